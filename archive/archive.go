@@ -0,0 +1,235 @@
+// Package archive reads and writes portable cache archives, streams of
+// checksummed records holding a cache key, its encoded tile packet and the
+// time it expires at, used to back up, restore and migrate proxy caches.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// magic identifies cache archives and their format version
+var magic = []byte("LODARC\x00\x02")
+
+// maxField is the largest key or packet a record may hold
+const maxField = 1 << 30
+
+// Record is a single cache entry within an archive
+type Record struct {
+	Cursor  string    // scan cursor of the batch the entry was exported in
+	Key     string    // cache key of the entry
+	Packet  []byte    // raw encoded tile packet
+	Expires time.Time // time the entry expires at, zero for never
+}
+
+// Writer writes records to an archive
+type Writer struct {
+	w      *bufio.Writer
+	record bytes.Buffer
+}
+
+// NewWriter starts a new archive written to w
+func NewWriter(w io.Writer) (*Writer, error) {
+	writer := AppendWriter(w)
+	if _, err := writer.w.Write(magic); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+// AppendWriter returns a writer appending records to an existing archive
+// that w is positioned at the end of
+func AppendWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// Write appends the record to the archive
+func (w *Writer) Write(record Record) error {
+	w.record.Reset()
+
+	var expires int64
+	if !record.Expires.IsZero() {
+		expires = record.Expires.UnixMilli()
+	}
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	for _, field := range []string{record.Cursor, record.Key} {
+		w.record.Write(buf[:binary.PutUvarint(buf, uint64(len(field)))])
+		w.record.WriteString(field)
+	}
+	w.record.Write(buf[:binary.PutVarint(buf, expires)])
+	w.record.Write(buf[:binary.PutUvarint(buf, uint64(len(record.Packet)))])
+	w.record.Write(record.Packet)
+
+	checksum := make([]byte, crc32.Size)
+	binary.BigEndian.PutUint32(checksum, crc32.ChecksumIEEE(w.record.Bytes()))
+
+	if _, err := w.w.Write(w.record.Bytes()); err != nil {
+		return err
+	}
+	_, err := w.w.Write(checksum)
+	return err
+}
+
+// Flush writes any buffered records to the underlying writer
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Reader reads records from an archive
+type Reader struct {
+	r      *countingReader
+	offset int64
+}
+
+// NewReader reads the archive from r, failing if it isn't an archive
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{r: &countingReader{r: bufio.NewReader(r)}}
+
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(reader.r, header); err != nil || !bytes.Equal(header, magic) {
+		return nil, ErrNotArchive{}
+	}
+
+	reader.offset = reader.r.n
+	return reader, nil
+}
+
+// Read returns the next record of the archive, io.EOF at the end of the
+// archive, or io.ErrUnexpectedEOF if the archive ends within a record
+func (r *Reader) Read() (Record, error) {
+	checked := &checkedReader{r: r.r, crc: crc32.NewIEEE()}
+
+	// only an archive ending between records ends at the first field
+	length, err := binary.ReadUvarint(checked)
+	if err == io.EOF {
+		return Record{}, io.EOF
+	}
+	if err != nil {
+		return Record{}, truncated(err)
+	}
+
+	cursor, err := r.readBytes(checked, length)
+	if err != nil {
+		return Record{}, err
+	}
+
+	key, err := r.readField(checked)
+	if err != nil {
+		return Record{}, err
+	}
+
+	expires, err := binary.ReadVarint(checked)
+	if err != nil {
+		return Record{}, truncated(err)
+	}
+
+	packet, err := r.readField(checked)
+	if err != nil {
+		return Record{}, err
+	}
+
+	checksum := make([]byte, crc32.Size)
+	if _, err = io.ReadFull(r.r, checksum); err != nil {
+		return Record{}, truncated(err)
+	}
+
+	if binary.BigEndian.Uint32(checksum) != checked.crc.Sum32() {
+		return Record{}, ErrCorruptRecord{Offset: r.offset}
+	}
+
+	record := Record{
+		Cursor: string(cursor),
+		Key:    string(key),
+		Packet: packet,
+	}
+	if expires != 0 {
+		record.Expires = time.UnixMilli(expires)
+	}
+
+	r.offset = r.r.n
+	return record, nil
+}
+
+// Offset returns the number of bytes of the archive read up to the end of
+// the last complete record, where writing may resume after a failure
+func (r *Reader) Offset() int64 {
+	return r.offset
+}
+
+// readField reads a length-prefixed field of a record
+func (r *Reader) readField(checked *checkedReader) ([]byte, error) {
+	length, err := binary.ReadUvarint(checked)
+	if err != nil {
+		return nil, truncated(err)
+	}
+
+	return r.readBytes(checked, length)
+}
+
+// readBytes reads a field of a record of the given length
+func (r *Reader) readBytes(checked *checkedReader, length uint64) ([]byte, error) {
+	if length > maxField {
+		return nil, ErrCorruptRecord{Offset: r.offset}
+	}
+
+	field := make([]byte, length)
+	if _, err := io.ReadFull(checked, field); err != nil {
+		return nil, truncated(err)
+	}
+
+	return field, nil
+}
+
+// truncated reports an archive ending within a record as io.ErrUnexpectedEOF
+func truncated(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// countingReader counts the bytes read from the underlying reader
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+// checkedReader checksums the bytes of a record as they're read
+type checkedReader struct {
+	r   *countingReader
+	crc hash.Hash32
+}
+
+func (c *checkedReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	_, _ = c.crc.Write(p[:n])
+	return n, err
+}
+
+func (c *checkedReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		_, _ = c.crc.Write([]byte{b})
+	}
+	return b, err
+}
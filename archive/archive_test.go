@@ -0,0 +1,65 @@
+package archive
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+// TestRoundTrip will test that records written to an archive are read back
+// intact, and that a truncated archive reports where writing can resume
+func TestRoundTrip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	expires := time.UnixMilli(time.Now().Add(time.Hour).UnixMilli())
+
+	w, err := NewWriter(buf)
+	if err != nil {
+		t.Fatalf("failed to create writer, error=%s", err.Error())
+	}
+
+	records := []Record{
+		{Key: "1/2/3", Packet: []byte("tile"), Expires: expires},
+		{Cursor: "1:3072", Key: "4/5/6", Packet: []byte("another tile")},
+	}
+
+	for _, record := range records {
+		if err = w.Write(record); err != nil {
+			t.Fatalf("failed to write record, error=%s", err.Error())
+		}
+	}
+
+	if err = w.Flush(); err != nil {
+		t.Fatalf("failed to flush, error=%s", err.Error())
+	}
+
+	complete := buf.Len()
+
+	// simulate an export interrupted within a record
+	buf.Write([]byte{9, 5, '7'})
+
+	r, err := NewReader(buf)
+	if err != nil {
+		t.Fatalf("failed to create reader, error=%s", err.Error())
+	}
+
+	for _, expected := range records {
+		record, errRead := r.Read()
+		if errRead != nil {
+			t.Fatalf("failed to read record, error=%s", errRead.Error())
+		}
+
+		if record.Cursor != expected.Cursor || record.Key != expected.Key ||
+			!bytes.Equal(record.Packet, expected.Packet) || !record.Expires.Equal(expected.Expires) {
+			t.Errorf("expected record %+v, got %+v", expected, record)
+		}
+	}
+
+	if _, err = r.Read(); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected truncated record, got %v", err)
+	}
+
+	if r.Offset() != int64(complete) {
+		t.Errorf("expected resumable offset %d, got %d", complete, r.Offset())
+	}
+}
@@ -0,0 +1,21 @@
+package archive
+
+import "fmt"
+
+// ErrNotArchive is thrown when reading data that isn't a cache archive
+type ErrNotArchive struct{}
+
+// Error returns the string representation of ErrNotArchive
+func (e ErrNotArchive) Error() string {
+	return "archive: not a cache archive or unsupported version"
+}
+
+// ErrCorruptRecord is thrown when a record fails its checksum or is malformed
+type ErrCorruptRecord struct {
+	Offset int64
+}
+
+// Error returns the string representation of ErrCorruptRecord
+func (e ErrCorruptRecord) Error() string {
+	return fmt.Sprintf("archive: corrupt record at offset %d", e.Offset)
+}
@@ -0,0 +1,306 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/util"
+)
+
+// Backend is a single level of a proxy's tiered tile cache. Entries are the
+// raw bytes of encoded tile packets.
+type Backend interface {
+	// Name returns the name of the cache level, ex: memory, redis
+	Name() string
+	// Get returns the entry for the given key, or nil if not present.
+	// Backends that extend an entry's expiry when read reset it to the
+	// given TTL, persist the entry if the TTL is zero, or leave its expiry
+	// untouched if the TTL is KeepTTL.
+	Get(ctx context.Context, key string, ttl time.Duration) ([]byte, error)
+	// Set stores the entry for the given key, expiring it after the given
+	// TTL, or never if the TTL is zero. Backends with a single fixed
+	// lifetime for all entries may ignore the TTL.
+	Set(ctx context.Context, key string, data []byte, ttl time.Duration) error
+	// Delete removes the entry for the given key, succeeding if not present
+	Delete(ctx context.Context, key string) error
+	// Flush removes every entry the backend holds for its proxy
+	Flush(ctx context.Context) error
+	// Stats returns usage stats, with -1 for values the backend can't report
+	Stats() BackendStats
+	// Close releases any resources held by the backend
+	Close() error
+}
+
+// KeepTTL is passed to Backend.Get to read an entry without touching its expiry
+const KeepTTL time.Duration = -1
+
+// Scanner is implemented by cache levels able to enumerate their entries,
+// which the on-disk and memcached levels can't since they only hold hashes
+// of cache keys
+type Scanner interface {
+	// Scan calls fn with batches of the unexpired entries held for the
+	// proxy, starting from the given cursor, or the first entry if empty,
+	// and skipping entries the filter rejects. fn is given the cursor to
+	// resume scanning after each batch from, which is empty after the last
+	// batch. Batches hold up to roughly count entries before filtering, or
+	// a default number if count is zero, and may be empty. Scanning stops
+	// once every entry is scanned or fn returns an error.
+	Scan(ctx context.Context, cursor string, count int, filter Filter,
+		fn func(batch []Entry, next string) error) error
+}
+
+// Filter is called by a Scanner with the key of each entry before reading
+// it, skipping the entry if it returns false. A nil Filter accepts every
+// entry.
+type Filter func(key string) bool
+
+// accept returns whether the filter accepts the entry
+func (f Filter) accept(key string) bool {
+	return f == nil || f(key)
+}
+
+// Entry is a single entry enumerated by a Scanner
+type Entry struct {
+	Key     string    // cache key of the entry
+	Data    []byte    // raw bytes of the entry's encoded tile packet
+	Expires time.Time // time the entry expires at, zero for never
+}
+
+// batcher batches the entries of Scanners enumerating their entries in a
+// stable order, using positions in that order as cursors
+type batcher struct {
+	from     int                                    // position to start from
+	position int                                    // position of the next entry
+	count    int                                    // entries scanned per batch
+	scanned  int                                    // entries scanned in the batch
+	batch    []Entry                                // accepted entries of the batch
+	fn       func(batch []Entry, next string) error // called with each batch
+}
+
+// newBatcher returns a batcher starting from the given position cursor
+func newBatcher(cursor string, count int, fn func(batch []Entry, next string) error) (*batcher, error) {
+	from := 0
+	if cursor != "" {
+		var err error
+		if from, err = strconv.Atoi(cursor); err != nil || from < 0 {
+			return nil, ErrInvalidCursor{Cursor: cursor}
+		}
+	}
+
+	if count <= 0 {
+		count = scanCount
+	}
+
+	return &batcher{from: from, count: count, fn: fn}, nil
+}
+
+// skip advances past the next entry, returning true if it's before the
+// position scanning started from
+func (b *batcher) skip() bool {
+	b.position++
+	return b.position <= b.from
+}
+
+// add counts a scanned entry, adding it to the batch if accepted, and calls
+// fn with the batch once it's full
+func (b *batcher) add(entry *Entry) error {
+	if entry != nil {
+		b.batch = append(b.batch, *entry)
+	}
+
+	if b.scanned++; b.scanned < b.count {
+		return nil
+	}
+
+	err := b.fn(b.batch, strconv.Itoa(b.position))
+	b.batch, b.scanned = nil, 0
+	return err
+}
+
+// done calls fn with the last batch once every entry is scanned
+func (b *batcher) done() error {
+	return b.fn(b.batch, "")
+}
+
+// BackendStats contains usage stats about a cache level
+type BackendStats struct {
+	Name    string `json:"name"`    // name of the cache level
+	Shared  bool   `json:"shared"`  // whether the cache level is shared between instances
+	Hits    uint64 `json:"hits"`    // number of fetches served by this level
+	Misses  uint64 `json:"misses"`  // number of fetches this level could not serve
+	Entries int64  `json:"entries"` // number of entries stored
+	Size    int64  `json:"size"`    // total size of entries in bytes
+	Cap     int64  `json:"cap"`     // maximum total size of entries in bytes
+}
+
+// layer is a cache level within a proxy's ordered list of cache levels
+type layer struct {
+	backend Backend       // cache level implementation
+	ttl     time.Duration // TTL of entries set in this level
+	shared  bool          // whether entries are shared with other instances
+	refresh bool          // whether entries must be rewritten on hit to stay alive
+	id      string        // identifies the backend's settings, so it can be reused across reloads
+	hits    uint64        // number of fetches served by this level
+	misses  uint64        // number of fetches this level could not serve
+}
+
+// TTLs are the TTLs of a tile's entries by cache level name, overriding the
+// TTLs configured for each level
+type TTLs map[string]time.Duration
+
+// of returns the TTL of the tile's entry in the given layer
+func (t TTLs) of(l *layer) time.Duration {
+	if ttl, ok := t[l.backend.Name()]; ok {
+		return ttl
+	}
+	return l.ttl
+}
+
+// hitLabels maps cache level names to the cache status reported on hits
+var hitLabels = map[string]string{
+	config.LayerMemory:    ":hit-i",
+	config.LayerDisk:      ":hit-d",
+	config.LayerRedis:     ":hit-e",
+	config.LayerMemcached: ":hit-m",
+	config.LayerS3:        ":hit-s",
+}
+
+// hitLabel returns the cache status reported when the layer serves a hit
+func (l *layer) hitLabel() string {
+	if label, ok := hitLabels[l.backend.Name()]; ok {
+		return label
+	}
+	return ":hit-" + l.backend.Name()
+}
+
+// stats returns the layer's backend stats along with its hit counts
+func (l *layer) stats() BackendStats {
+	stats := l.backend.Stats()
+	stats.Name = l.backend.Name()
+	stats.Shared = l.shared
+	stats.Hits = atomic.LoadUint64(&l.hits)
+	stats.Misses = atomic.LoadUint64(&l.misses)
+	return stats
+}
+
+// layerID returns a value identifying the settings of the named cache level,
+// so that unchanged levels are kept across configuration reloads
+func layerID(proxy config.Proxy, name string) string {
+	switch name {
+	case config.LayerMemory:
+		return fmt.Sprintf("%s:%d|%s|%t", name, proxy.Cache.MemCap, proxy.Cache.MaxMemTTL(), proxy.Cache.Dedup)
+	case config.LayerDisk:
+		return fmt.Sprintf("%s:%s|%d|%s", name, proxy.Cache.DiskPath, proxy.Cache.DiskCap, proxy.Cache.DiskTTLDuration)
+	case config.LayerRedis:
+		return fmt.Sprintf("%s:%s|%s|%s|%t", name, proxy.Cache.RedisConnection.Fingerprint(),
+			proxy.Cache.RedisTTLDuration, proxy.Cache.MaxRedisTTL(), proxy.Cache.Dedup)
+	case config.LayerMemcached:
+		return fmt.Sprintf("%s:%v|%s|%d|%t|%s", name, proxy.Cache.MemcachedServers,
+			proxy.Cache.MemcachedTTLDuration, proxy.Cache.MemcachedItemSize,
+			proxy.Cache.MemcachedChunk, proxy.Cache.MemcachedTimeoutDuration)
+	case config.LayerS3:
+		return fmt.Sprintf("%s:%s|%s|%s|%s|%s|%s|%t|%s", name, proxy.Cache.S3Endpoint, proxy.Cache.S3Bucket,
+			proxy.Cache.S3Region, proxy.Cache.S3Prefix, proxy.Cache.S3AccessKey, proxy.Cache.S3SecretKey,
+			proxy.Cache.S3PathStyle, proxy.Cache.S3TTLDuration)
+	}
+	return name
+}
+
+// buildLayers builds the ordered cache levels configured for the proxy,
+// reusing any levels from the previous list whose settings are unchanged
+func buildLayers(proxy config.Proxy, previous []*layer) ([]*layer, error) {
+	reusable := make(map[string]*layer)
+	for _, l := range previous {
+		reusable[l.id] = l
+	}
+
+	layers := make([]*layer, 0, len(proxy.Cache.Layers))
+	for _, name := range proxy.Cache.Layers {
+		id := layerID(proxy, name)
+
+		if old, ok := reusable[id]; ok {
+			layers = append(layers, old)
+			delete(reusable, id)
+			continue
+		}
+
+		l, err := newLayer(proxy, name)
+		if err != nil {
+			closeLayers(layers, previous)
+			return nil, err
+		}
+		l.id = id
+
+		layers = append(layers, l)
+	}
+
+	return layers, nil
+}
+
+// newLayer initializes the named cache level from proxy configuration
+func newLayer(proxy config.Proxy, name string) (*layer, error) {
+	switch name {
+	case config.LayerMemory:
+		backend, err := newMemoryBackend(proxy)
+		if err != nil {
+			return nil, ErrInitInternalCache{Name: proxy.Name, Err: err}
+		}
+		return &layer{
+			backend: withDedup(proxy, backend, proxy.Cache.MaxMemTTL()),
+			ttl:     proxy.Cache.MemTTLDuration,
+			refresh: true,
+		}, nil
+	case config.LayerDisk:
+		backend, err := newDiskBackend(proxy)
+		if err != nil {
+			return nil, ErrInitDiskCache{Name: proxy.Name, Err: err}
+		}
+		return &layer{backend: backend, ttl: proxy.Cache.DiskTTLDuration}, nil
+	case config.LayerRedis:
+		backend, err := newRedisBackend(proxy)
+		if err != nil {
+			return nil, ErrInitExternalCache{Name: proxy.Name, Err: err}
+		}
+		return &layer{
+			backend: withDedup(proxy, backend, proxy.Cache.MaxRedisTTL()),
+			ttl:     proxy.Cache.RedisTTLDuration,
+			shared:  true,
+		}, nil
+	case config.LayerMemcached:
+		backend, err := newMemcachedBackend(proxy)
+		if err != nil {
+			return nil, ErrInitMemcachedCache{Name: proxy.Name, Err: err}
+		}
+		return &layer{backend: backend, ttl: proxy.Cache.MemcachedTTLDuration, shared: true}, nil
+	case config.LayerS3:
+		backend, err := newObjectBackend(proxy)
+		if err != nil {
+			return nil, ErrInitObjectCache{Name: proxy.Name, Err: err}
+		}
+		return &layer{backend: backend, ttl: proxy.Cache.S3TTLDuration, shared: true}, nil
+	}
+
+	return nil, ErrUnknownLayer{Name: proxy.Name, Layer: name}
+}
+
+// closeLayers closes the backends of the given layers that aren't kept
+func closeLayers(layers []*layer, kept []*layer) {
+	keep := make(map[*layer]bool)
+	for _, l := range kept {
+		keep[l] = true
+	}
+
+	for _, l := range layers {
+		if keep[l] {
+			continue
+		}
+		if err := l.backend.Close(); err != nil {
+			util.Error(str.CCache, str.ECacheClose, l.backend.Name(), err.Error())
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/packet"
+)
+
+// fakeBackend is an in-memory Backend for testing tiered cache behavior
+type fakeBackend struct {
+	name    string
+	lock    sync.Mutex
+	entries map[string][]byte
+	reads   map[string]time.Duration // TTL each key was last read with
+	writes  map[string]time.Duration // TTL each key was last written with
+	failSet error                    // error returned by every Set, if any
+}
+
+func newFakeBackend(name string) *fakeBackend {
+	return &fakeBackend{
+		name:    name,
+		entries: make(map[string][]byte),
+		reads:   make(map[string]time.Duration),
+		writes:  make(map[string]time.Duration),
+	}
+}
+
+func (f *fakeBackend) Name() string {
+	return f.name
+}
+
+func (f *fakeBackend) Get(_ context.Context, key string, ttl time.Duration) ([]byte, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.reads[key] = ttl
+	return f.entries[key], nil
+}
+
+func (f *fakeBackend) Set(_ context.Context, key string, data []byte, ttl time.Duration) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.failSet != nil {
+		return f.failSet
+	}
+	f.entries[key] = data
+	f.writes[key] = ttl
+	return nil
+}
+
+func (f *fakeBackend) Delete(_ context.Context, key string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.entries, key)
+	return nil
+}
+
+func (f *fakeBackend) Flush(_ context.Context) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.entries = make(map[string][]byte)
+	return nil
+}
+
+func (f *fakeBackend) Stats() BackendStats {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return BackendStats{Entries: int64(len(f.entries))}
+}
+
+func (f *fakeBackend) Close() error {
+	return nil
+}
+
+// has returns true if the backend holds an entry for the key
+func (f *fakeBackend) has(key string) bool {
+	data, _ := f.Get(context.Background(), key, 0)
+	return data != nil
+}
+
+// newTestCache creates a cache over a local and a shared fake backend
+func newTestCache() (*Cache, *fakeBackend, *fakeBackend) {
+	local := newFakeBackend("local")
+	shared := newFakeBackend("shared")
+
+	c := &Cache{
+		layers: []*layer{
+			{backend: local, refresh: true},
+			{backend: shared, shared: true},
+		},
+		proxy: &config.Proxy{Name: "test"},
+	}
+	c.Metrics = newMetrics(c)
+
+	return c, local, shared
+}
+
+// TestLookupPromotes will test that a tile found in a lower cache level is
+// returned with that level's status and promoted to the levels above it
+func TestLookupPromotes(t *testing.T) {
+	c, local, shared := newTestCache()
+	tile := packet.Encode([]byte("tile"), map[string]string{})
+
+	_ = shared.Set(context.Background(), "1/2/3", tile.Raw(), 0)
+
+	found, hit := c.lookup(context.Background(), "1/2/3", nil)
+	if found == nil {
+		t.Fatalf("expected tile to be found in shared level")
+	}
+
+	if hit != ":hit-shared" {
+		t.Errorf("expected hit status :hit-shared, got %s", hit)
+	}
+
+	// promotion happens in the background
+	deadline := time.Now().Add(time.Second)
+	for !local.has("1/2/3") && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if !local.has("1/2/3") {
+		t.Errorf("expected tile to be promoted to local level")
+	}
+
+	if stats := c.Stats(); stats[0].Misses != 1 || stats[1].Hits != 1 {
+		t.Errorf("unexpected layer stats %+v", stats)
+	}
+
+	if found, _ = c.lookup(context.Background(), "4/5/6", nil); found != nil {
+		t.Errorf("expected miss for uncached tile")
+	}
+}
+
+// TestInvalidateInternal will test that internal invalidation and flushes
+// leave shared cache levels untouched
+func TestInvalidateInternal(t *testing.T) {
+	c, local, shared := newTestCache()
+	tile := packet.Encode([]byte("tile"), map[string]string{})
+
+	for _, key := range []string{"1/2/3", "4/5/6"} {
+		_ = local.Set(context.Background(), key, tile.Raw(), 0)
+		_ = shared.Set(context.Background(), key, tile.Raw(), 0)
+	}
+
+	if err := c.InvalidateInternal("1/2/3"); err != nil {
+		t.Fatalf("failed to invalidate, error=%s", err.Error())
+	}
+
+	if local.has("1/2/3") || !shared.has("1/2/3") {
+		t.Errorf("expected tile to be invalidated from the local level only")
+	}
+
+	if err := c.Invalidate("4/5/6", context.Background()); err != nil {
+		t.Fatalf("failed to invalidate, error=%s", err.Error())
+	}
+
+	if local.has("4/5/6") || shared.has("4/5/6") {
+		t.Errorf("expected tile to be invalidated from all levels")
+	}
+
+	_ = local.Set(context.Background(), "7/8/9", tile.Raw(), 0)
+	_ = shared.Set(context.Background(), "7/8/9", tile.Raw(), 0)
+
+	if err := c.FlushInternal(); err != nil {
+		t.Fatalf("failed to flush, error=%s", err.Error())
+	}
+
+	if local.has("7/8/9") || !shared.has("7/8/9") {
+		t.Errorf("expected only the local level to be flushed")
+	}
+}
+
+// TestSetWait will test that waiting sets only return once shared levels
+// are set, with any error setting them
+func TestSetWait(t *testing.T) {
+	c, local, shared := newTestCache()
+	tile := packet.Encode([]byte("tile"), map[string]string{})
+
+	if err := c.SetWait("1/2/3", tile, nil, nil); err != nil {
+		t.Fatalf("failed to set tile, error=%s", err.Error())
+	}
+	if !local.has("1/2/3") || !shared.has("1/2/3") {
+		t.Error("expected tile to be set in every level before returning")
+	}
+
+	shared.failSet = errors.New("unavailable")
+	if err := c.SetWait("4/5/6", tile, nil, nil); err != shared.failSet {
+		t.Errorf("expected shared level error, got %v", err)
+	}
+}
+
+// TestInvalidateTagInternal will test that tiles are purged from local
+// levels by tag, leaving untagged tiles and shared levels untouched
+func TestInvalidateTagInternal(t *testing.T) {
+	c, local, shared := newTestCache()
+	tile := packet.Encode([]byte("tile"), map[string]string{})
+
+	c.Set("1/2/3", tile, nil, []string{"parcels", "county-42"}, true)
+	c.Set("4/5/6", tile, nil, []string{"parcels"}, true)
+	c.Set("7/8/9", tile, nil, nil, true)
+	_ = shared.Set(context.Background(), "1/2/3", tile.Raw(), 0)
+
+	purged, err := c.InvalidateTagInternal("county-42")
+	if err != nil {
+		t.Fatalf("failed to invalidate tag, error=%s", err.Error())
+	}
+
+	if purged != 1 || local.has("1/2/3") || !local.has("4/5/6") || !shared.has("1/2/3") {
+		t.Errorf("expected only 1/2/3 to be purged from the local level, purged %d", purged)
+	}
+
+	// purged keys must no longer be indexed under their other tags
+	if purged, _ = c.InvalidateTagInternal("parcels"); purged != 1 {
+		t.Errorf("expected 1 remaining tile tagged parcels, purged %d", purged)
+	}
+
+	if !local.has("7/8/9") {
+		t.Errorf("expected untagged tile to remain")
+	}
+}
+
+// TestWarm will test that warming loads tiles from shared cache levels into
+// local levels with the given TTLs, skipping tiles past their recorded expiry
+// and leaving the expiry of the level holding them untouched
+func TestWarm(t *testing.T) {
+	c, local, shared := newTestCache()
+	ctx := context.Background()
+
+	fresh := packet.Encode([]byte("tile"), map[string]string{})
+	expired := packet.EncodeMeta([]byte("tile"), map[string]string{}, packet.Meta{
+		Expires: time.Now().Add(-time.Minute),
+	})
+
+	_ = shared.Set(ctx, "1/2/3", fresh.Raw(), 0)
+	_ = shared.Set(ctx, "4/5/6", expired.Raw(), 0)
+
+	if found, _ := c.Warm(ctx, "1/2/3", TTLs{"local": time.Minute}); !found || !local.has("1/2/3") {
+		t.Errorf("expected tile to be warmed into local level")
+	}
+
+	if ttl := local.writes["1/2/3"]; ttl != time.Minute {
+		t.Errorf("expected tile to be warmed with the given TTL, got %s", ttl)
+	}
+
+	if ttl := shared.reads["1/2/3"]; ttl != KeepTTL {
+		t.Errorf("expected shared level to be read with KeepTTL, got %s", ttl)
+	}
+
+	if found, _ := c.Warm(ctx, "4/5/6", nil); found || local.has("4/5/6") {
+		t.Errorf("expected expired tile not to be warmed")
+	}
+
+	if found, _ := c.Warm(ctx, "7/8/9", nil); found {
+		t.Errorf("expected uncached tile not to be found")
+	}
+}
+
+// TestDedup will test that identical tile data is stored once by content
+// hash, and that references to missing tile data are treated as misses
+func TestDedup(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeBackend("memory")
+	proxy := config.Proxy{Name: "test", Cache: config.Cache{Dedup: true}}
+	d := withDedup(proxy, fake, 0)
+
+	tile := bytes.Repeat([]byte("ocean"), 100)
+	tilePacket := packet.EncodeMeta(tile, nil, packet.Meta{ContentHash: packet.ContentHash(tile)})
+
+	_ = d.Set(ctx, "a", tilePacket.Raw(), 0)
+	_ = d.Set(ctx, "b", tilePacket.Raw(), 0)
+	if stats := fake.Stats(); stats.Entries != 3 {
+		t.Fatalf("expected tile data to be stored once, got %d entries", stats.Entries)
+	}
+
+	data, _ := d.Get(ctx, "b", 0)
+	decoded, err := packet.FromBytes(data, "b")
+	if err != nil || !bytes.Equal(decoded.TileData(), tile) {
+		t.Fatalf("expected joined packet to hold the tile, error=%v", err)
+	}
+
+	// drop the tile data, leaving both references dangling
+	for key := range fake.entries {
+		if strings.HasPrefix(key, blobPrefix) {
+			_ = fake.Delete(ctx, key)
+		}
+	}
+
+	if data, _ = d.Get(ctx, "a", 0); data != nil {
+		t.Errorf("expected dangling reference to miss")
+	}
+	if fake.has("a") {
+		t.Errorf("expected dangling reference to be removed")
+	}
+}
+
+// TestDedupBlobTTL will test that tile data outlives every reference to it,
+// is read without touching its expiry and isn't rewritten once stored
+func TestDedupBlobTTL(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeBackend("memory")
+	proxy := config.Proxy{Name: "test", Cache: config.Cache{Dedup: true}}
+	d := withDedup(proxy, fake, 30*24*time.Hour)
+
+	tile := bytes.Repeat([]byte("ocean"), 100)
+	tilePacket := packet.EncodeMeta(tile, nil, packet.Meta{ContentHash: packet.ContentHash(tile)})
+	blob := d.(*dedupBackend).blobKey(packet.ContentHash(tile), packet.CodecNone)
+
+	_ = d.Set(ctx, "a", tilePacket.Raw(), time.Hour)
+	if ttl := fake.writes[blob]; ttl != 30*24*time.Hour {
+		t.Errorf("expected tile data to be stored with the longest TTL, got %s", ttl)
+	}
+	if ttl := fake.writes["a"]; ttl != time.Hour {
+		t.Errorf("expected reference to keep its own TTL, got %s", ttl)
+	}
+
+	// tile data that's already stored isn't rewritten
+	delete(fake.writes, blob)
+	_ = d.Set(ctx, "b", tilePacket.Raw(), time.Minute)
+	if _, ok := fake.writes[blob]; ok {
+		t.Error("expected stored tile data not to be rewritten")
+	}
+
+	if data, _ := d.Get(ctx, "b", time.Minute); data == nil {
+		t.Fatal("expected tile to be found")
+	}
+	if ttl := fake.reads[blob]; ttl != KeepTTL {
+		t.Errorf("expected tile data to be read without touching its expiry, got ttl=%s", ttl)
+	}
+	if ttl := fake.reads["b"]; ttl != time.Minute {
+		t.Errorf("expected reference to be read with its TTL, got ttl=%s", ttl)
+	}
+}
+
+// TestPeekKeepsTTL will test that peeking at a tile reads every cache level
+// without touching the expiry of its entries, including deduplicated tile data
+func TestPeekKeepsTTL(t *testing.T) {
+	c, _, shared := newTestCache()
+	c.layers[1].backend = withDedup(config.Proxy{Name: "test", Cache: config.Cache{Dedup: true}}, shared, time.Hour)
+	c.layers[1].ttl = time.Hour
+
+	tile := bytes.Repeat([]byte("ocean"), 100)
+	tilePacket := packet.EncodeMeta(tile, nil, packet.Meta{ContentHash: packet.ContentHash(tile)})
+	_ = c.layers[1].backend.Set(context.Background(), "a", tilePacket.Raw(), time.Hour)
+
+	if c.Peek("a", context.Background()) == nil {
+		t.Fatal("expected tile to be found")
+	}
+
+	if len(shared.reads) != 2 {
+		t.Fatalf("expected the reference and tile data to be read, got %v", shared.reads)
+	}
+	for key, ttl := range shared.reads {
+		if ttl != KeepTTL {
+			t.Errorf("expected %s to be read without touching its expiry, got ttl=%s", key, ttl)
+		}
+	}
+}
+
+// TestMetricsLifecycle will test that a removed proxy's metrics can be
+// registered again by a new cache instance of the same name, and that failed
+// registrations leave no metrics behind
+func TestMetricsLifecycle(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	first, _, _ := newTestCache()
+	second, _, _ := newTestCache()
+
+	if err := first.Metrics.register(registry); err != nil {
+		t.Fatalf("failed to register metrics, error=%s", err.Error())
+	}
+
+	if err := second.Metrics.register(registry); err == nil {
+		t.Fatalf("expected duplicate metrics to fail registration")
+	}
+
+	first.Metrics.unregister(registry)
+	if families, _ := registry.Gather(); len(families) != 0 {
+		t.Fatalf("expected no metrics after unregistering, got %d", len(families))
+	}
+
+	if err := second.Metrics.register(registry); err != nil {
+		t.Fatalf("failed to re-register metrics, error=%s", err.Error())
+	}
+}
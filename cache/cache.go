@@ -2,18 +2,15 @@ package cache
 
 import (
 	"context"
-	"crypto/tls"
-	"os"
-	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/allegro/bigcache/v3"
 	"github.com/go-redis/redis/v8"
 	"github.com/gofiber/fiber/v2"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 
 	"github.com/dechristopher/lod/config"
-	"github.com/dechristopher/lod/env"
 	"github.com/dechristopher/lod/packet"
 	"github.com/dechristopher/lod/str"
 	"github.com/dechristopher/lod/util"
@@ -24,23 +21,27 @@ var Subsystem = "cache"
 // CachesMap is an alias type for the map of proxy name to its cache
 type CachesMap map[string]*Cache
 
-// Caches configured for this instance
+// Caches configured for this instance, read through Get and All since
+// reloads modify the map while requests are served
 var Caches = make(CachesMap)
 
-// Cache is a wrapper struct that operates a dual cache against the in-memory
-// cache and Redis as a backing cache
-type Cache struct {
-	internal *bigcache.BigCache // pointer to internal cache instance
-	external *redis.Client      // pointer to external Redis cache
-	Proxy    *config.Proxy      // a reference to the proxy's configuration
-	Metrics  *Metrics           // metrics container instance
-}
+// cachesLock guards the Caches map
+var cachesLock sync.RWMutex
 
-// Metrics for the cache instance
-type Metrics struct {
-	CacheHits   prometheus.Counter     // cache hits
-	CacheMisses prometheus.Counter     // cache misses
-	HitRate     prometheus.CounterFunc // cache hit rate
+// Cache is a wrapper struct that operates a tiered cache against an ordered
+// list of cache levels, by default the in-memory cache, an optional on-disk
+// cache, Redis as a backing cache and optional object storage behind Redis
+type Cache struct {
+	lock     sync.RWMutex  // guards the cache levels and proxy, which may change on reload
+	layers   []*layer      // ordered cache levels, checked first to last
+	proxy    *config.Proxy // the proxy's current configuration
+	gens     generations   // namespace generations, if the proxy is versioned
+	tags     tagIndex      // keys of locally cached tiles by tag
+	hot      hotKeys       // requests by key, if the proxy tracks its most popular keys
+	heat     heatmap       // sampled requests by tile, if the proxy keeps a heatmap
+	lookups  window        // latency of cache lookups over the past minute
+	upstream window        // latency of upstream fetches over the past minute
+	Metrics  *Metrics      // metrics container instance
 }
 
 // OneMB represents one megabyte worth of bytes
@@ -50,7 +51,8 @@ const OneMB = 1024 * 1024
 func Init() error {
 	// build out initial proxy instances
 	for _, proxy := range config.Get().Proxies {
-		if Caches[proxy.Name] == nil {
+		existing := Get(proxy.Name)
+		if existing == nil {
 			err := BuildInstance(proxy.Name)
 			if err != nil {
 				return ErrBuildInstance{
@@ -58,6 +60,15 @@ func Init() error {
 					Err:  err,
 				}
 			}
+			continue
+		}
+
+		// rebuild the cache levels of existing caches whose levels changed
+		if err := existing.rebuild(proxy); err != nil {
+			return ErrBuildInstance{
+				Name: proxy.Name,
+				Err:  err,
+			}
 		}
 	}
 
@@ -68,15 +79,31 @@ func Init() error {
 
 // Get a cache instance by name
 func Get(name string) *Cache {
+	cachesLock.RLock()
+	defer cachesLock.RUnlock()
 	return Caches[name]
 }
 
+// All returns a snapshot of every cache instance by name
+func All() CachesMap {
+	cachesLock.RLock()
+	defer cachesLock.RUnlock()
+
+	all := make(CachesMap, len(Caches))
+	for name, c := range Caches {
+		all[name] = c
+	}
+	return all
+}
+
 // WipeOldCaches deletes old cache instances from the Caches map
 // Usually called after a config read/reload
 func WipeOldCaches() {
 	proxies := config.Get().Proxies
 
-	for cacheName := range Caches {
+	cachesLock.Lock()
+	var removed []*Cache
+	for cacheName, c := range Caches {
 		present := false
 
 		// ensure proxy is present in current config
@@ -94,8 +121,16 @@ func WipeOldCaches() {
 
 		// delete old cache if not present in current config
 		util.Info(str.CCache, str.MOldCacheDeleted, cacheName)
+		removed = append(removed, c)
 		delete(Caches, cacheName)
 	}
+	cachesLock.Unlock()
+
+	// close deleted caches once requests can no longer find them
+	for _, c := range removed {
+		closeLayers(c.getLayers(), nil)
+		c.Metrics.unregister(Registry)
+	}
 }
 
 // BuildInstance will build a cache instance by name
@@ -103,41 +138,23 @@ func BuildInstance(name string) error {
 	// find and populate a new cache instance for the given name
 	for _, proxy := range config.Get().Proxies {
 		if proxy.Name == name {
-			var internal *bigcache.BigCache
-			var external *redis.Client
-			var err error
-
-			if proxy.Cache.MemEnabled {
-				internal, err = initInternal(proxy)
-				if err != nil {
-					return ErrInitInternalCache{
-						Name: proxy.Name,
-						Err:  err,
-					}
-				}
+			c, err := New(proxy)
+			if err != nil {
+				return err
 			}
 
-			if proxy.Cache.RedisEnabled {
-				external, err = initExternal(proxy)
-				if err != nil {
-					return ErrInitExternalCache{
-						Name: proxy.Name,
-						Err:  err,
-					}
-				}
+			// register metrics once nothing else can fail, so failed builds
+			// leave no metrics behind
+			if err = c.Metrics.register(Registry); err != nil {
+				closeLayers(c.layers, nil)
+				return ErrRegisterMetrics{Name: name, Err: err}
 			}
 
-			// initialize metrics for this cache instance
-			metrics := initMetrics(proxy)
-
 			util.DebugFlag("cache", str.CCache, str.DCacheUp, name)
 
-			Caches[name] = &Cache{
-				internal: internal,
-				external: external,
-				Proxy:    &proxy,
-				Metrics:  metrics,
-			}
+			cachesLock.Lock()
+			Caches[name] = c
+			cachesLock.Unlock()
 
 			return nil
 		}
@@ -146,238 +163,471 @@ func BuildInstance(name string) error {
 	return nil
 }
 
-// initInternal initializes an in-memory cache instance from proxy configuration
-func initInternal(proxy config.Proxy) (*bigcache.BigCache, error) {
-	maxEntrySize := 4
+// New builds a cache instance and its cache levels for the given proxy
+// without registering its metrics or adding it to the Caches map
+func New(proxy config.Proxy) (*Cache, error) {
+	c := &Cache{proxy: &proxy}
 
-	// allow override of MaxEntrySize via env var
-	if max, present := os.LookupEnv("MAX_ENTRY_SIZE"); present {
-		if maxInt, err := strconv.Atoi(max); err == nil {
-			maxEntrySize = maxInt
-		} else {
-			util.Error(str.CCache, str.ECacheEntry, max)
-		}
+	// initialize metrics for this cache instance
+	c.Metrics = newMetrics(c)
+
+	layers, err := buildLayers(proxy, nil)
+	if err != nil {
+		return nil, err
 	}
+	c.instrument(layers)
+	c.layers = layers
+
+	return c, nil
+}
 
-	conf := bigcache.DefaultConfig(proxy.Cache.MemTTLDuration)
-	conf.StatsEnabled = !env.IsProd()
-	conf.MaxEntrySize = OneMB * maxEntrySize
-	conf.HardMaxCacheSize = proxy.Cache.MemCap
+// rebuild replaces the cache levels of an existing cache instance after a
+// configuration reload, keeping levels whose settings are unchanged along
+// with their contents, closing levels that are no longer used and adopting
+// the reloaded proxy configuration. The cache instance itself is kept since
+// jobs and handlers hold a reference to it.
+func (c *Cache) rebuild(proxy config.Proxy) error {
+	previous := c.getLayers()
+
+	layers, err := buildLayers(proxy, previous)
+	if err != nil {
+		return err
+	}
+	c.instrument(layers)
 
-	return bigcache.New(context.TODO(), conf)
+	c.lock.Lock()
+	c.layers = layers
+	c.proxy = &proxy
+	c.lock.Unlock()
+
+	closeLayers(previous, layers)
+	return nil
 }
 
-// initExternal initializes an external cache instance from proxy configuration
-func initExternal(proxy config.Proxy) (*redis.Client, error) {
-	if proxy.Cache.RedisTLS {
-		proxy.Cache.RedisOpts.TLSConfig = &tls.Config{
-			MinVersion: tls.VersionTLS12,
+// instrument hands the cache instance's metrics to new cache levels, levels
+// kept across reloads already holding them
+func (c *Cache) instrument(layers []*layer) {
+	for _, l := range layers {
+		if r, ok := unwrap(l.backend).(*redisBackend); ok && r.latency == nil {
+			r.latency = c.Metrics.RedisLatency
 		}
 	}
+}
 
-	// create new Redis client using opts parsed from config
-	external := redis.NewClient(proxy.Cache.RedisOpts)
-
-	// ping Redis to verify connectivity
-	_, err := external.Ping(context.Background()).Result()
-
-	return external, err
-}
-
-// initMetrics for the given proxy configuration
-func initMetrics(proxy config.Proxy) *Metrics {
-	cacheHits := promauto.NewCounter(prometheus.CounterOpts{
-		Namespace: config.Namespace,
-		Subsystem: Subsystem,
-		Name:      "hit_total",
-		ConstLabels: map[string]string{
-			"proxy": proxy.Name,
-		},
-		Help: "The total number of cache hits",
-	})
-
-	cacheMisses := promauto.NewCounter(prometheus.CounterOpts{
-		Namespace: config.Namespace,
-		Subsystem: Subsystem,
-		Name:      "miss_total",
-		ConstLabels: map[string]string{
-			"proxy": proxy.Name,
-		},
-		Help: "The total number of cache misses",
-	})
-
-	hitRate := promauto.NewCounterFunc(prometheus.CounterOpts{
-		Namespace: config.Namespace,
-		Subsystem: Subsystem,
-		Name:      "hit_rate",
-		ConstLabels: map[string]string{
-			"proxy": proxy.Name,
-		},
-		Help: "The rate of hits to misses",
-	}, func() float64 {
-		hits := util.GetMetricValue(cacheHits)
-		misses := util.GetMetricValue(cacheMisses)
-		return hits / (hits + misses)
-	})
-
-	return &Metrics{
-		CacheHits:   cacheHits,
-		CacheMisses: cacheMisses,
-		HitRate:     hitRate,
-	}
+// Proxy returns the proxy's current configuration, which is replaced rather
+// than modified on reload
+func (c *Cache) Proxy() *config.Proxy {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.proxy
+}
+
+// getLayers returns the current ordered cache levels
+func (c *Cache) getLayers() []*layer {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.layers
 }
 
 // Fetch will attempt to grab a tile by key from any of the cache layers,
-// populating higher layers of the cache if found.
-func (c *Cache) Fetch(key string, ctx *fiber.Ctx) *packet.TilePacket {
-	var cachedTile []byte
-	var err error
-	var hit string
-
-	// fetch from in-memory cache if enabled
-	if c.Proxy.Cache.MemEnabled {
-		cachedTile, err = c.internal.Get(key)
+// populating higher layers of the cache if found. Entries are kept alive
+// and promoted with the given TTLs.
+func (c *Cache) Fetch(key string, ttls TTLs, ctx *fiber.Ctx) *packet.Decoded {
+	c.recordHot(key)
+
+	start := time.Now()
+	tile, hit := c.lookup(ctx.Context(), key, ttls)
+	elapsed := time.Since(start)
+	c.lookups.observe(elapsed)
+	c.Metrics.CacheFetch.Observe(elapsed.Seconds())
+
+	if tile == nil {
+		return nil
+	}
+
+	ctx.Locals(str.LocalCacheStatus, hit)
+	return tile
+}
+
+// lookup fetches a tile by key from the first cache level holding it and
+// returns it along with the cache status of the hit
+func (c *Cache) lookup(ctx context.Context, key string, ttls TTLs) (*packet.Decoded, string) {
+	layers := c.getLayers()
+
+	for i, l := range layers {
+		cachedTile, err := l.backend.Get(ctx, key, ttls.of(l))
 		if err != nil {
-			if err == bigcache.ErrEntryNotFound {
-				util.DebugFlag("cache", str.CCache, str.DCacheMiss, key)
-			} else {
-				util.Error(str.CCache, str.ECacheFetch, key, err.Error())
-				return nil
-			}
+			util.Error(str.CCache, str.ECacheFetch, key, err.Error())
+			return nil, ""
 		}
 
-		hit = ":hit-i"
-	}
-
-	// try fetching from redis if not present in internal cache
-	if cachedTile == nil && c.Proxy.Cache.RedisEnabled {
-		var redisTile *redis.StringCmd
+		if cachedTile == nil {
+			atomic.AddUint64(&l.misses, 1)
+			c.Metrics.LayerMisses.WithLabelValues(l.backend.Name()).Inc()
+			util.DebugFlag("cache", str.CCache, str.DCacheMissLayer, l.backend.Name(), key)
+			continue
+		}
 
-		if c.Proxy.Cache.RedisTTLDuration > 0 {
-			// if TTL set, extend Redis TTL when we fetch a tile to prevent
-			// key expiry for tiles that are fetched periodically
-			redisTile = c.external.GetEx(ctx.Context(), key, c.Proxy.Cache.RedisTTLDuration)
+		// wrap bytes in TilePacket container, verifying the checksum unless
+		// in-memory entries are trusted
+		var tile *packet.Decoded
+		if c.Proxy().Cache.TrustMemory && l.backend.Name() == config.LayerMemory {
+			tile, err = packet.FromTrustedBytes(cachedTile, key)
 		} else {
-			// get and persist the key, meaning no expiry
-			redisTile = c.external.GetEx(ctx.Context(), key, 0)
+			tile, err = packet.FromBytes(cachedTile, key)
+		}
+		if err != nil {
+			// exit early and wipe cache if we cached a bad value
+			c.Metrics.InvalidPackets.Inc()
+			util.Error(str.CCache, str.ECacheFetch, key, err.Error())
+			err = c.Invalidate(key, ctx)
+			if err != nil {
+				util.Error(str.CCache, str.ECacheDelete, key, err.Error())
+			}
+			return nil, ""
 		}
 
-		if redisTile.Err() != nil {
-			if redisTile.Err() == redis.Nil {
-				// exit early if we don't have anything cached at any level
+		// wipe tiles that outlived the expiry recorded from upstream headers,
+		// and keep the entries of fresh tiles from outliving it on promotion
+		if expires, ok := tile.Expires(); ok {
+			remaining := time.Until(expires)
+			if remaining <= 0 {
+				atomic.AddUint64(&l.misses, 1)
+				c.Metrics.LayerMisses.WithLabelValues(l.backend.Name()).Inc()
 				c.Metrics.CacheMisses.Inc()
-				util.DebugFlag("cache", str.CCache, str.DCacheMissExt, key)
-				return nil
+				util.DebugFlag("cache", str.CCache, str.DCacheExpired, key)
+				if err = c.Invalidate(key, ctx); err != nil {
+					util.Error(str.CCache, str.ECacheDelete, key, err.Error())
+				}
+				return nil, ""
 			}
-			util.Error(str.CCache, str.ECacheFetch, key, err.Error())
-			return nil
+			ttls = c.CapTTLs(ttls, remaining)
 		}
 
-		// squeeze out the bytes from the redis response
-		cachedTile, err = redisTile.Bytes()
-		if err != nil {
-			util.Error(str.CCache, str.ECacheFetch, key, err.Error())
-			return nil
-		}
+		atomic.AddUint64(&l.hits, 1)
+		c.Metrics.LayerHits.WithLabelValues(l.backend.Name()).Inc()
+		c.Metrics.CacheHits.Inc()
+
+		util.DebugFlag("cache", str.CCache, str.DCacheHit, key, tile.TileDataSize())
+
+		// populate the levels above the one we hit, and keep entries alive
+		// in levels that expire entries from the time they were set
+		// TODO investigate alternative methods of preventing entry death
+		go c.promote(layers[:i+1], key, tile.TilePacket, ttls)
 
-		hit = ":hit-e"
+		return tile, l.hitLabel()
 	}
 
-	if cachedTile == nil {
-		// exit if we don't have anything cached at any level
-		c.Metrics.CacheMisses.Inc()
-		util.DebugFlag("cache", str.CCache, str.DCacheMissExt, key)
-		return nil
+	// exit if we don't have anything cached at any level
+	c.Metrics.CacheMisses.Inc()
+	util.DebugFlag("cache", str.CCache, str.DCacheMiss, key)
+	return nil, ""
+}
+
+// promote sets a tile found in the last of the given cache levels in every
+// level above it, and resets it in the level it was found in if that level
+// must rewrite entries to keep them alive
+func (c *Cache) promote(layers []*layer, key string, tile packet.TilePacket, ttls TTLs) {
+	hit := len(layers) - 1
+
+	for i, l := range layers {
+		if i == hit && !l.refresh {
+			continue
+		}
+
+		c.setLayer(l, key, tile, ttls.of(l))
 	}
+}
 
-	ctx.Locals(str.LocalCacheStatus, hit)
-	c.Metrics.CacheHits.Inc()
+// Peek returns the tile for the given key from the highest cache level it's
+// present in without touching metrics, expiries or populating higher cache
+// levels
+func (c *Cache) Peek(key string, ctx context.Context) *packet.Decoded {
+	for _, l := range c.getLayers() {
+		cachedTile, _ := l.backend.Get(ctx, key, KeepTTL)
+		if cachedTile == nil {
+			continue
+		}
 
-	// wrap bytes in TilePacket container
-	tile, err := packet.FromBytes(cachedTile, key)
-	if err != nil {
-		// exit early and wipe cache if we cached a bad value
-		util.Error(str.CCache, str.ECacheFetch, key, err.Error())
-		err = c.Invalidate(key, ctx.Context())
+		tile, err := packet.FromBytes(cachedTile, key)
 		if err != nil {
-			util.Error(str.CCache, str.ECacheDelete, key, err.Error())
+			return nil
 		}
-		return nil
+
+		return tile
 	}
 
-	util.DebugFlag("cache", str.CCache, str.DCacheHit, key, tile.TileDataSize())
+	return nil
+}
 
-	// extend internal cache TTL (keeping entry alive) by resetting the entry
-	// this also sets internal cache entries if we find a tile in redis but not internally
-	// TODO investigate alternative methods of preventing entry death
-	go c.Set(key, *tile, true)
+// EncodeSet will encode tile data and metadata into a TilePacket and then set
+// the cache entry to the specified key with the given TTLs, indexing it by
+// the given tags
+func (c *Cache) EncodeSet(key string, tileData []byte, headers map[string]string, meta packet.Meta,
+	ttls TTLs, tags []string) {
+	c.Set(key, c.encode(tileData, headers, meta), ttls, tags)
+}
 
-	return tile
+// EncodeSetWait is EncodeSet, only returning once the tile is stored in
+// every cache level, with the first error storing it
+func (c *Cache) EncodeSetWait(key string, tileData []byte, headers map[string]string, meta packet.Meta,
+	ttls TTLs, tags []string) error {
+	return c.SetWait(key, c.encode(tileData, headers, meta), ttls, tags)
 }
 
-// EncodeSet will encode tile data into a TilePacket and then set the cache
-// entry to the specified key
-func (c *Cache) EncodeSet(key string, tileData []byte, headers map[string]string) {
-	tilePacket := packet.Encode(tileData, headers)
-	c.Set(key, tilePacket)
+// encode tile data and metadata into a TilePacket using the proxy's
+// checksum, compression and deduplication settings
+func (c *Cache) encode(tileData []byte, headers map[string]string, meta packet.Meta) packet.TilePacket {
+	meta.Checksum, _ = packet.ParseChecksum(c.Proxy().Cache.Checksum)
+
+	// compress large tiles that upstream didn't already encode
+	if len(tileData) >= c.Proxy().Cache.CompressionThreshold && !encoded(headers) {
+		meta.Codec, _ = packet.ParseCodec(c.Proxy().Cache.Compression)
+	}
+
+	// identify tile data by content so deduplicating levels store it once
+	if c.Proxy().Cache.Dedup {
+		meta.ContentHash = packet.ContentHash(tileData)
+	}
+
+	return packet.EncodeMeta(tileData, headers, meta)
+}
+
+// encoded returns true if the headers give a content encoding other than identity
+func encoded(headers map[string]string) bool {
+	for key, val := range headers {
+		if strings.EqualFold(key, fiber.HeaderContentEncoding) && val != "" && val != "identity" {
+			return true
+		}
+	}
+	return false
 }
 
-// Set the tile in all cache levels with the configured TTLs
-func (c *Cache) Set(key string, tile packet.TilePacket, internalOnly ...bool) {
+// Set the tile in all cache levels with the given TTLs, falling back to the
+// TTLs configured for each level, and index it by the given tags. Local
+// cache levels are set immediately and shared levels in the background,
+// unless internalOnly is given, in which case shared levels are skipped.
+func (c *Cache) Set(key string, tile packet.TilePacket, ttls TTLs, tags []string, internalOnly ...bool) {
+	_ = c.set(key, tile, ttls, tags, len(internalOnly) > 0 && internalOnly[0], false)
+}
+
+// SetWait is Set, only returning once shared cache levels are set and the
+// tile is indexed by its tags, with the first error setting it. Tiles are
+// set this way before telling peers they can read them from shared levels.
+func (c *Cache) SetWait(key string, tile packet.TilePacket, ttls TTLs, tags []string) error {
+	return c.set(key, tile, ttls, tags, false, true)
+}
+
+// set the tile in all cache levels, skipping shared levels if requested and
+// otherwise setting them in the background unless asked to wait on them
+func (c *Cache) set(key string, tile packet.TilePacket, ttls TTLs, tags []string, skipShared, wait bool) error {
 	util.DebugFlag("cache", str.CCache, str.DCacheSet, key, len(tile))
 
-	// set in external cache if enabled and allowed
-	if (len(internalOnly) == 0 || !internalOnly[0]) && c.Proxy.Cache.RedisEnabled {
+	if len(tags) > 0 && !c.tags.add(key, tags) {
+		util.DebugFlag("cache", str.CCache, str.DCacheTagIndexFull, key)
+	}
+
+	layers := c.getLayers()
+	wg := sync.WaitGroup{}
+	errs := make(chan error, len(layers)+1)
+
+	// run a shared write in the background, collecting its error if waiting
+	background := func(write func() error) {
+		if !wait {
+			go func() { _ = write() }()
+			return
+		}
+		wg.Add(1)
 		go func() {
-			status := c.external.Set(context.Background(), key,
-				tile.Raw(), c.Proxy.Cache.RedisTTLDuration)
-			if status.Err() != nil {
-				util.Error(str.CCache, str.ECacheSet, key, status.Err())
-			}
+			defer wg.Done()
+			errs <- write()
 		}()
 	}
 
-	// set in the in-memory cache if enabled
-	if c.Proxy.Cache.MemEnabled {
-		err := c.internal.Set(key, tile)
+	for _, l := range layers {
+		l := l
+		if l.shared {
+			if !skipShared {
+				background(func() error {
+					return c.setLayer(l, key, tile, ttls.of(l))
+				})
+			}
+			continue
+		}
+
+		errs <- c.setLayer(l, key, tile, ttls.of(l))
+	}
+
+	if len(tags) > 0 && !skipShared {
+		background(func() error {
+			return c.tagShared(context.Background(), key, tags)
+		})
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
 		if err != nil {
-			util.Error(str.CCache, str.ECacheSet, key, err.Error())
+			return err
 		}
 	}
+	return nil
+}
+
+// setLayer sets the tile in a single cache level, logging any failure
+func (c *Cache) setLayer(l *layer, key string, tile packet.TilePacket, ttl time.Duration) error {
+	err := l.backend.Set(context.Background(), key, tile.Raw(), ttl)
+	if err != nil {
+		util.Error(str.CCache, str.ECacheSet, key, err.Error())
+	}
+	return err
 }
 
 // Invalidate a tile by key from all cache levels
 func (c *Cache) Invalidate(key string, ctx context.Context) error {
-	// invalidate from in-memory cache if enabled
-	if c.Proxy.Cache.MemEnabled {
-		err := c.internal.Delete(key)
-		if err != nil && err != bigcache.ErrEntryNotFound {
+	c.tags.remove(key)
+
+	for _, l := range c.getLayers() {
+		if err := l.backend.Delete(ctx, key); err != nil {
 			return err
 		}
 	}
 
-	if c.Proxy.Cache.RedisEnabled {
-		status := c.external.Del(ctx, key)
-		if status.Err() != nil {
-			return status.Err()
+	return nil
+}
+
+// InvalidateInternal removes a tile by key from the cache levels local to
+// this instance only, leaving shared levels untouched
+func (c *Cache) InvalidateInternal(key string) error {
+	c.tags.remove(key)
+
+	for _, l := range c.getLayers() {
+		if l.shared {
+			continue
+		}
+
+		if err := l.backend.Delete(context.Background(), key); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-// FlushInternal flushes the internal bigcache instance
+// FlushInternal flushes every cache level local to this instance
 func (c *Cache) FlushInternal() error {
-	if c.Proxy.Cache.MemEnabled {
-		return c.internal.Reset()
+	c.tags.reset()
+
+	for _, l := range c.getLayers() {
+		if l.shared {
+			continue
+		}
+
+		if err := l.backend.Flush(context.Background()); err != nil {
+			return err
+		}
 	}
+
 	return nil
 }
 
-// StatsInternal returns stats about the internal bigcache instance
-func (c *Cache) StatsInternal() bigcache.Stats {
-	if c.Proxy.Cache.MemEnabled {
-		return c.internal.Stats()
+// FlushExternal flushes every cache level shared between instances, deleting
+// every key matching the proxy's cache key template from Redis and every
+// object under the proxy's key prefix from object storage
+func (c *Cache) FlushExternal(ctx context.Context) error {
+	for _, l := range c.getLayers() {
+		if !l.shared {
+			continue
+		}
+
+		if err := l.backend.Flush(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Shared returns true if any of the cache levels are shared between instances
+func (c *Cache) Shared() bool {
+	for _, l := range c.getLayers() {
+		if l.shared {
+			return true
+		}
+	}
+	return false
+}
+
+// TTLs returns the TTLs of the rule applying to tiles at the given zoom level
+// of the given dynamic endpoint, or nil if no rule applies, in which case
+// the TTLs configured for each cache level are used
+func (c *Cache) TTLs(zoom int, endpoint string) TTLs {
+	rule := c.Proxy().Cache.TTLRule(zoom, endpoint)
+	if rule == nil {
+		return nil
+	}
+
+	ttls := make(TTLs)
+	if rule.MemTTL != "" {
+		ttls[config.LayerMemory] = rule.MemTTLDuration
+	}
+	if rule.RedisTTL != "" {
+		ttls[config.LayerRedis] = rule.RedisTTLDuration
 	}
-	return bigcache.Stats{}
+
+	return ttls
+}
+
+// CapTTLs returns the given TTLs of every cache level capped at max, treating
+// TTLs of zero, which never expire, as longer than max
+func (c *Cache) CapTTLs(ttls TTLs, max time.Duration) TTLs {
+	capped := make(TTLs)
+	for _, l := range c.getLayers() {
+		ttl := ttls.of(l)
+		if ttl <= 0 || ttl > max {
+			ttl = max
+		}
+		capped[l.backend.Name()] = ttl
+	}
+	return capped
+}
+
+// maxTTL returns the longest TTL of the cache level and the TTL rules that
+// apply to it, or zero if the level's entries never expire
+func (c *Cache) maxTTL(l *layer) time.Duration {
+	if l.ttl <= 0 {
+		return l.ttl
+	}
+
+	switch l.backend.Name() {
+	case config.LayerMemory:
+		return c.Proxy().Cache.MaxMemTTL()
+	case config.LayerRedis:
+		return c.Proxy().Cache.MaxRedisTTL()
+	}
+	return l.ttl
+}
+
+// Redis returns the client of the proxy's Redis cache level, or nil if the
+// proxy doesn't use Redis
+func (c *Cache) Redis() redis.UniversalClient {
+	for _, l := range c.getLayers() {
+		if backend, ok := unwrap(l.backend).(*redisBackend); ok {
+			return backend.client
+		}
+	}
+	return nil
+}
+
+// Stats returns usage stats for each cache level, in order
+func (c *Cache) Stats() []BackendStats {
+	layers := c.getLayers()
+
+	stats := make([]BackendStats, 0, len(layers))
+	for _, l := range layers {
+		stats = append(stats, l.stats())
+	}
+
+	return stats
 }
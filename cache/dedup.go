@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/packet"
+)
+
+const (
+	// blobPrefix prefixes the keys of tile data stored by content hash
+	blobPrefix = "lod:blob:"
+
+	// blobTTL is the shortest TTL of tile data, refreshed whenever a
+	// reference to it is set, so that tile data orphaned by invalidations
+	// is eventually removed
+	blobTTL = 7 * 24 * time.Hour
+
+	// dedupMinSize is the smallest tile data worth storing by content hash,
+	// below which the extra entry costs more than deduplication saves
+	dedupMinSize = 64
+)
+
+// dedupBackend wraps a cache level to store the tile data of packets with a
+// content hash once per hash, with each cache key holding a reference to
+// it. Tile data is stored with a TTL no shorter than any reference's, so it
+// outlives every reference set along with it, and its expiry is only ever
+// extended. References whose tile data expired or was evicted are treated
+// as misses and removed, so tile data is never deleted explicitly.
+type dedupBackend struct {
+	Backend
+	prefix string        // prefix of the proxy's tile data keys
+	ttl    time.Duration // TTL of the proxy's tile data
+}
+
+// withDedup wraps the backend to deduplicate the proxy's tile data, if
+// enabled for the proxy, given the longest TTL of the level's entries
+func withDedup(proxy config.Proxy, backend Backend, maxTTL time.Duration) Backend {
+	if !proxy.Cache.Dedup {
+		return backend
+	}
+
+	ttl := blobTTL
+	if maxTTL > ttl {
+		ttl = maxTTL
+	}
+
+	return &dedupBackend{
+		Backend: backend,
+		prefix:  blobPrefix + proxy.Name + ":",
+		ttl:     ttl,
+	}
+}
+
+// blobKey returns the key of tile data with the given content hash and codec
+func (d *dedupBackend) blobKey(hash []byte, codec packet.Codec) string {
+	return fmt.Sprintf("%s%d:%x", d.prefix, codec, hash)
+}
+
+// Get returns the entry for the given key, joining references with the
+// tile data they point to. Only the reference's expiry is extended by the
+// TTL, since tile data is shared by references with other TTLs.
+func (d *dedupBackend) Get(ctx context.Context, key string, ttl time.Duration) ([]byte, error) {
+	data, err := d.Backend.Get(ctx, key, ttl)
+	if err != nil || data == nil {
+		return data, err
+	}
+
+	return d.resolve(ctx, key, data)
+}
+
+// resolve joins a reference with the tile data it points to, returning
+// other entries as is. References to missing tile data are removed.
+func (d *dedupBackend) resolve(ctx context.Context, key string, data []byte) ([]byte, error) {
+	hash, codec, ok := packet.Referenced(data)
+	if !ok {
+		return data, nil
+	}
+
+	tile, err := d.Backend.Get(ctx, d.blobKey(hash, codec), KeepTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	if tile == nil {
+		return nil, d.Backend.Delete(ctx, key)
+	}
+
+	return packet.Join(data, tile), nil
+}
+
+// Set stores the tile data of the entry by content hash, before storing a
+// reference to it under the given key, so that references never point to
+// tile data that was never stored. Entries without a content hash or with
+// little tile data are stored as is.
+func (d *dedupBackend) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	ref, tile, ok := packet.TilePacket(data).Split()
+	if !ok || len(tile) < dedupMinSize {
+		return d.Backend.Set(ctx, key, data, ttl)
+	}
+
+	hash, codec, _ := packet.Referenced(ref)
+	if err := d.setBlob(ctx, d.blobKey(hash, codec), tile); err != nil {
+		return err
+	}
+
+	return d.Backend.Set(ctx, key, ref, ttl)
+}
+
+// setBlob stores tile data unless it's already stored, in which case Redis
+// refreshes its expiry instead, which never shortens it since tile data is
+// always stored with the same TTL. Other levels keep the existing tile data
+// as is, since references outliving it are treated as misses.
+func (d *dedupBackend) setBlob(ctx context.Context, key string, tile []byte) error {
+	if r, ok := d.Backend.(*redisBackend); ok {
+		return r.setOrExtend(ctx, key, tile, d.ttl)
+	}
+
+	existing, err := d.Backend.Get(ctx, key, KeepTTL)
+	if err != nil || existing != nil {
+		return err
+	}
+
+	return d.Backend.Set(ctx, key, tile, d.ttl)
+}
+
+// Flush removes every entry of the proxy, along with its tile data
+func (d *dedupBackend) Flush(ctx context.Context) error {
+	if err := d.Backend.Flush(ctx); err != nil {
+		return err
+	}
+
+	// the in-memory level is flushed entirely, but Redis must be scanned
+	if r, ok := d.Backend.(*redisBackend); ok {
+		return r.deleteMatching(ctx, d.prefix+"*", nil)
+	}
+
+	return nil
+}
+
+// Scan calls fn with batches of entries of the wrapped level, joining
+// references with the tile data they point to and skipping the tile data
+// itself
+func (d *dedupBackend) Scan(ctx context.Context, cursor string, count int, filter Filter,
+	fn func(batch []Entry, next string) error) error {
+	scanner, ok := d.Backend.(Scanner)
+	if !ok {
+		return ErrNotScannable{Layer: d.Name()}
+	}
+
+	entries := func(key string) bool {
+		return !strings.HasPrefix(key, blobPrefix) && filter.accept(key)
+	}
+
+	return scanner.Scan(ctx, cursor, count, entries, func(batch []Entry, next string) error {
+		resolved := batch[:0]
+		for _, entry := range batch {
+			data, err := d.resolve(ctx, entry.Key, entry.Data)
+			if err != nil {
+				return err
+			}
+
+			// skip references whose tile data is gone
+			if data == nil {
+				continue
+			}
+
+			entry.Data = data
+			resolved = append(resolved, entry)
+		}
+
+		return fn(resolved, next)
+	})
+}
+
+// unwrap returns the cache level wrapped by a deduplicating level
+func unwrap(backend Backend) Backend {
+	if d, ok := backend.(*dedupBackend); ok {
+		return d.Backend
+	}
+	return backend
+}
@@ -0,0 +1,308 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dechristopher/lod/config"
+)
+
+// tmpPrefix prefixes in-progress entry writes, which are discarded on startup
+const tmpPrefix = ".tmp-"
+
+// diskBackend is a size-capped LRU cache level of tile packets stored as
+// individual files on local disk. Entries are indexed in memory and the
+// index is rebuilt from the files present on startup, so the cache survives
+// restarts.
+type diskBackend struct {
+	dir      string                   // directory entries are stored in
+	capacity int64                    // maximum total size of entries in bytes
+	ttl      time.Duration            // TTL of entries found on startup, 0 for none
+	lock     sync.Mutex               // guards the fields below
+	size     int64                    // current total size of entries in bytes
+	entries  map[string]*list.Element // entry file names to their LRU element
+	lru      *list.List               // entries, most recently used first
+}
+
+// diskEntry is the index record of a single on-disk cache entry
+type diskEntry struct {
+	name    string    // hashed file name of the entry
+	size    int64     // size of the entry in bytes
+	written time.Time // time the entry was written
+	expires time.Time // time the entry expires, zero for never
+}
+
+// newDiskBackend initializes an on-disk cache instance from proxy configuration
+func newDiskBackend(proxy config.Proxy) (*diskBackend, error) {
+	d := &diskBackend{
+		dir:      filepath.Join(proxy.Cache.DiskPath, proxy.Name),
+		capacity: int64(proxy.Cache.DiskCap) * OneMB,
+		ttl:      proxy.Cache.DiskTTLDuration,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+
+	if err := os.MkdirAll(d.dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// load rebuilds the entry index from the files already on disk, ordering
+// entries by modification time as an approximation of their last use
+func (d *diskBackend) load() error {
+	found := make([]*diskEntry, 0)
+
+	err := filepath.WalkDir(d.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		// remove writes interrupted by a previous shutdown
+		if strings.HasPrefix(entry.Name(), tmpPrefix) {
+			return os.Remove(path)
+		}
+
+		// ignore anything that isn't a cache entry
+		if len(entry.Name()) != sha256.Size*2 {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		found = append(found, &diskEntry{
+			name:    entry.Name(),
+			size:    info.Size(),
+			written: info.ModTime(),
+			expires: expiry(info.ModTime(), d.ttl),
+		})
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].written.Before(found[j].written)
+	})
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, entry := range found {
+		d.entries[entry.name] = d.lru.PushFront(entry)
+		d.size += entry.size
+	}
+
+	d.evict()
+	return nil
+}
+
+// Name returns the name of the cache level
+func (d *diskBackend) Name() string {
+	return config.LayerDisk
+}
+
+// Get returns the entry for the given key, or nil if not present or expired
+func (d *diskBackend) Get(_ context.Context, key string, _ time.Duration) ([]byte, error) {
+	name := d.fileName(key)
+
+	d.lock.Lock()
+	element, ok := d.entries[name]
+	if !ok {
+		d.lock.Unlock()
+		return nil, nil
+	}
+
+	if expired(element.Value.(*diskEntry).expires) {
+		d.remove(element)
+		d.lock.Unlock()
+		return nil, nil
+	}
+
+	d.lru.MoveToFront(element)
+	d.lock.Unlock()
+
+	data, err := os.ReadFile(d.path(name))
+	if err != nil {
+		// drop the entry if its file was removed from under us
+		if os.IsNotExist(err) {
+			return nil, d.Delete(context.Background(), key)
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Set writes the entry for the given key, evicting the least recently used
+// entries if the cache grows over capacity. Entries larger than the whole
+// cache are not stored.
+func (d *diskBackend) Set(_ context.Context, key string, data []byte, ttl time.Duration) error {
+	size := int64(len(data))
+	if size > d.capacity {
+		return nil
+	}
+
+	name := d.fileName(key)
+	path := d.path(name)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// write to a temporary file first so readers never see partial entries
+	tmp, err := os.CreateTemp(d.dir, tmpPrefix)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	if element, ok := d.entries[name]; ok {
+		d.size -= element.Value.(*diskEntry).size
+		d.lru.Remove(element)
+	}
+
+	now := time.Now()
+	d.entries[name] = d.lru.PushFront(&diskEntry{
+		name:    name,
+		size:    size,
+		written: now,
+		expires: expiry(now, ttl),
+	})
+	d.size += size
+
+	d.evict()
+	return nil
+}
+
+// Delete removes the entry for the given key if present
+func (d *diskBackend) Delete(_ context.Context, key string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if element, ok := d.entries[d.fileName(key)]; ok {
+		d.remove(element)
+	}
+
+	return nil
+}
+
+// Flush removes every entry from the cache
+func (d *diskBackend) Flush(_ context.Context) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.entries = make(map[string]*list.Element)
+	d.lru.Init()
+	d.size = 0
+
+	if err := os.RemoveAll(d.dir); err != nil {
+		return err
+	}
+
+	return os.MkdirAll(d.dir, 0o755)
+}
+
+// Stats returns usage stats about the cache
+func (d *diskBackend) Stats() BackendStats {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return BackendStats{
+		Entries: int64(len(d.entries)),
+		Size:    d.size,
+		Cap:     d.capacity,
+	}
+}
+
+// Close is a no-op, entries are kept on disk for the next startup
+func (d *diskBackend) Close() error {
+	return nil
+}
+
+// evict removes least recently used entries until the cache is within
+// capacity, must be called with the lock held
+func (d *diskBackend) evict() {
+	for d.size > d.capacity {
+		oldest := d.lru.Back()
+		if oldest == nil {
+			return
+		}
+		d.remove(oldest)
+	}
+}
+
+// remove deletes an entry and its file, must be called with the lock held
+func (d *diskBackend) remove(element *list.Element) {
+	entry := element.Value.(*diskEntry)
+
+	d.lru.Remove(element)
+	delete(d.entries, entry.name)
+	d.size -= entry.size
+
+	_ = os.Remove(d.path(entry.name))
+}
+
+// expiry returns the expiry time of an entry written at the given time with
+// the given TTL, or the zero time if the entry never expires
+func expiry(written time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return written.Add(ttl)
+}
+
+// expired returns true if the given expiry time has passed
+func expired(expires time.Time) bool {
+	return !expires.IsZero() && time.Now().After(expires)
+}
+
+// fileName returns the hashed file name of the entry for the given key,
+// keeping arbitrary cache keys safe for use in file paths
+func (d *diskBackend) fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// path returns the full path of the named entry, fanned out into
+// subdirectories to keep directory sizes manageable
+func (d *diskBackend) path(name string) string {
+	return filepath.Join(d.dir, name[:2], name)
+}
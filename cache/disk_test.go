@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dechristopher/lod/config"
+)
+
+// newTestDisk creates an on-disk cache in the given directory
+func newTestDisk(t *testing.T, dir string, capacity int64) *diskBackend {
+	d := &diskBackend{
+		dir:      dir,
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+
+	if err := d.load(); err != nil {
+		t.Fatalf("failed to load disk cache, error=%s", err.Error())
+	}
+
+	return d
+}
+
+// TestDiskEviction will test that the least recently used entries are
+// evicted once the on-disk cache grows over capacity
+func TestDiskEviction(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDisk(t, t.TempDir(), 10)
+	entry := []byte("1234")
+
+	for _, key := range []string{"a", "b"} {
+		if err := d.Set(ctx, key, entry, 0); err != nil {
+			t.Fatalf("failed to set %s, error=%s", key, err.Error())
+		}
+	}
+
+	// touch a so that b becomes the least recently used entry
+	if data, _ := d.Get(ctx, "a", 0); !bytes.Equal(data, entry) {
+		t.Fatalf("expected entry for a, got %v", data)
+	}
+
+	if err := d.Set(ctx, "c", entry, 0); err != nil {
+		t.Fatalf("failed to set c, error=%s", err.Error())
+	}
+
+	if data, _ := d.Get(ctx, "b", 0); data != nil {
+		t.Errorf("expected b to be evicted")
+	}
+
+	for _, key := range []string{"a", "c"} {
+		if data, _ := d.Get(ctx, key, 0); !bytes.Equal(data, entry) {
+			t.Errorf("expected entry for %s to remain", key)
+		}
+	}
+
+	if stats := d.Stats(); stats.Entries != 2 || stats.Size != 8 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+// TestDiskTTL will test that expired entries are not returned
+func TestDiskTTL(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDisk(t, t.TempDir(), 10)
+
+	if err := d.Set(ctx, "a", []byte("1234"), time.Millisecond); err != nil {
+		t.Fatalf("failed to set a, error=%s", err.Error())
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if data, _ := d.Get(ctx, "a", 0); data != nil {
+		t.Errorf("expected a to have expired")
+	}
+
+	if stats := d.Stats(); stats.Entries != 0 || stats.Size != 0 {
+		t.Errorf("expected expired entry to be removed, got %+v", stats)
+	}
+}
+
+// TestDiskReload will test that entries survive the cache being reopened
+func TestDiskReload(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	entry := []byte("1234")
+
+	if err := newTestDisk(t, dir, 10).Set(ctx, "a", entry, 0); err != nil {
+		t.Fatalf("failed to set a, error=%s", err.Error())
+	}
+
+	d := newTestDisk(t, dir, 10)
+	if data, _ := d.Get(ctx, "a", 0); !bytes.Equal(data, entry) {
+		t.Errorf("expected entry for a after reload, got %v", data)
+	}
+}
+
+// TestDiskLayerReuse will test that the on-disk cache level is kept across
+// configuration reloads only while its settings are unchanged
+func TestDiskLayerReuse(t *testing.T) {
+	proxy := config.Proxy{Name: "test", Cache: config.Cache{
+		Layers:          []string{config.LayerDisk},
+		DiskPath:        t.TempDir(),
+		DiskCap:         1,
+		DiskTTLDuration: time.Hour,
+	}}
+
+	layers, err := buildLayers(proxy, nil)
+	if err != nil {
+		t.Fatalf("failed to build layers, error=%s", err.Error())
+	}
+
+	reloaded, err := buildLayers(proxy, layers)
+	if err != nil {
+		t.Fatalf("failed to rebuild layers, error=%s", err.Error())
+	}
+	if reloaded[0] != layers[0] {
+		t.Error("expected unchanged disk level to be kept")
+	}
+
+	proxy.Cache.DiskTTLDuration = time.Minute
+	changed, err := buildLayers(proxy, reloaded)
+	if err != nil {
+		t.Fatalf("failed to rebuild layers, error=%s", err.Error())
+	}
+	if changed[0] == reloaded[0] || changed[0].ttl != time.Minute {
+		t.Error("expected disk level with changed TTL to be replaced")
+	}
+}
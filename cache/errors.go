@@ -14,6 +14,18 @@ func (e ErrBuildInstance) Error() string {
 	return fmt.Sprintf("config: failed to init caches for proxy '%s', got error %s", e.Name, e.Err.Error())
 }
 
+// ErrRegisterMetrics is an error struct for errors
+// encountered registering a proxy's metrics
+type ErrRegisterMetrics struct {
+	Name string
+	Err  error
+}
+
+// Error returns the string representation of ErrRegisterMetrics
+func (e ErrRegisterMetrics) Error() string {
+	return fmt.Sprintf("cache: failed to register metrics for '%s', got error %s", e.Name, e.Err.Error())
+}
+
 // ErrInitInternalCache is an error struct for errors
 // encountered during the internal cache initialization
 type ErrInitInternalCache struct {
@@ -26,6 +38,18 @@ func (e ErrInitInternalCache) Error() string {
 	return fmt.Sprintf("cache: failed to init internal memory cache for '%s', got error %s", e.Name, e.Err.Error())
 }
 
+// ErrInitDiskCache is an error struct for errors
+// encountered during the on-disk cache initialization
+type ErrInitDiskCache struct {
+	Name string
+	Err  error
+}
+
+// Error returns the string representation of ErrInitDiskCache
+func (e ErrInitDiskCache) Error() string {
+	return fmt.Sprintf("cache: failed to init on-disk cache for '%s', got error %s", e.Name, e.Err.Error())
+}
+
 // ErrInitExternalCache is an error struct for errors
 // encountered during the external cache initialization
 type ErrInitExternalCache struct {
@@ -37,3 +61,111 @@ type ErrInitExternalCache struct {
 func (e ErrInitExternalCache) Error() string {
 	return fmt.Sprintf("cache: failed to init external cache for '%s', got error %s", e.Name, e.Err.Error())
 }
+
+// ErrInitMemcachedCache is an error struct for errors
+// encountered during the memcached cache initialization
+type ErrInitMemcachedCache struct {
+	Name string
+	Err  error
+}
+
+// Error returns the string representation of ErrInitMemcachedCache
+func (e ErrInitMemcachedCache) Error() string {
+	return fmt.Sprintf("cache: failed to init memcached cache for '%s', got error %s", e.Name, e.Err.Error())
+}
+
+// ErrInitObjectCache is an error struct for errors
+// encountered during the object storage cache initialization
+type ErrInitObjectCache struct {
+	Name string
+	Err  error
+}
+
+// Error returns the string representation of ErrInitObjectCache
+func (e ErrInitObjectCache) Error() string {
+	return fmt.Sprintf("cache: failed to init object storage cache for '%s', got error %s", e.Name, e.Err.Error())
+}
+
+// ErrNoRedis is an error struct for operations that
+// require a Redis cache level on a proxy without one
+type ErrNoRedis struct {
+	Name string
+}
+
+// Error returns the string representation of ErrNoRedis
+func (e ErrNoRedis) Error() string {
+	return fmt.Sprintf("cache: proxy '%s' has no redis cache level", e.Name)
+}
+
+// ErrUnknownLayer is an error struct for a cache level
+// without a backend implementation
+type ErrUnknownLayer struct {
+	Name  string
+	Layer string
+}
+
+// Error returns the string representation of ErrUnknownLayer
+func (e ErrUnknownLayer) Error() string {
+	return fmt.Sprintf("cache: unknown cache layer '%s' for '%s'", e.Layer, e.Name)
+}
+
+// ErrLayerNotConfigured is an error struct for operations
+// on a cache level the proxy doesn't have enabled
+type ErrLayerNotConfigured struct {
+	Name  string
+	Layer string
+}
+
+// Error returns the string representation of ErrLayerNotConfigured
+func (e ErrLayerNotConfigured) Error() string {
+	return fmt.Sprintf("cache: proxy '%s' has no %s cache level", e.Name, e.Layer)
+}
+
+// ErrNotScannable is an error struct for exports from
+// a cache level that can't enumerate its entries
+type ErrNotScannable struct {
+	Name  string
+	Layer string
+}
+
+// Error returns the string representation of ErrNotScannable
+func (e ErrNotScannable) Error() string {
+	return fmt.Sprintf("cache: %s cache level of '%s' can't enumerate its entries", e.Layer, e.Name)
+}
+
+// ErrInvalidPattern is an error struct for key
+// browser operations given a malformed glob pattern
+type ErrInvalidPattern struct {
+	Pattern string
+}
+
+// Error returns the string representation of ErrInvalidPattern
+func (e ErrInvalidPattern) Error() string {
+	return fmt.Sprintf("cache: invalid key pattern '%s'", e.Pattern)
+}
+
+// ErrUnknownGeneration is an error struct for cache keys
+// that can't be built because no namespace generation is known
+type ErrUnknownGeneration struct {
+	Name     string
+	Endpoint string
+}
+
+// Error returns the string representation of ErrUnknownGeneration
+func (e ErrUnknownGeneration) Error() string {
+	if e.Endpoint == "" {
+		return fmt.Sprintf("cache: proxy '%s' has no known generation", e.Name)
+	}
+	return fmt.Sprintf("cache: proxy '%s' has no known generation for endpoint '%s'", e.Name, e.Endpoint)
+}
+
+// ErrInvalidCursor is an error struct for scans
+// resumed from a malformed or outdated cursor
+type ErrInvalidCursor struct {
+	Cursor string
+}
+
+// Error returns the string representation of ErrInvalidCursor
+func (e ErrInvalidCursor) Error() string {
+	return fmt.Sprintf("cache: invalid scan cursor '%s'", e.Cursor)
+}
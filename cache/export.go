@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/dechristopher/lod/archive"
+	"github.com/dechristopher/lod/packet"
+)
+
+// progressInterval is the number of entries transferred between progress reports
+const progressInterval = 10000
+
+// Transfer reports the progress of an export or import
+type Transfer struct {
+	Entries int    `json:"entries"` // number of entries exported or imported
+	Expired int    `json:"expired"` // number of expired archive records skipped on import
+	Next    int    `json:"next"`    // archive record to resume an import from
+	Cursor  string `json:"cursor"`  // scan cursor to resume an export from, empty once complete
+}
+
+// layerNamed returns the proxy's cache level with the given name, if enabled
+func (c *Cache) layerNamed(name string) (*layer, error) {
+	for _, l := range c.getLayers() {
+		if l.backend.Name() == name {
+			return l, nil
+		}
+	}
+	return nil, ErrLayerNotConfigured{Name: c.Proxy().Name, Layer: name}
+}
+
+// scanner returns the Scanner of the named cache level
+func (c *Cache) scanner(layerName string) (Scanner, error) {
+	l, err := c.layerNamed(layerName)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner, ok := l.backend.(Scanner)
+	if !ok {
+		return nil, ErrNotScannable{Name: c.Proxy().Name, Layer: layerName}
+	}
+
+	return scanner, nil
+}
+
+// Exportable returns an error if the named cache level can't be exported
+func (c *Cache) Exportable(layerName string) error {
+	_, err := c.scanner(layerName)
+	return err
+}
+
+// Importable returns an error if the named cache level can't be imported to
+func (c *Cache) Importable(layerName string) error {
+	_, err := c.layerNamed(layerName)
+	return err
+}
+
+// Export writes every unexpired entry of the named cache level to the
+// archive, starting from the given scan cursor, and reports progress
+// periodically. Records hold the cursor their scan batch started from. On
+// failure, the export can be resumed from the returned Transfer's Cursor,
+// which follows the last batch written entirely.
+func (c *Cache) Export(ctx context.Context, layerName, from string, w *archive.Writer,
+	progress func(Transfer)) (Transfer, error) {
+	result := Transfer{Cursor: from}
+
+	scanner, err := c.scanner(layerName)
+	if err != nil {
+		return result, err
+	}
+
+	err = scanner.Scan(ctx, from, 0, nil, func(batch []Entry, next string) error {
+		for _, entry := range batch {
+			err := w.Write(archive.Record{
+				Cursor:  result.Cursor,
+				Key:     entry.Key,
+				Packet:  entry.Data,
+				Expires: entry.Expires,
+			})
+			if err != nil {
+				return err
+			}
+
+			result.Entries++
+			if result.Entries%progressInterval == 0 {
+				progress(result)
+				if err = w.Flush(); err != nil {
+					return err
+				}
+			}
+		}
+
+		result.Cursor = next
+		return nil
+	})
+
+	if errFlush := w.Flush(); err == nil {
+		err = errFlush
+	}
+
+	return result, err
+}
+
+// Import stores every unexpired record of the archive in the named cache
+// level with the lesser of its remaining TTL and the longest TTL the level's
+// TTL rules allow, skipping the given number of records, and reports
+// progress periodically. On failure, the import can be resumed by skipping
+// the returned Transfer's Next number of records.
+func (c *Cache) Import(ctx context.Context, layerName string, r *archive.Reader, skip int,
+	progress func(Transfer)) (Transfer, error) {
+	result := Transfer{Next: skip}
+
+	l, err := c.layerNamed(layerName)
+	if err != nil {
+		return result, err
+	}
+
+	// keys can't be mapped back to tiles and the rule applying to them, so
+	// records are capped at the longest TTL of any rule
+	maxTTL := c.maxTTL(l)
+
+	for number := 0; ; number++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+
+		if number < skip {
+			continue
+		}
+
+		if err = ctx.Err(); err != nil {
+			return result, err
+		}
+
+		// only restore valid tile packets
+		if _, err = packet.FromBytes(record.Packet, record.Key); err != nil {
+			return result, err
+		}
+
+		var ttl time.Duration
+		if !record.Expires.IsZero() {
+			if ttl = time.Until(record.Expires); ttl <= 0 {
+				result.Expired++
+				result.Next = number + 1
+				continue
+			}
+		}
+
+		// don't outlive the TTLs configured for the level
+		if ttl == 0 || (maxTTL > 0 && maxTTL < ttl) {
+			ttl = maxTTL
+		}
+
+		if err = l.backend.Set(ctx, record.Key, record.Packet, ttl); err != nil {
+			return result, err
+		}
+
+		result.Entries++
+		result.Next = number + 1
+
+		if result.Entries%progressInterval == 0 {
+			progress(result)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dechristopher/lod/archive"
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/packet"
+)
+
+// TestImportTTL will test that imported records keep their remaining TTL,
+// capped at the longest TTL the level's TTL rules allow
+func TestImportTTL(t *testing.T) {
+	memory := newFakeBackend(config.LayerMemory)
+	c := &Cache{
+		layers: []*layer{{backend: memory, ttl: time.Minute}},
+		proxy: &config.Proxy{Name: "test", Cache: config.Cache{
+			MemTTLDuration: time.Minute,
+			TTLRules: []config.TTLRule{
+				{MinZoom: 0, MaxZoom: 4, MemTTL: "1h", MemTTLDuration: time.Hour},
+			},
+		}},
+	}
+	c.Metrics = newMetrics(c)
+
+	tile := packet.Encode([]byte("tile"), map[string]string{}).Raw()
+	now := time.Now()
+
+	var body bytes.Buffer
+	writer, _ := archive.NewWriter(&body)
+	_ = writer.Write(archive.Record{Key: "short", Packet: tile, Expires: now.Add(30 * time.Minute)})
+	_ = writer.Write(archive.Record{Key: "long", Packet: tile, Expires: now.Add(2 * time.Hour)})
+	_ = writer.Write(archive.Record{Key: "forever", Packet: tile})
+	_ = writer.Write(archive.Record{Key: "expired", Packet: tile, Expires: now.Add(-time.Minute)})
+	_ = writer.Flush()
+
+	reader, err := archive.NewReader(&body)
+	if err != nil {
+		t.Fatalf("failed to read archive: %s", err)
+	}
+
+	result, err := c.Import(context.Background(), config.LayerMemory, reader, 0, func(Transfer) {})
+	if err != nil {
+		t.Fatalf("failed to import archive: %s", err)
+	}
+
+	if result.Entries != 3 || result.Expired != 1 {
+		t.Errorf("expected 3 entries and 1 expired, got %+v", result)
+	}
+
+	if ttl := memory.writes["short"]; ttl <= time.Minute || ttl > 30*time.Minute {
+		t.Errorf("expected remaining TTL beyond the level's TTL to be kept, got %s", ttl)
+	}
+
+	for _, key := range []string{"long", "forever"} {
+		if ttl := memory.writes[key]; ttl != time.Hour {
+			t.Errorf("expected %s to be capped at the longest rule TTL, got %s", key, ttl)
+		}
+	}
+
+	if memory.has("expired") {
+		t.Error("expected expired record to be skipped")
+	}
+}
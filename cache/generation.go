@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/util"
+)
+
+// generationPrefix prefixes the Redis keys holding namespace generations
+const generationPrefix = "lod:gen:"
+
+// generationRefresh is how long generations are used before being re-read
+// from Redis, bounding how long a missed bump can go unnoticed. Generations
+// that couldn't be read are retried no more often than this either.
+const generationRefresh = time.Second
+
+// generations caches the namespace generations of a versioned proxy, which
+// are folded into every cache key so that bumping a generation orphans all
+// entries stored under the previous one
+type generations struct {
+	lock   sync.Mutex            // guards values and epoch
+	values map[string]generation // generations by dynamic endpoint, "" for the whole proxy
+	epoch  uint64                // incremented whenever values are forgotten
+	flight singleflight.Group    // in-flight reads of generations from Redis
+}
+
+// generation is a namespace generation and when it was read from Redis
+type generation struct {
+	value  string
+	loaded time.Time
+}
+
+// generationKey returns the Redis key holding the proxy's generation, or
+// that of one of its dynamic endpoints
+func generationKey(proxy, endpoint string) string {
+	if endpoint == "" {
+		return generationPrefix + proxy
+	}
+	return generationPrefix + proxy + ":e:" + endpoint
+}
+
+// Generation returns the namespace generation folded into the cache keys of
+// the given dynamic endpoint, or of the whole proxy if no endpoint is given.
+// Proxies with dynamic endpoints fold in both the proxy and endpoint
+// generations, ex: 3.1. Stale generations are returned while being re-read
+// from Redis in the background, so only the first use of a generation waits
+// on Redis. If Redis can't be reached the last known generation is used, and
+// ErrUnknownGeneration is returned if none is known.
+func (c *Cache) Generation(ctx context.Context, endpoint string) (string, error) {
+	c.gens.lock.Lock()
+	cached, ok := c.gens.values[endpoint]
+	epoch := c.gens.epoch
+	c.gens.lock.Unlock()
+
+	if ok && time.Since(cached.loaded) < generationRefresh {
+		return c.knownGeneration(endpoint, cached.value)
+	}
+
+	// share one read between all requests, not sharing reads started
+	// before the generations were forgotten
+	refresh := c.gens.flight.DoChan(endpoint+"@"+strconv.FormatUint(epoch, 10), func() (interface{}, error) {
+		return c.refreshGeneration(endpoint, epoch), nil
+	})
+
+	if ok {
+		return c.knownGeneration(endpoint, cached.value)
+	}
+
+	select {
+	case result := <-refresh:
+		return c.knownGeneration(endpoint, result.Val.(string))
+	case <-ctx.Done():
+		return c.knownGeneration(endpoint, "")
+	}
+}
+
+// knownGeneration returns the given generation, or ErrUnknownGeneration
+// if it's empty because no generation could be read
+func (c *Cache) knownGeneration(endpoint, value string) (string, error) {
+	if value == "" {
+		return "", ErrUnknownGeneration{Name: c.Proxy().Name, Endpoint: endpoint}
+	}
+	return value, nil
+}
+
+// refreshGeneration re-reads the generation from Redis and stores it unless
+// the generations were forgotten in the meantime. If the read fails the last
+// known generation is stored instead, or an empty one if none is known, so
+// it isn't retried until it's stale.
+func (c *Cache) refreshGeneration(endpoint string, epoch uint64) string {
+	value, err := c.readGeneration(context.Background(), endpoint)
+
+	c.gens.lock.Lock()
+	defer c.gens.lock.Unlock()
+
+	if err != nil {
+		util.Error(str.CCache, str.ECacheGeneration, c.Proxy().Name, err.Error())
+		value = ""
+		if cached, ok := c.gens.values[endpoint]; ok {
+			value = cached.value
+		}
+	}
+
+	if epoch != c.gens.epoch {
+		return value
+	}
+
+	if c.gens.values == nil {
+		c.gens.values = make(map[string]generation)
+	}
+	c.gens.values[endpoint] = generation{value: value, loaded: time.Now()}
+
+	return value
+}
+
+// readGeneration reads the current generation from Redis
+func (c *Cache) readGeneration(ctx context.Context, endpoint string) (string, error) {
+	client := c.Redis()
+	if client == nil {
+		return "", ErrNoRedis{Name: c.Proxy().Name}
+	}
+
+	keys := []string{generationKey(c.Proxy().Name, "")}
+	if c.Proxy().HasEndpointParam {
+		keys = append(keys, generationKey(c.Proxy().Name, endpoint))
+	}
+
+	// read keys individually since they may live in different cluster slots
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		value, err := client.Get(ctx, key).Result()
+		if err == redis.Nil {
+			value = "0"
+		} else if err != nil {
+			return "", err
+		}
+		values[i] = value
+	}
+
+	return strings.Join(values, "."), nil
+}
+
+// BumpGeneration increments the generation of the given dynamic endpoint,
+// or of the whole proxy if no endpoint is given, instantly orphaning every
+// cache entry stored under the previous generation. Orphaned entries are
+// left to expire through each cache level's TTL or eviction.
+func (c *Cache) BumpGeneration(ctx context.Context, endpoint string) (int64, error) {
+	client := c.Redis()
+	if client == nil {
+		return 0, ErrNoRedis{Name: c.Proxy().Name}
+	}
+
+	value, err := client.Incr(ctx, generationKey(c.Proxy().Name, endpoint)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	c.ForgetGenerations()
+	util.Info(str.CCache, str.MCacheGeneration, c.Proxy().Name, endpoint, strconv.FormatInt(value, 10))
+
+	return value, nil
+}
+
+// ForgetGenerations drops the locally cached generations so that they're
+// re-read from Redis on next use, ex: after a peer bumped a generation
+func (c *Cache) ForgetGenerations() {
+	c.gens.lock.Lock()
+	defer c.gens.lock.Unlock()
+	c.gens.values = make(map[string]generation)
+	c.gens.epoch++
+}
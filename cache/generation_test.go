@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestGenerationFallback will test that generations that can't be read from
+// Redis fall back to the last known generation, or fail if none is known,
+// without waiting on Redis again until they're stale
+func TestGenerationFallback(t *testing.T) {
+	c, _, _ := newTestCache()
+	ctx := context.Background()
+
+	if gen, err := c.Generation(ctx, ""); err == nil {
+		t.Errorf("expected no generation without a known generation, got %s", gen)
+	} else if _, ok := err.(ErrUnknownGeneration); !ok {
+		t.Errorf("expected ErrUnknownGeneration, got %v", err)
+	}
+
+	c.gens.lock.Lock()
+	if cached, ok := c.gens.values[""]; !ok || cached.value != "" || time.Since(cached.loaded) > generationRefresh {
+		t.Error("expected failed read to store a fresh unknown generation")
+	}
+	c.gens.values["roads"] = generation{value: "5", loaded: time.Now().Add(-time.Minute)}
+	c.gens.lock.Unlock()
+
+	// unknown generations fail without waiting on Redis until they're stale
+	if _, err := c.Generation(ctx, ""); err == nil {
+		t.Error("expected unknown generation to keep failing until stale")
+	}
+
+	if gen, err := c.Generation(ctx, "roads"); err != nil || gen != "5" {
+		t.Errorf("expected stale generation 5 while refreshing, got %s: %v", gen, err)
+	}
+
+	// the stale generation is refreshed in the background
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		c.gens.lock.Lock()
+		cached := c.gens.values["roads"]
+		c.gens.lock.Unlock()
+
+		if time.Since(cached.loaded) < generationRefresh {
+			if cached.value != "5" {
+				t.Errorf("expected last known generation 5 to be kept, got %s", cached.value)
+			}
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Error("expected stale generation to be refreshed")
+}
+
+// TestGenerationForgotten will test that forgotten generations aren't
+// guessed when they can't be read again
+func TestGenerationForgotten(t *testing.T) {
+	c, _, _ := newTestCache()
+
+	c.gens.lock.Lock()
+	c.gens.values = map[string]generation{"": {value: "3", loaded: time.Now()}}
+	c.gens.lock.Unlock()
+
+	if gen, err := c.Generation(context.Background(), ""); err != nil || gen != "3" {
+		t.Fatalf("expected known generation 3, got %s: %v", gen, err)
+	}
+
+	c.ForgetGenerations()
+
+	if gen, err := c.Generation(context.Background(), ""); err == nil {
+		t.Errorf("expected forgotten generation not to be guessed, got %s", gen)
+	}
+}
@@ -0,0 +1,287 @@
+package cache
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/tile"
+	"github.com/dechristopher/lod/util"
+)
+
+// heatPrefix prefixes the Redis sorted sets ranking each proxy's tiles by requests
+const heatPrefix = "lod:heat:"
+
+const (
+	// sketchDepth is the number of rows of the count-min sketch, each hashing
+	// tiles independently so that an overestimate requires collisions in all
+	sketchDepth = 4
+
+	// sketchWidth is the number of counters of each row of the count-min
+	// sketch, overestimating counts by at most e/sketchWidth (0.07%) of all
+	// requests with a probability of 1-e^-sketchDepth (98%)
+	sketchWidth = 4096
+)
+
+// TileCount is a tile along with its estimated number of requests
+type TileCount struct {
+	Tile  tile.Tile // requested tile
+	Count uint64    // estimated number of requests
+}
+
+// sketch is a count-min sketch estimating the number of requests of any
+// tile in fixed memory, never underestimating
+type sketch [sketchDepth][sketchWidth]uint64
+
+// add adds n requests of the tile with the given key using conservative
+// updates, returning its new estimated count
+func (s *sketch) add(key []byte, n uint64) uint64 {
+	hash := xxhash.Sum64(key)
+	h1, h2 := uint32(hash), uint32(hash>>32)
+
+	var cells [sketchDepth]*uint64
+	estimate := uint64(math.MaxUint64)
+	for i := range s {
+		cells[i] = &s[i][(h1+uint32(i)*h2)%sketchWidth]
+		if *cells[i] < estimate {
+			estimate = *cells[i]
+		}
+	}
+
+	// only raise counters below the new estimate, reducing overestimates
+	estimate += n
+	for _, cell := range cells {
+		if *cell < estimate {
+			*cell = estimate
+		}
+	}
+
+	return estimate
+}
+
+// heatEntry is a ranked tile within the top-K heap
+type heatEntry struct {
+	TileCount
+	index int // position within the heap
+}
+
+// heatHeap is a min-heap of ranked tiles, least requested first
+type heatHeap []*heatEntry
+
+func (h heatHeap) Len() int           { return len(h) }
+func (h heatHeap) Less(i, j int) bool { return h[i].Count < h[j].Count }
+func (h heatHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *heatHeap) Push(x interface{}) {
+	entry := x.(*heatEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+func (h *heatHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// heatmap counts sampled tile requests in a count-min sketch, ranking the
+// top-K most requested tiles by their estimated counts, and optionally
+// collects the requests counted between flushes to Redis
+type heatmap struct {
+	lock    sync.Mutex               // guards every field
+	sketch  *sketch                  // estimated requests of every tile
+	top     heatHeap                 // most requested tiles, least requested first
+	ranked  map[tile.Tile]*heatEntry // most requested tiles by tile
+	pending map[tile.Tile]float64    // requests by tile since the last flush to Redis
+	carry   float64                  // fraction of a request weight not yet counted in the sketch
+	flushed time.Time                // time of the last flush to Redis
+}
+
+// heatKey returns the key of the Redis sorted set ranking the proxy's tiles
+func heatKey(proxy string) string {
+	return heatPrefix + proxy
+}
+
+// tileKey returns the z/x/y representation of the tile
+func tileKey(t tile.Tile) string {
+	return fmt.Sprintf("%d/%d/%d", t.Zoom, t.X, t.Y)
+}
+
+// RecordTile counts a sampled request for the given tile if the proxy keeps
+// a heatmap, weighing each sampled request by the inverse of the sample rate
+// so counts estimate every request. The sketch only counts whole requests,
+// so the fractional part of weights is carried over to later requests.
+func (c *Cache) RecordTile(t tile.Tile) {
+	conf := c.Proxy().Heatmap
+	if !conf.Enabled() || (conf.SampleRate < 1 && rand.Float64() >= conf.SampleRate) {
+		return
+	}
+	weight := 1 / conf.SampleRate
+
+	c.heat.lock.Lock()
+	defer c.heat.lock.Unlock()
+
+	if c.heat.sketch == nil {
+		c.heat.sketch = new(sketch)
+		c.heat.ranked = make(map[tile.Tile]*heatEntry)
+	}
+
+	whole := math.Floor(weight + c.heat.carry)
+	c.heat.carry += weight - whole
+
+	count := c.heat.sketch.add([]byte(tileKey(t)), uint64(whole))
+
+	c.heat.rank(t, count, conf.TopK)
+
+	if conf.Redis {
+		c.heat.collect(t, weight, c.flushHeat)
+	}
+}
+
+// rank updates the count of a ranked tile, or ranks an unranked tile if
+// fewer than k tiles are ranked or it outranks the least requested one
+func (h *heatmap) rank(t tile.Tile, count uint64, k int) {
+	if entry, ok := h.ranked[t]; ok {
+		entry.Count = count
+		heap.Fix(&h.top, entry.index)
+		return
+	}
+
+	if len(h.top) >= k {
+		if k == 0 || h.top[0].Count >= count {
+			return
+		}
+		delete(h.ranked, heap.Pop(&h.top).(*heatEntry).Tile)
+	}
+
+	entry := &heatEntry{TileCount: TileCount{Tile: t, Count: count}}
+	heap.Push(&h.top, entry)
+	h.ranked[t] = entry
+}
+
+// collect adds requests to those pending a flush to Redis, flushing them in
+// the background periodically
+func (h *heatmap) collect(t tile.Tile, weight float64, flush func(map[tile.Tile]float64)) {
+	if h.pending == nil {
+		h.pending = make(map[tile.Tile]float64)
+		if h.flushed.IsZero() {
+			h.flushed = time.Now()
+		}
+	}
+
+	if _, ok := h.pending[t]; ok || len(h.pending) < hotPendingCap {
+		h.pending[t] += weight
+	}
+
+	if time.Since(h.flushed) >= hotFlushInterval {
+		go flush(h.pending)
+		h.pending = nil
+		h.flushed = time.Now()
+	}
+}
+
+// flushHeat adds the given request counts to the proxy's ranked tiles in
+// Redis, trimming the ranking to the most requested tiles
+func (c *Cache) flushHeat(counts map[tile.Tile]float64) {
+	client := c.Redis()
+	if client == nil {
+		return
+	}
+
+	ctx := context.Background()
+	key := heatKey(c.Proxy().Name)
+
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for t, count := range counts {
+			pipe.ZIncrBy(ctx, key, count, tileKey(t))
+		}
+		pipe.ZRemRangeByRank(ctx, key, 0, -int64(c.Proxy().Heatmap.TopK)-1)
+		pipe.Expire(ctx, key, hotTTL)
+		return nil
+	})
+	if err != nil {
+		util.Error(str.CCache, str.ECacheHeat, c.Proxy().Name, err.Error())
+	}
+}
+
+// HotTiles returns up to n of the proxy's most requested tiles, most
+// requested first, optionally only those at the given zoom level (or every
+// zoom level if negative). Counts are summed across instances in Redis if
+// the proxy aggregates them there, otherwise they're this instance's own.
+func (c *Cache) HotTiles(ctx context.Context, n, zoom int) ([]TileCount, error) {
+	var ranked []TileCount
+	var err error
+
+	if c.Proxy().Heatmap.Redis {
+		ranked, err = c.sharedHeat(ctx)
+	} else {
+		ranked = c.localHeat()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hot := make([]TileCount, 0, n)
+	for _, tileCount := range ranked {
+		if len(hot) == n {
+			break
+		}
+		if zoom < 0 || tileCount.Tile.Zoom == zoom {
+			hot = append(hot, tileCount)
+		}
+	}
+
+	return hot, nil
+}
+
+// localHeat returns this instance's ranked tiles, most requested first
+func (c *Cache) localHeat() []TileCount {
+	c.heat.lock.Lock()
+	ranked := make([]TileCount, 0, len(c.heat.top))
+	for _, entry := range c.heat.top {
+		ranked = append(ranked, entry.TileCount)
+	}
+	c.heat.lock.Unlock()
+
+	sort.Slice(ranked, func(i, j int) bool {
+		return ranked[i].Count > ranked[j].Count
+	})
+	return ranked
+}
+
+// sharedHeat returns the ranked tiles of every instance from Redis, most
+// requested first
+func (c *Cache) sharedHeat(ctx context.Context) ([]TileCount, error) {
+	client := c.Redis()
+	if client == nil {
+		return nil, ErrNoRedis{Name: c.Proxy().Name}
+	}
+
+	members, err := client.ZRevRangeWithScores(ctx, heatKey(c.Proxy().Name), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := make([]TileCount, 0, len(members))
+	for _, member := range members {
+		var t tile.Tile
+		name, _ := member.Member.(string)
+		if _, err = fmt.Sscanf(name, "%d/%d/%d", &t.Zoom, &t.X, &t.Y); err != nil {
+			continue
+		}
+		ranked = append(ranked, TileCount{Tile: t, Count: uint64(member.Score)})
+	}
+
+	return ranked, nil
+}
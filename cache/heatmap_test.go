@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/tile"
+)
+
+// TestHeatmap will test that the most requested tiles are ranked by their
+// estimated request counts, and that less requested tiles are dropped
+func TestHeatmap(t *testing.T) {
+	c := &Cache{proxy: &config.Proxy{
+		Name:    "test",
+		Heatmap: config.Heatmap{SampleRate: 1, TopK: 2},
+	}}
+
+	requests := map[tile.Tile]int{
+		{Zoom: 14, X: 1, Y: 2}: 5,
+		{Zoom: 12, X: 3, Y: 4}: 3,
+		{Zoom: 14, X: 5, Y: 6}: 1,
+	}
+	for requested, n := range requests {
+		for i := 0; i < n; i++ {
+			c.RecordTile(requested)
+		}
+	}
+
+	hot, _ := c.HotTiles(context.Background(), 10, -1)
+	if len(hot) != 2 || hot[0].Count != 5 || hot[1].Count != 3 {
+		t.Fatalf("expected the two most requested tiles, got %+v", hot)
+	}
+
+	hot, _ = c.HotTiles(context.Background(), 10, 14)
+	if len(hot) != 1 || hot[0].Tile != (tile.Tile{Zoom: 14, X: 1, Y: 2}) {
+		t.Errorf("expected the most requested tile at zoom 14, got %+v", hot)
+	}
+}
+
+// TestHeatmapSampleWeight will test that sampled requests are weighed so
+// counts estimate every request, even if the sample rate isn't 1/n
+func TestHeatmapSampleWeight(t *testing.T) {
+	c := &Cache{proxy: &config.Proxy{
+		Name:    "test",
+		Heatmap: config.Heatmap{SampleRate: 0.3, TopK: 1},
+	}}
+
+	requested := tile.Tile{Zoom: 14, X: 1, Y: 2}
+	for i := 0; i < 100000; i++ {
+		c.RecordTile(requested)
+	}
+
+	hot, _ := c.HotTiles(context.Background(), 1, -1)
+	if len(hot) != 1 || hot[0].Count < 97000 || hot[0].Count > 103000 {
+		t.Errorf("expected about 100000 requests, got %+v", hot)
+	}
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/packet"
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/util"
+)
+
+// hotPrefix prefixes the Redis sorted sets ranking each proxy's keys by popularity
+const hotPrefix = "lod:hot:"
+
+// hotFlushInterval is how often locally counted requests are added to Redis
+const hotFlushInterval = 30 * time.Second
+
+// hotPendingCap is the maximum number of distinct keys counted between flushes
+const hotPendingCap = 10000
+
+// hotTTL is how long popular keys are kept after the last flush of any instance
+const hotTTL = 7 * 24 * time.Hour
+
+// hotKeys counts requests by key between flushes to Redis, where every
+// instance's counts are summed to rank the proxy's most popular keys
+type hotKeys struct {
+	lock    sync.Mutex         // guards counts and flushed
+	counts  map[string]float64 // requests by key since the last flush
+	flushed time.Time          // time of the last flush
+}
+
+// hotKey returns the key of the Redis sorted set ranking the proxy's keys
+func hotKey(proxy string) string {
+	return hotPrefix + proxy
+}
+
+// recordHot counts a request for the given key if the proxy tracks its most
+// popular keys, flushing the counts to Redis in the background periodically
+func (c *Cache) recordHot(key string) {
+	if c.Proxy().Warmup.HotKeys == 0 {
+		return
+	}
+
+	c.hot.lock.Lock()
+	defer c.hot.lock.Unlock()
+
+	if c.hot.counts == nil {
+		c.hot.counts = make(map[string]float64)
+		if c.hot.flushed.IsZero() {
+			c.hot.flushed = time.Now()
+		}
+	}
+
+	if _, ok := c.hot.counts[key]; ok || len(c.hot.counts) < hotPendingCap {
+		c.hot.counts[key]++
+	}
+
+	if time.Since(c.hot.flushed) >= hotFlushInterval {
+		go c.flushHot(c.hot.counts)
+		c.hot.counts = nil
+		c.hot.flushed = time.Now()
+	}
+}
+
+// flushHot adds the given request counts to the proxy's popular keys in
+// Redis, trimming the ranking to the most popular keys
+func (c *Cache) flushHot(counts map[string]float64) {
+	client := c.Redis()
+	if client == nil {
+		return
+	}
+
+	ctx := context.Background()
+	key := hotKey(c.Proxy().Name)
+
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for member, count := range counts {
+			pipe.ZIncrBy(ctx, key, count, member)
+		}
+		pipe.ZRemRangeByRank(ctx, key, 0, -int64(config.MaxWarmupHotKeys)-1)
+		pipe.Expire(ctx, key, hotTTL)
+		return nil
+	})
+	if err != nil {
+		util.Error(str.CCache, str.ECacheHot, c.Proxy().Name, err.Error())
+	}
+}
+
+// HotKeys returns up to n of the proxy's most popular keys, most popular first
+func (c *Cache) HotKeys(ctx context.Context, n int) ([]string, error) {
+	client := c.Redis()
+	if client == nil {
+		return nil, ErrNoRedis{Name: c.Proxy().Name}
+	}
+
+	return client.ZRevRange(ctx, hotKey(c.Proxy().Name), 0, int64(n-1)).Result()
+}
+
+// Warm loads the tile for the given key into the local cache levels above
+// the first level holding it with the given TTLs, returning false if no
+// level holds the tile. The expiry of the level holding it is left as is.
+func (c *Cache) Warm(ctx context.Context, key string, ttls TTLs) (bool, error) {
+	layers := c.getLayers()
+
+	for i, l := range layers {
+		cachedTile, err := l.backend.Get(ctx, key, KeepTTL)
+		if err != nil {
+			return false, err
+		}
+
+		if cachedTile == nil {
+			continue
+		}
+
+		tile, err := packet.FromBytes(cachedTile, key)
+		if err != nil {
+			return false, err
+		}
+
+		// keep expired tiles out, and fresh tiles from outliving their expiry
+		if expires, ok := tile.Expires(); ok {
+			remaining := time.Until(expires)
+			if remaining <= 0 {
+				return false, nil
+			}
+			ttls = c.CapTTLs(ttls, remaining)
+		}
+
+		for _, above := range layers[:i] {
+			if !above.shared {
+				c.setLayer(above, key, tile.TilePacket, ttls.of(above))
+			}
+		}
+
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,243 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dechristopher/lod/packet"
+)
+
+// Key browser page limits
+const (
+	DefaultKeyCount = 100   // number of keys returned per page by default
+	MaxKeyCount     = 1000  // maximum number of keys returned per page
+	keyScanBudget   = 50000 // maximum number of keys scanned per page
+)
+
+// errPageDone stops a key scan once a page is complete
+var errPageDone = errors.New("page done")
+
+// KeyInfo describes a single entry found by the key browser
+type KeyInfo struct {
+	Key     string            `json:"key"`               // cache key of the entry
+	Size    int               `json:"size"`              // size of the encoded tile packet in bytes
+	TTL     int64             `json:"ttl"`               // remaining seconds until expiry, -1 for never
+	Valid   bool              `json:"valid"`             // whether the tile packet passes its checksum
+	Version int               `json:"version"`           // layout version of the tile packet
+	Headers map[string]string `json:"headers,omitempty"` // headers stored in valid tile packets
+	Fetched int64             `json:"fetched,omitempty"` // unix time the tile was fetched from upstream at
+	Status  int               `json:"status,omitempty"`  // upstream status code the tile was fetched with
+	ETag    string            `json:"etag,omitempty"`    // upstream ETag of the tile
+	Codec   string            `json:"codec,omitempty"`   // content coding the tile data is stored with
+}
+
+// KeyPage is a page of entries found by the key browser
+type KeyPage struct {
+	Layer  string    `json:"layer"`  // cache level the entries were found in
+	Match  string    `json:"match"`  // glob pattern the keys match
+	Keys   []KeyInfo `json:"keys"`   // entries found
+	Cursor string    `json:"cursor"` // scan cursor of the next page, empty once complete
+}
+
+// Keys returns a page of roughly count entries of the named cache level
+// whose keys match the Redis-style glob pattern, starting from the given
+// cursor, or the first level able to enumerate its entries if no level is
+// named. Like Redis SCAN, pages end between scan batches, so they may hold
+// more entries than requested, or fewer, or none at all when few keys
+// match. Scanning is complete once the returned cursor is empty.
+func (c *Cache) Keys(ctx context.Context, layerName, match, cursor string, count int) (KeyPage, error) {
+	page := KeyPage{Layer: layerName, Match: match, Keys: make([]KeyInfo, 0)}
+
+	if page.Layer == "" {
+		if page.Layer = c.firstScannable(); page.Layer == "" {
+			return page, ErrNotScannable{Name: c.Proxy().Name, Layer: "any"}
+		}
+	}
+
+	scanner, err := c.scanner(page.Layer)
+	if err != nil {
+		return page, err
+	}
+
+	glob, err := compileGlob(match)
+	if err != nil {
+		return page, err
+	}
+
+	scanned := 0
+	filter := func(key string) bool {
+		scanned++
+		return glob.MatchString(key)
+	}
+
+	err = scanner.Scan(ctx, cursor, count, filter, func(batch []Entry, next string) error {
+		for _, entry := range batch {
+			page.Keys = append(page.Keys, describe(entry))
+		}
+
+		// bound the work done per page when few keys match
+		page.Cursor = next
+		if len(page.Keys) >= count || scanned >= keyScanBudget {
+			return errPageDone
+		}
+		return nil
+	})
+	if err != nil && err != errPageDone {
+		return page, err
+	}
+
+	return page, nil
+}
+
+// DeleteKeys removes every entry whose key matches the Redis-style glob
+// pattern from all cache levels, returning the number of keys deleted.
+// Keys are found by scanning each level able to enumerate its entries, so
+// entries held only by the on-disk or memcached levels are left to expire.
+// Peers must delete matching keys from their local levels themselves.
+func (c *Cache) DeleteKeys(ctx context.Context, match string) (int, error) {
+	return c.deleteKeys(ctx, match, false)
+}
+
+// DeleteKeysInternal removes every entry whose key matches the Redis-style
+// glob pattern from the cache levels local to this instance, returning the
+// number of keys deleted
+func (c *Cache) DeleteKeysInternal(match string) (int, error) {
+	return c.deleteKeys(context.Background(), match, true)
+}
+
+// deleteKeys removes matching entries found in every scannable level from
+// all levels, or only from local levels if internalOnly is set
+func (c *Cache) deleteKeys(ctx context.Context, match string, internalOnly bool) (int, error) {
+	glob, err := compileGlob(match)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, l := range c.getLayers() {
+		scanner, ok := l.backend.(Scanner)
+		if !ok || (internalOnly && l.shared) {
+			continue
+		}
+
+		err = scanner.Scan(ctx, "", 0, glob.MatchString, func(batch []Entry, _ string) error {
+			for _, entry := range batch {
+				deleted++
+				if internalOnly {
+					err = c.InvalidateInternal(entry.Key)
+				} else {
+					err = c.Invalidate(entry.Key, ctx)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
+
+// firstScannable returns the name of the first cache level able to
+// enumerate its entries, or an empty string if there are none
+func (c *Cache) firstScannable() string {
+	for _, l := range c.getLayers() {
+		if _, ok := l.backend.(Scanner); ok {
+			return l.backend.Name()
+		}
+	}
+	return ""
+}
+
+// describe returns the key browser's description of an entry
+func describe(entry Entry) KeyInfo {
+	info := KeyInfo{
+		Key:  entry.Key,
+		Size: len(entry.Data),
+		TTL:  -1,
+	}
+
+	if !entry.Expires.IsZero() {
+		info.TTL = int64(time.Until(entry.Expires).Round(time.Second) / time.Second)
+	}
+
+	tile := packet.TilePacket(entry.Data)
+	info.Version = tile.Version()
+	if info.Valid = tile.Validate(); !info.Valid {
+		return info
+	}
+
+	meta := tile.Meta()
+	info.Headers = tile.Headers()
+	info.Status = meta.Status
+	info.ETag = meta.ETag
+	info.Codec = meta.Codec.Encoding()
+	if !meta.Fetched.IsZero() {
+		info.Fetched = meta.Fetched.Unix()
+	}
+
+	return info
+}
+
+// compileGlob compiles a Redis-style glob pattern, supporting *, ?, [abc],
+// [^abc], [a-z] and backslash escapes, into an anchored regular expression
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	expr := strings.Builder{}
+	expr.WriteString(`(?s)^`)
+
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '*':
+			expr.WriteString(`.*`)
+		case '?':
+			expr.WriteString(`.`)
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+			expr.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case '[':
+			end := i + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end >= len(runes) || end == i+1 {
+				return nil, ErrInvalidPattern{Pattern: pattern}
+			}
+
+			expr.WriteString(`[`)
+			for j := i + 1; j < end; j++ {
+				switch {
+				case j == i+1 && runes[j] == '^':
+					expr.WriteRune('^')
+				case runes[j] == '-' && j > i+1 && j < end-1:
+					expr.WriteRune('-')
+				default:
+					expr.WriteString(`\x{` + strconv.FormatInt(int64(runes[j]), 16) + `}`)
+				}
+			}
+			expr.WriteString(`]`)
+			i = end
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(runes[i])))
+		}
+	}
+
+	expr.WriteString(`$`)
+
+	glob, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, ErrInvalidPattern{Pattern: pattern}
+	}
+
+	return glob, nil
+}
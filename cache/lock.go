@@ -0,0 +1,23 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// lockPrefix prefixes the Redis keys of locks held by instances sharing
+// cache levels
+const lockPrefix = "lod:lock:"
+
+// Lock acquires the named lock for the proxy in Redis until it expires
+// after the given TTL, returning false if another instance holds it.
+// Proxies without a Redis cache level can't coordinate with other
+// instances, so they always acquire it.
+func (c *Cache) Lock(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	client := c.Redis()
+	if client == nil {
+		return true, nil
+	}
+
+	return client.SetNX(ctx, lockPrefix+c.Proxy().Name+":"+name, 1, ttl).Result()
+}
@@ -0,0 +1,311 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/memcached"
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/util"
+)
+
+const (
+	// flagChunked marks items holding the manifest of a chunked entry
+	flagChunked = 1
+	// itemOverhead is the room left in each item for memcached's item
+	// header and key, which count towards the item size limit
+	itemOverhead = 512
+	// namespaceRefresh is how often the proxy's namespace is re-read, and so
+	// how long other instances may take to observe a flush
+	namespaceRefresh = 5 * time.Second
+)
+
+// memcachedClient is the subset of the memcached client used by the backend
+type memcachedClient interface {
+	Get(ctx context.Context, key string) (*memcached.Item, error)
+	GetMulti(ctx context.Context, keys []string) (map[string]*memcached.Item, error)
+	Set(ctx context.Context, item *memcached.Item, ttl time.Duration) error
+	Add(ctx context.Context, item *memcached.Item, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	Close() error
+}
+
+// memcachedBackend is an external cache level backed by a pool of memcached
+// servers. Memcached can't list keys, so entries are stored under a
+// namespace that is replaced to flush the proxy's entries.
+type memcachedBackend struct {
+	client    memcachedClient // memcached client
+	nsKey     string          // key holding the proxy's current namespace
+	itemLimit int             // largest entry stored in a single item
+	chunk     bool            // whether larger entries are split across items
+
+	lock     sync.Mutex // guards ns and nsLoaded
+	ns       string     // current namespace of the proxy's entries
+	nsLoaded time.Time  // when the namespace was last read
+}
+
+// newMemcachedBackend initializes a memcached cache level from proxy configuration
+func newMemcachedBackend(proxy config.Proxy) (*memcachedBackend, error) {
+	client, err := memcached.New(memcached.Options{
+		Servers: proxy.Cache.MemcachedServers,
+		Timeout: proxy.Cache.MemcachedTimeoutDuration,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// ping every server to verify connectivity
+	if err = client.Ping(context.Background()); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+
+	return &memcachedBackend{
+		client:    client,
+		nsKey:     itemKey("lod:ns", proxy.Name),
+		itemLimit: proxy.Cache.MemcachedItemSize*1024 - itemOverhead,
+		chunk:     proxy.Cache.MemcachedChunk,
+	}, nil
+}
+
+// Name returns the name of the cache level
+func (m *memcachedBackend) Name() string {
+	return config.LayerMemcached
+}
+
+// Get returns the entry for the given key, or nil if not present or if any
+// chunk of a chunked entry has been evicted
+func (m *memcachedBackend) Get(ctx context.Context, key string, _ time.Duration) ([]byte, error) {
+	ns, err := m.namespace(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := m.client.Get(ctx, itemKey(ns, key))
+	if err != nil {
+		if _, ok := err.(memcached.ErrNotFound); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if item.Flags != flagChunked {
+		return item.Value, nil
+	}
+
+	return m.getChunks(ctx, item)
+}
+
+// Set stores the entry for the given key with the given TTL. Entries over
+// the item size limit are split into chunks if enabled, or skipped.
+func (m *memcachedBackend) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	ns, err := m.namespace(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(data) <= m.itemLimit {
+		return m.client.Set(ctx, &memcached.Item{
+			Key:   itemKey(ns, key),
+			Value: data,
+		}, ttl)
+	}
+
+	if !m.chunk {
+		util.DebugFlag("cache", str.CCache, str.DMemcachedSkip, key, len(data))
+		return nil
+	}
+
+	return m.setChunks(ctx, itemKey(ns, key), data, ttl)
+}
+
+// Delete removes the entry for the given key, along with its chunks
+func (m *memcachedBackend) Delete(ctx context.Context, key string) error {
+	ns, err := m.namespace(ctx)
+	if err != nil {
+		return err
+	}
+	key = itemKey(ns, key)
+
+	item, err := m.client.Get(ctx, key)
+	if err != nil {
+		if _, ok := err.(memcached.ErrNotFound); ok {
+			return nil
+		}
+		return err
+	}
+
+	if item.Flags == flagChunked {
+		if count, _, token, ok := parseManifest(item.Value); ok {
+			for i := 0; i < count; i++ {
+				if err = m.client.Delete(ctx, chunkKey(key, token, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return m.client.Delete(ctx, key)
+}
+
+// Flush replaces the proxy's namespace, orphaning every entry stored under
+// the previous one to be evicted by memcached over time
+func (m *memcachedBackend) Flush(ctx context.Context) error {
+	ns := newToken()
+	if err := m.client.Set(ctx, &memcached.Item{Key: m.nsKey, Value: []byte(ns)}, 0); err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.ns = ns
+	m.nsLoaded = time.Now()
+
+	return nil
+}
+
+// Stats returns usage stats, which memcached can't report per proxy
+func (m *memcachedBackend) Stats() BackendStats {
+	return BackendStats{
+		Entries: -1,
+		Size:    -1,
+		Cap:     -1,
+	}
+}
+
+// Close closes the backend's idle connections
+func (m *memcachedBackend) Close() error {
+	return m.client.Close()
+}
+
+// namespace returns the proxy's current namespace, re-reading it from
+// memcached periodically and creating it if it doesn't exist yet
+func (m *memcachedBackend) namespace(ctx context.Context) (string, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.ns != "" && time.Since(m.nsLoaded) < namespaceRefresh {
+		return m.ns, nil
+	}
+
+	item, err := m.client.Get(ctx, m.nsKey)
+	if _, ok := err.(memcached.ErrNotFound); ok {
+		// create the namespace unless another instance just did
+		item = &memcached.Item{Key: m.nsKey, Value: []byte(newToken())}
+		err = m.client.Add(ctx, item, 0)
+		if _, ok = err.(memcached.ErrNotStored); ok {
+			item, err = m.client.Get(ctx, m.nsKey)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	m.ns = string(item.Value)
+	m.nsLoaded = time.Now()
+
+	return m.ns, nil
+}
+
+// setChunks stores an oversized entry as a series of chunk items followed by
+// a manifest item at the entry's key. Chunk keys include a unique token so
+// readers never mix the chunks of different writes.
+func (m *memcachedBackend) setChunks(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	token := newToken()
+	count := (len(data) + m.itemLimit - 1) / m.itemLimit
+
+	for i := 0; i < count; i++ {
+		end := (i + 1) * m.itemLimit
+		if end > len(data) {
+			end = len(data)
+		}
+
+		err := m.client.Set(ctx, &memcached.Item{
+			Key:   chunkKey(key, token, i),
+			Value: data[i*m.itemLimit : end],
+		}, ttl)
+		if err != nil {
+			return err
+		}
+	}
+
+	return m.client.Set(ctx, &memcached.Item{
+		Key:   key,
+		Value: []byte(fmt.Sprintf("%d %d %s", count, len(data), token)),
+		Flags: flagChunked,
+	}, ttl)
+}
+
+// getChunks reassembles a chunked entry from its manifest item
+func (m *memcachedBackend) getChunks(ctx context.Context, manifest *memcached.Item) ([]byte, error) {
+	count, size, token, ok := parseManifest(manifest.Value)
+	if !ok {
+		return nil, nil
+	}
+
+	keys := make([]string, count)
+	for i := range keys {
+		keys[i] = chunkKey(manifest.Key, token, i)
+	}
+
+	chunks, err := m.client.GetMulti(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	data := bytes.NewBuffer(make([]byte, 0, size))
+	for _, key := range keys {
+		chunk, found := chunks[key]
+		if !found {
+			return nil, nil
+		}
+		data.Write(chunk.Value)
+	}
+
+	if data.Len() != size {
+		return nil, nil
+	}
+
+	return data.Bytes(), nil
+}
+
+// parseManifest parses the chunk count, total size and token of a manifest
+func parseManifest(value []byte) (int, int, string, bool) {
+	var count, size int
+	var token string
+
+	if _, err := fmt.Sscanf(string(value), "%d %d %s", &count, &size, &token); err != nil {
+		return 0, 0, "", false
+	}
+
+	return count, size, token, count > 0
+}
+
+// itemKey returns the memcached key of a cache key within a namespace,
+// hashing keys that memcached can't store as they are
+func itemKey(ns, key string) string {
+	full := ns + ":" + key
+	if memcached.ValidKey(full) {
+		return full
+	}
+
+	sum := sha1.Sum([]byte(full))
+	return "h:" + hex.EncodeToString(sum[:])
+}
+
+// chunkKey returns the key of a chunk of an entry
+func chunkKey(key, token string, i int) string {
+	return itemKey(key+":"+token, strconv.Itoa(i))
+}
+
+// newToken returns a new unique token for namespaces and chunked writes
+func newToken() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dechristopher/lod/memcached"
+)
+
+// fakeMemcached is an in-memory memcachedClient for testing
+type fakeMemcached struct {
+	lock  sync.Mutex
+	items map[string]memcached.Item
+}
+
+func (f *fakeMemcached) Get(_ context.Context, key string) (*memcached.Item, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	item, ok := f.items[key]
+	if !ok {
+		return nil, memcached.ErrNotFound{Key: key}
+	}
+	return &item, nil
+}
+
+func (f *fakeMemcached) GetMulti(ctx context.Context, keys []string) (map[string]*memcached.Item, error) {
+	items := make(map[string]*memcached.Item)
+	for _, key := range keys {
+		if item, err := f.Get(ctx, key); err == nil {
+			items[key] = item
+		}
+	}
+	return items, nil
+}
+
+func (f *fakeMemcached) Set(_ context.Context, item *memcached.Item, _ time.Duration) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.items[item.Key] = *item
+	return nil
+}
+
+func (f *fakeMemcached) Add(ctx context.Context, item *memcached.Item, ttl time.Duration) error {
+	if _, err := f.Get(ctx, item.Key); err == nil {
+		return memcached.ErrNotStored{Key: item.Key}
+	}
+	return f.Set(ctx, item, ttl)
+}
+
+func (f *fakeMemcached) Delete(_ context.Context, key string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.items, key)
+	return nil
+}
+
+func (f *fakeMemcached) Close() error {
+	return nil
+}
+
+// newTestMemcached creates a memcached backend over a fake client
+func newTestMemcached(itemLimit int, chunk bool) (*memcachedBackend, *fakeMemcached) {
+	client := &fakeMemcached{items: make(map[string]memcached.Item)}
+	return &memcachedBackend{
+		client:    client,
+		nsKey:     "lod:ns:test",
+		itemLimit: itemLimit,
+		chunk:     chunk,
+	}, client
+}
+
+// TestMemcachedChunking will test that oversized entries are split across
+// items and reassembled, or skipped if chunking is disabled
+func TestMemcachedChunking(t *testing.T) {
+	ctx := context.Background()
+	entry := []byte("0123456789abcdefghij")
+
+	m, client := newTestMemcached(8, true)
+	if err := m.Set(ctx, "1/2/3", entry, 0); err != nil {
+		t.Fatalf("failed to set, error=%s", err.Error())
+	}
+
+	// namespace, manifest and three chunks
+	if len(client.items) != 5 {
+		t.Errorf("expected 5 items, got %d", len(client.items))
+	}
+
+	if data, _ := m.Get(ctx, "1/2/3", 0); !bytes.Equal(data, entry) {
+		t.Errorf("expected chunked entry to be reassembled, got %q", data)
+	}
+
+	if err := m.Delete(ctx, "1/2/3"); err != nil {
+		t.Fatalf("failed to delete, error=%s", err.Error())
+	}
+
+	if len(client.items) != 1 {
+		t.Errorf("expected chunks to be deleted, %d items remain", len(client.items))
+	}
+
+	m, client = newTestMemcached(8, false)
+	if err := m.Set(ctx, "1/2/3", entry, 0); err != nil {
+		t.Fatalf("failed to set, error=%s", err.Error())
+	}
+
+	if data, _ := m.Get(ctx, "1/2/3", 0); data != nil || len(client.items) != 1 {
+		t.Errorf("expected oversized entry to be skipped")
+	}
+}
+
+// TestMemcachedFlush will test that flushing hides previously set entries
+func TestMemcachedFlush(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestMemcached(1024, false)
+
+	if err := m.Set(ctx, "1/2/3", []byte("tile"), 0); err != nil {
+		t.Fatalf("failed to set, error=%s", err.Error())
+	}
+
+	if err := m.Flush(ctx); err != nil {
+		t.Fatalf("failed to flush, error=%s", err.Error())
+	}
+
+	if data, _ := m.Get(ctx, "1/2/3", 0); data != nil {
+		t.Errorf("expected entry to be flushed, got %q", data)
+	}
+}
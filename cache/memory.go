@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/allegro/bigcache/v3"
+
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/env"
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/util"
+)
+
+// expiryLen is the length of the expiry time prefixed to in-memory entries
+const expiryLen = 8
+
+// memoryBackend is an in-memory cache level backed by bigcache. Entries are
+// prefixed with their own expiry time, since bigcache expires every entry
+// after the same life window, which is set to the longest configured TTL.
+type memoryBackend struct {
+	cache     *bigcache.BigCache // underlying bigcache instance
+	capacity  int64              // maximum capacity of the cache in bytes
+	evictions uint64             // number of entries evicted to make room for others
+}
+
+// newMemoryBackend initializes an in-memory cache instance from proxy configuration
+func newMemoryBackend(proxy config.Proxy) (*memoryBackend, error) {
+	maxEntrySize := 4
+
+	// allow override of MaxEntrySize via env var
+	if max, present := os.LookupEnv("MAX_ENTRY_SIZE"); present {
+		if maxInt, err := strconv.Atoi(max); err == nil {
+			maxEntrySize = maxInt
+		} else {
+			util.Error(str.CCache, str.ECacheEntry, max)
+		}
+	}
+
+	conf := bigcache.DefaultConfig(proxy.Cache.MaxMemTTL())
+	conf.StatsEnabled = !env.IsProd()
+	conf.MaxEntrySize = OneMB * maxEntrySize
+	conf.HardMaxCacheSize = proxy.Cache.MemCap
+
+	m := &memoryBackend{capacity: int64(proxy.Cache.MemCap) * OneMB}
+
+	// count entries evicted when the cache is full
+	conf.OnRemoveWithReason = func(string, []byte, bigcache.RemoveReason) {
+		atomic.AddUint64(&m.evictions, 1)
+	}
+	conf = conf.OnRemoveFilterSet(bigcache.NoSpace)
+
+	cache, err := bigcache.New(context.TODO(), conf)
+	if err != nil {
+		return nil, err
+	}
+
+	m.cache = cache
+	return m, nil
+}
+
+// Name returns the name of the cache level
+func (m *memoryBackend) Name() string {
+	return config.LayerMemory
+}
+
+// Get returns the entry for the given key, or nil if not present or expired
+func (m *memoryBackend) Get(_ context.Context, key string, _ time.Duration) ([]byte, error) {
+	data, err := m.cache.Get(key)
+	if err == bigcache.ErrEntryNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < expiryLen {
+		return nil, m.Delete(context.Background(), key)
+	}
+
+	expires := int64(binary.BigEndian.Uint64(data))
+	if expires > 0 && time.Now().UnixNano() > expires {
+		return nil, m.Delete(context.Background(), key)
+	}
+
+	return data[expiryLen:], nil
+}
+
+// Set stores the entry for the given key, expiring it after the given TTL,
+// or after bigcache's life window if the TTL is zero
+func (m *memoryBackend) Set(_ context.Context, key string, data []byte, ttl time.Duration) error {
+	entry := make([]byte, expiryLen+len(data))
+	if ttl > 0 {
+		binary.BigEndian.PutUint64(entry, uint64(time.Now().Add(ttl).UnixNano()))
+	}
+	copy(entry[expiryLen:], data)
+
+	return m.cache.Set(key, entry)
+}
+
+// Scan calls fn with batches of unexpired entries, in bigcache's iteration
+// order, using positions in that order as cursors
+func (m *memoryBackend) Scan(ctx context.Context, cursor string, count int, filter Filter,
+	fn func(batch []Entry, next string) error) error {
+	b, err := newBatcher(cursor, count, fn)
+	if err != nil {
+		return err
+	}
+
+	iterator := m.cache.Iterator()
+	for iterator.SetNext() {
+		if b.skip() {
+			continue
+		}
+
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		if err = b.add(m.entry(iterator, filter)); err != nil {
+			return err
+		}
+	}
+
+	return b.done()
+}
+
+// entry returns the iterator's current entry, or nil if it was evicted
+// while iterating, is expired, or is rejected by the filter
+func (m *memoryBackend) entry(iterator *bigcache.EntryInfoIterator, filter Filter) *Entry {
+	info, err := iterator.Value()
+	if err != nil || len(info.Value()) < expiryLen || !filter.accept(info.Key()) {
+		return nil
+	}
+
+	var expires time.Time
+	if nanos := int64(binary.BigEndian.Uint64(info.Value())); nanos > 0 {
+		if expires = time.Unix(0, nanos); time.Now().After(expires) {
+			return nil
+		}
+	}
+
+	return &Entry{
+		Key:     info.Key(),
+		Data:    info.Value()[expiryLen:],
+		Expires: expires,
+	}
+}
+
+// Delete removes the entry for the given key
+func (m *memoryBackend) Delete(_ context.Context, key string) error {
+	err := m.cache.Delete(key)
+	if err == bigcache.ErrEntryNotFound {
+		return nil
+	}
+	return err
+}
+
+// Flush removes every entry
+func (m *memoryBackend) Flush(_ context.Context) error {
+	return m.cache.Reset()
+}
+
+// Stats returns usage stats about the cache
+func (m *memoryBackend) Stats() BackendStats {
+	return BackendStats{
+		Entries: int64(m.cache.Len()),
+		Size:    int64(m.cache.Capacity()),
+		Cap:     m.capacity,
+	}
+}
+
+// usage returns bigcache's entry count, key collisions and evictions
+func (m *memoryBackend) usage() memoryStats {
+	return memoryStats{
+		entries:    m.cache.Len(),
+		collisions: m.cache.Stats().Collisions,
+		evictions:  atomic.LoadUint64(&m.evictions),
+	}
+}
+
+// Close shuts down the cache, releasing its memory
+func (m *memoryBackend) Close() error {
+	return m.cache.Close()
+}
@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/packet"
+)
+
+// TestMemoryTTL will test that in-memory entries expire after their own
+// TTL, independent of the cache's life window
+func TestMemoryTTL(t *testing.T) {
+	ctx := context.Background()
+	m, err := newMemoryBackend(config.Proxy{
+		Cache: config.Cache{MemCap: 1, MemTTLDuration: time.Hour},
+	})
+	if err != nil {
+		t.Fatalf("failed to create memory cache, error=%s", err.Error())
+	}
+	defer m.Close()
+
+	entry := []byte("tile")
+	_ = m.Set(ctx, "short", entry, time.Millisecond)
+	_ = m.Set(ctx, "long", entry, 0)
+
+	time.Sleep(5 * time.Millisecond)
+
+	if data, _ := m.Get(ctx, "short", 0); data != nil {
+		t.Errorf("expected short lived entry to have expired")
+	}
+
+	if data, _ := m.Get(ctx, "long", 0); !bytes.Equal(data, entry) {
+		t.Errorf("expected entry without TTL to remain, got %q", data)
+	}
+}
+
+// TestTTLRules will test that the first rule matching a tile's zoom level
+// and endpoint overrides the TTLs of the levels it configures
+func TestTTLRules(t *testing.T) {
+	c, _, _ := newTestCache()
+	c.Proxy().Cache.TTLRules = []config.TTLRule{
+		{MinZoom: 0, MaxZoom: 8, Endpoint: "parcels", MemTTL: "1h", MemTTLDuration: time.Hour},
+		{MinZoom: 0, MaxZoom: 8, RedisTTL: "48h", RedisTTLDuration: 48 * time.Hour},
+	}
+
+	ttls := c.TTLs(4, "parcels")
+	if ttls[config.LayerMemory] != time.Hour {
+		t.Errorf("expected endpoint rule to apply, got %v", ttls)
+	}
+	if _, ok := ttls[config.LayerRedis]; ok {
+		t.Errorf("expected redis TTL to fall back to the level's TTL, got %v", ttls)
+	}
+
+	if ttls = c.TTLs(4, "roads"); ttls[config.LayerRedis] != 48*time.Hour {
+		t.Errorf("expected zoom rule to apply, got %v", ttls)
+	}
+
+	if ttls = c.TTLs(12, "parcels"); ttls != nil {
+		t.Errorf("expected no rule to apply, got %v", ttls)
+	}
+}
+
+// TestKeys will test that the key browser pages through the entries whose
+// keys match a glob pattern, and that matching keys are bulk deleted
+func TestKeys(t *testing.T) {
+	ctx := context.Background()
+	m, err := newMemoryBackend(config.Proxy{
+		Cache: config.Cache{MemCap: 1, MemTTLDuration: time.Hour},
+	})
+	if err != nil {
+		t.Fatalf("failed to create memory cache, error=%s", err.Error())
+	}
+	defer m.Close()
+
+	c := &Cache{
+		layers: []*layer{{backend: m}},
+		proxy:  &config.Proxy{Name: "test"},
+	}
+
+	tile := packet.Encode([]byte("tile"), map[string]string{"Content-Type": "image/png"})
+	for _, key := range []string{"basemap:14:1:2", "basemap:14:3:4", "basemap:15:1:2", "terrain:14:1:2"} {
+		_ = m.Set(ctx, key, tile.Raw(), 0)
+	}
+
+	found := make([]KeyInfo, 0)
+	for cursor := ""; ; {
+		page, errKeys := c.Keys(ctx, "", "basemap:1[4]:*", cursor, 1)
+		if errKeys != nil {
+			t.Fatalf("failed to list keys, error=%s", errKeys.Error())
+		}
+		found = append(found, page.Keys...)
+		if cursor = page.Cursor; cursor == "" {
+			break
+		}
+	}
+
+	if len(found) != 2 {
+		t.Fatalf("expected 2 matching keys, got %d", len(found))
+	}
+
+	for _, info := range found {
+		if !info.Valid || info.TTL != -1 || info.Headers["Content-Type"] != "image/png" {
+			t.Errorf("unexpected key info %+v", info)
+		}
+	}
+
+	deleted, err := c.DeleteKeys(ctx, "basemap:*")
+	if err != nil || deleted != 3 {
+		t.Errorf("expected 3 keys deleted, got %d, error=%v", deleted, err)
+	}
+
+	if data, _ := m.Get(ctx, "terrain:14:1:2", 0); data == nil {
+		t.Errorf("expected key not matching pattern to remain")
+	}
+}
+
+// TestRebuildProxy will test that reloads hand the reloaded proxy
+// configuration to existing cache instances along with their levels
+func TestRebuildProxy(t *testing.T) {
+	c, _, _ := newTestCache()
+
+	proxy := config.Proxy{Name: "test", Cache: config.Cache{
+		TTLRules: []config.TTLRule{{MinZoom: 0, MaxZoom: 8, MemTTL: "1h", MemTTLDuration: time.Hour}},
+	}}
+	if err := c.rebuild(proxy); err != nil {
+		t.Fatalf("failed to rebuild cache: %s", err)
+	}
+
+	if ttls := c.TTLs(4, ""); ttls[config.LayerMemory] != time.Hour {
+		t.Errorf("expected reloaded TTL rule to apply, got %v", ttls)
+	}
+}
@@ -0,0 +1,303 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/util"
+)
+
+const (
+	// maxZoomLabel is the highest zoom level labeled in request counts,
+	// requests for deeper zoom levels are counted as other
+	maxZoomLabel = 30
+
+	// otherLabel labels values beyond the cardinality cap of a label
+	otherLabel = "other"
+)
+
+// Registry is LOD's Prometheus registry, holding Go runtime and process
+// metrics along with the metrics of each proxy's cache instance, which are
+// registered when the instance is built and unregistered once the proxy is
+// removed from the configuration, so reloads never collide or leak series
+var Registry = newRegistry()
+
+// newRegistry creates a registry holding Go runtime and process metrics
+func newRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	return registry
+}
+
+// Metrics for the cache instance
+type Metrics struct {
+	CacheHits        prometheus.Counter       // cache hits
+	CacheMisses      prometheus.Counter       // cache misses
+	HitRate          prometheus.CounterFunc   // cache hit rate
+	CacheFetch       prometheus.Histogram     // cache lookup latency
+	UpstreamFetch    prometheus.Histogram     // upstream fetch latency
+	LayerHits        *prometheus.CounterVec   // hits by cache level
+	LayerMisses      *prometheus.CounterVec   // misses by cache level
+	UpstreamStatus   *prometheus.CounterVec   // upstream responses by status code
+	BytesServed      prometheus.Counter       // response body bytes sent to clients
+	FlightWaits      prometheus.Counter       // requests served by another request's upstream fetch
+	InvalidPackets   prometheus.Counter       // cached tile packets failing validation
+	Requests         *prometheus.CounterVec   // tile requests by zoom level and endpoint
+	RedisLatency     *prometheus.HistogramVec // redis command latency by command
+	MemoryEntries    prometheus.GaugeFunc     // entries in the in-memory level
+	MemoryCollisions prometheus.CounterFunc   // key hash collisions in the in-memory level
+	MemoryEvictions  prometheus.CounterFunc   // entries evicted from the in-memory level when full
+
+	endpoints labelSet // endpoints labeled in request counts
+}
+
+// newMetrics creates the metrics of the given cache instance, unregistered
+func newMetrics(c *Cache) *Metrics {
+	labels := prometheus.Labels{"proxy": c.Proxy().Name}
+
+	cacheHits := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   config.Namespace,
+		Subsystem:   Subsystem,
+		Name:        "hit_total",
+		ConstLabels: labels,
+		Help:        "The total number of cache hits",
+	})
+
+	cacheMisses := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   config.Namespace,
+		Subsystem:   Subsystem,
+		Name:        "miss_total",
+		ConstLabels: labels,
+		Help:        "The total number of cache misses",
+	})
+
+	return &Metrics{
+		CacheHits:   cacheHits,
+		CacheMisses: cacheMisses,
+		HitRate: prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   Subsystem,
+			Name:        "hit_rate",
+			ConstLabels: labels,
+			Help:        "The rate of hits to misses",
+		}, func() float64 {
+			hits := util.GetMetricValue(cacheHits)
+			misses := util.GetMetricValue(cacheMisses)
+			return hits / (hits + misses)
+		}),
+		CacheFetch: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   Subsystem,
+			Name:        "fetch_duration_seconds",
+			ConstLabels: labels,
+			Help:        "The latency of cache lookups, hit or miss",
+			Buckets:     latencyBuckets,
+		}),
+		UpstreamFetch: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   "upstream",
+			Name:        "fetch_duration_seconds",
+			ConstLabels: labels,
+			Help:        "The latency of upstream tile fetches",
+			Buckets:     latencyBuckets,
+		}),
+		LayerHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   Subsystem,
+			Name:        "layer_hit_total",
+			ConstLabels: labels,
+			Help:        "The total number of hits by cache level",
+		}, []string{"layer"}),
+		LayerMisses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   Subsystem,
+			Name:        "layer_miss_total",
+			ConstLabels: labels,
+			Help:        "The total number of misses by cache level",
+		}, []string{"layer"}),
+		UpstreamStatus: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   "upstream",
+			Name:        "response_total",
+			ConstLabels: labels,
+			Help:        "The total number of upstream responses by status code, or error if the fetch failed",
+		}, []string{"code"}),
+		BytesServed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   "proxy",
+			Name:        "served_bytes_total",
+			ConstLabels: labels,
+			Help:        "The total number of response body bytes sent to clients",
+		}),
+		FlightWaits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   "proxy",
+			Name:        "flight_wait_total",
+			ConstLabels: labels,
+			Help:        "The total number of requests served by another request's upstream fetch",
+		}),
+		InvalidPackets: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   Subsystem,
+			Name:        "invalid_packet_total",
+			ConstLabels: labels,
+			Help:        "The total number of cached tile packets failing validation",
+		}),
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   "proxy",
+			Name:        "request_total",
+			ConstLabels: labels,
+			Help:        "The total number of tile requests by zoom level and endpoint",
+		}, []string{"zoom", "endpoint"}),
+		RedisLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   "redis",
+			Name:        "command_duration_seconds",
+			ConstLabels: labels,
+			Help:        "The latency of redis cache level commands",
+			Buckets:     latencyBuckets,
+		}, []string{"command"}),
+		MemoryEntries: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   "memory",
+			Name:        "entries",
+			ConstLabels: labels,
+			Help:        "The number of entries in the in-memory cache level",
+		}, func() float64 {
+			return float64(c.memoryStats().entries)
+		}),
+		MemoryCollisions: prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   "memory",
+			Name:        "collision_total",
+			ConstLabels: labels,
+			Help:        "The total number of key hash collisions in the in-memory cache level",
+		}, func() float64 {
+			return float64(c.memoryStats().collisions)
+		}),
+		MemoryEvictions: prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   config.Namespace,
+			Subsystem:   "memory",
+			Name:        "eviction_total",
+			ConstLabels: labels,
+			Help:        "The total number of entries evicted from the full in-memory cache level",
+		}, func() float64 {
+			return float64(c.memoryStats().evictions)
+		}),
+	}
+}
+
+// collectors returns every metric for registration
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.CacheHits, m.CacheMisses, m.HitRate, m.CacheFetch, m.UpstreamFetch,
+		m.LayerHits, m.LayerMisses, m.UpstreamStatus, m.BytesServed, m.FlightWaits,
+		m.InvalidPackets, m.Requests, m.RedisLatency, m.MemoryEntries,
+		m.MemoryCollisions, m.MemoryEvictions,
+	}
+}
+
+// register registers every metric with the registry, unregistering those
+// already registered if any of them fails
+func (m *Metrics) register(registry prometheus.Registerer) error {
+	collectors := m.collectors()
+	for i, collector := range collectors {
+		if err := registry.Register(collector); err != nil {
+			for _, registered := range collectors[:i] {
+				registry.Unregister(registered)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// unregister unregisters every metric from the registry
+func (m *Metrics) unregister(registry prometheus.Registerer) {
+	for _, collector := range m.collectors() {
+		registry.Unregister(collector)
+	}
+}
+
+// ObserveUpstream records the duration of an upstream fetch along with its
+// status code, or 0 if the fetch failed
+func (c *Cache) ObserveUpstream(d time.Duration, code int) {
+	c.upstream.observe(d)
+	c.Metrics.UpstreamFetch.Observe(d.Seconds())
+
+	status := "error"
+	if code > 0 {
+		status = strconv.Itoa(code)
+	}
+	c.Metrics.UpstreamStatus.WithLabelValues(status).Inc()
+}
+
+// ObserveRequest counts a tile request by zoom level and, if enabled for the
+// proxy, by endpoint, labeling the first endpoints seen up to the configured
+// limit and counting later endpoints as other
+func (c *Cache) ObserveRequest(zoom int, endpoint string, endpointLabels int) {
+	zoomLabel := otherLabel
+	if zoom >= 0 && zoom <= maxZoomLabel {
+		zoomLabel = strconv.Itoa(zoom)
+	}
+
+	if endpointLabels == 0 {
+		endpoint = ""
+	} else {
+		endpoint = c.Metrics.endpoints.label(endpoint, endpointLabels)
+	}
+
+	c.Metrics.Requests.WithLabelValues(zoomLabel, endpoint).Inc()
+}
+
+// labelSet caps the distinct values of a label
+type labelSet struct {
+	lock sync.Mutex
+	seen map[string]bool
+}
+
+// label returns the value if it was seen before or fewer than limit values
+// have been seen, otherwise the other label
+func (l *labelSet) label(value string, limit int) string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.seen[value] {
+		return value
+	}
+
+	if len(l.seen) >= limit {
+		return otherLabel
+	}
+
+	if l.seen == nil {
+		l.seen = make(map[string]bool)
+	}
+	l.seen[value] = true
+	return value
+}
+
+// memoryStats are usage stats of bigcache
+type memoryStats struct {
+	entries    int
+	collisions int64
+	evictions  uint64
+}
+
+// memoryStats returns usage stats of the proxy's in-memory level, if any
+func (c *Cache) memoryStats() memoryStats {
+	for _, l := range c.getLayers() {
+		if m, ok := unwrap(l.backend).(*memoryBackend); ok {
+			return m.usage()
+		}
+	}
+	return memoryStats{}
+}
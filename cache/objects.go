@@ -0,0 +1,180 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/s3"
+)
+
+// expiresMeta is the object metadata key holding an object's expiry time
+const expiresMeta = "lod-expires"
+
+// objectBackend is a durable cache level backed by S3-compatible object
+// storage, storing entries under the proxy's key prefix
+type objectBackend struct {
+	client *s3.Client // object storage client
+	prefix string     // prefix of every object key
+}
+
+// newObjectBackend initializes an object storage cache level from proxy configuration
+func newObjectBackend(proxy config.Proxy) (*objectBackend, error) {
+	client, err := s3.New(s3.Options{
+		Endpoint:  proxy.Cache.S3Endpoint,
+		Bucket:    proxy.Cache.S3Bucket,
+		Region:    proxy.Cache.S3Region,
+		AccessKey: proxy.Cache.S3AccessKey,
+		SecretKey: proxy.Cache.S3SecretKey,
+		PathStyle: proxy.Cache.S3PathStyle,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// verify the bucket is reachable with the configured credentials
+	if err = client.Ping(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return &objectBackend{
+		client: client,
+		prefix: proxy.Cache.S3Prefix,
+	}, nil
+}
+
+// Name returns the name of the cache level
+func (o *objectBackend) Name() string {
+	return config.LayerS3
+}
+
+// Get returns the entry stored for the given cache key, or nil if there is
+// none or it has expired
+func (o *objectBackend) Get(ctx context.Context, key string, _ time.Duration) ([]byte, error) {
+	object, err := o.client.Get(ctx, o.prefix+key)
+	if err != nil {
+		if _, ok := err.(s3.ErrNotFound); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if expires, ok := object.Metadata[expiresMeta]; ok {
+		unix, errParse := strconv.ParseInt(expires, 10, 64)
+		if errParse == nil && time.Now().Unix() > unix {
+			return nil, nil
+		}
+	}
+
+	return object.Data, nil
+}
+
+// Set stores the entry under the given cache key, recording its expiry in
+// the object's metadata if a TTL is given
+func (o *objectBackend) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	metadata := make(map[string]string)
+	if ttl > 0 {
+		metadata[expiresMeta] = strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	}
+
+	return o.client.Put(ctx, o.prefix+key, data, metadata)
+}
+
+// Delete removes the entry stored for the given cache key
+func (o *objectBackend) Delete(ctx context.Context, key string) error {
+	return o.client.Delete(ctx, o.prefix+key)
+}
+
+// Flush deletes every object stored under the proxy's key prefix
+func (o *objectBackend) Flush(ctx context.Context) error {
+	return o.client.List(ctx, o.prefix, func(keys []string) error {
+		for _, key := range keys {
+			if err := o.client.Delete(ctx, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Scan calls fn with batches of unexpired objects stored under the proxy's
+// key prefix, in the lexicographic order of their keys, using positions in
+// that order as cursors
+func (o *objectBackend) Scan(ctx context.Context, cursor string, count int, filter Filter,
+	fn func(batch []Entry, next string) error) error {
+	b, err := newBatcher(cursor, count, fn)
+	if err != nil {
+		return err
+	}
+
+	err = o.client.List(ctx, o.prefix, func(keys []string) error {
+		for _, key := range keys {
+			if b.skip() {
+				continue
+			}
+
+			entry, err := o.entry(ctx, key, filter)
+			if err != nil {
+				return err
+			}
+			if err = b.add(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.done()
+}
+
+// entry returns the unexpired object with the given key, or nil if it was
+// deleted while listing, is expired, or is rejected by the filter
+func (o *objectBackend) entry(ctx context.Context, key string, filter Filter) (*Entry, error) {
+	if !filter.accept(strings.TrimPrefix(key, o.prefix)) {
+		return nil, nil
+	}
+
+	object, err := o.client.Get(ctx, key)
+	if err != nil {
+		// objects may be deleted while listing
+		if _, ok := err.(s3.ErrNotFound); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var expires time.Time
+	if value, ok := object.Metadata[expiresMeta]; ok {
+		unix, errParse := strconv.ParseInt(value, 10, 64)
+		if errParse == nil {
+			if expires = time.Unix(unix, 0); time.Now().After(expires) {
+				return nil, nil
+			}
+		}
+	}
+
+	return &Entry{
+		Key:     strings.TrimPrefix(key, o.prefix),
+		Data:    object.Data,
+		Expires: expires,
+	}, nil
+}
+
+// Stats returns usage stats, which object storage can't report cheaply
+func (o *objectBackend) Stats() BackendStats {
+	return BackendStats{
+		Entries: -1,
+		Size:    -1,
+		Cap:     -1,
+	}
+}
+
+// Close is a no-op, object storage requests hold no connections open
+func (o *objectBackend) Close() error {
+	return nil
+}
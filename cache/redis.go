@@ -0,0 +1,417 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/util"
+)
+
+// sharedClient is a Redis client shared by every cache using the same
+// connection definition, closed once the last of them releases it
+type sharedClient struct {
+	client redis.UniversalClient
+	refs   int
+}
+
+var (
+	// clientsLock guards the clients map
+	clientsLock sync.Mutex
+	// clients maps connection fingerprints to their shared clients
+	clients = make(map[string]*sharedClient)
+)
+
+// acquireRedis returns the shared client for the given connection, creating
+// and connecting it if no cache holds a reference to it yet
+func acquireRedis(conn *config.RedisConnection) (redis.UniversalClient, error) {
+	clientsLock.Lock()
+	defer clientsLock.Unlock()
+
+	key := conn.Fingerprint()
+	if shared, ok := clients[key]; ok {
+		shared.refs++
+		return shared.client, nil
+	}
+
+	client := newRedisClient(conn)
+
+	// ping Redis to verify connectivity
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+
+	clients[key] = &sharedClient{
+		client: client,
+		refs:   1,
+	}
+
+	util.DebugFlag("cache", str.CCache, str.DRedisConnect, conn.Name, conn.Mode)
+	return client, nil
+}
+
+// releaseRedis drops a reference to the shared client for the given
+// connection fingerprint, closing it once no caches reference it
+func releaseRedis(key string) {
+	clientsLock.Lock()
+	defer clientsLock.Unlock()
+
+	shared, ok := clients[key]
+	if !ok {
+		return
+	}
+
+	shared.refs--
+	if shared.refs > 0 {
+		return
+	}
+
+	delete(clients, key)
+	if err := shared.client.Close(); err != nil {
+		util.Error(str.CCache, str.ERedisClose, err.Error())
+	}
+}
+
+// newRedisClient creates a Redis client for the connection's deployment mode
+func newRedisClient(conn *config.RedisConnection) redis.UniversalClient {
+	switch conn.Mode {
+	case config.RedisModeCluster:
+		return redis.NewClusterClient(conn.Options.Cluster())
+	case config.RedisModeSentinel:
+		return redis.NewFailoverClient(conn.Options.Failover())
+	default:
+		return redis.NewClient(conn.Options.Simple())
+	}
+}
+
+// redisBackend is an external cache level backed by a shared Redis client
+type redisBackend struct {
+	client  redis.UniversalClient    // shared Redis client
+	key     string                   // fingerprint of the shared client's connection
+	pattern string                   // glob pattern matching every key of the proxy
+	latency *prometheus.HistogramVec // command latency of the owning proxy, if instrumented
+}
+
+// newRedisBackend initializes an external cache level from proxy configuration
+func newRedisBackend(proxy config.Proxy) (*redisBackend, error) {
+	client, err := acquireRedis(proxy.Cache.RedisConnection)
+	if err != nil {
+		return nil, err
+	}
+
+	return &redisBackend{
+		client:  client,
+		key:     proxy.Cache.RedisConnection.Fingerprint(),
+		pattern: KeyPattern(proxy),
+	}, nil
+}
+
+// Name returns the name of the cache level
+func (r *redisBackend) Name() string {
+	return config.LayerRedis
+}
+
+// Get returns the entry for the given key, or nil if not present. If a TTL
+// is given, the key's expiry is extended to prevent expiry of tiles that
+// are fetched periodically, otherwise the key is persisted. Keys read with
+// KeepTTL keep their expiry.
+func (r *redisBackend) Get(ctx context.Context, key string, ttl time.Duration) ([]byte, error) {
+	if ttl == KeepTTL {
+		defer r.observe("get", time.Now())
+		return nilIfMissing(r.client.Get(ctx, key).Bytes())
+	}
+
+	defer r.observe("getex", time.Now())
+	return nilIfMissing(r.client.GetEx(ctx, key, ttl).Bytes())
+}
+
+// nilIfMissing returns nil data without an error for keys that don't exist
+func nilIfMissing(data []byte, err error) ([]byte, error) {
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return data, err
+}
+
+// Set stores the entry for the given key with the given TTL
+func (r *redisBackend) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	defer r.observe("set", time.Now())
+	return r.client.Set(ctx, key, data, ttl).Err()
+}
+
+// setOrExtend stores the entry for the given key unless the key exists,
+// setting the key's TTL either way
+func (r *redisBackend) setOrExtend(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	defer r.observe("setnx", time.Now())
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetNX(ctx, key, data, ttl)
+		pipe.PExpire(ctx, key, ttl)
+		return nil
+	})
+	return err
+}
+
+// Delete removes the entry for the given key
+func (r *redisBackend) Delete(ctx context.Context, key string) error {
+	defer r.observe("del", time.Now())
+	return r.client.Del(ctx, key).Err()
+}
+
+// observe records the latency of a command started at the given time
+func (r *redisBackend) observe(command string, start time.Time) {
+	if r.latency != nil {
+		r.latency.WithLabelValues(command).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Flush deletes every key matching the proxy's cache key template, scanning
+// every master shard when running against a Redis Cluster
+func (r *redisBackend) Flush(ctx context.Context) error {
+	return r.deleteMatching(ctx, r.pattern, internalKey)
+}
+
+// deleteMatching deletes every key matching the pattern on every master
+// shard, other than keys for which keep returns true, if given
+func (r *redisBackend) deleteMatching(ctx context.Context, pattern string, keep func(key string) bool) error {
+	return forEachShard(ctx, r.client, func(ctx context.Context, shard *redis.Client) error {
+		iter := shard.Scan(ctx, 0, pattern, scanCount).Iterator()
+		keys := make([]string, 0, scanCount)
+
+		for iter.Next(ctx) {
+			// keep internal keys, which loose key patterns may match
+			if keep != nil && keep(iter.Val()) {
+				continue
+			}
+			keys = append(keys, iter.Val())
+			if len(keys) >= scanCount {
+				if err := deleteKeys(ctx, shard, keys); err != nil {
+					return err
+				}
+				keys = keys[:0]
+			}
+		}
+
+		if err := iter.Err(); err != nil {
+			return err
+		}
+
+		return deleteKeys(ctx, shard, keys)
+	})
+}
+
+// Scan calls fn with batches of keys matching the proxy's cache key
+// template, scanning master shards one at a time in the order of their
+// addresses. Cursors hold the index of the shard being scanned and its SCAN
+// cursor, ex: 1:3072, so they're only valid while the shards are unchanged.
+func (r *redisBackend) Scan(ctx context.Context, cursor string, count int, filter Filter,
+	fn func(batch []Entry, next string) error) error {
+	shards, err := shardsOf(ctx, r.client)
+	if err != nil {
+		return err
+	}
+
+	index, position, err := parseCursor(cursor, len(shards))
+	if err != nil {
+		return err
+	}
+
+	if count <= 0 {
+		count = scanCount
+	}
+
+	for ; index < len(shards); index, position = index+1, 0 {
+		for {
+			keys, next, errScan := shards[index].Scan(ctx, position, r.pattern, int64(count)).Result()
+			if errScan != nil {
+				return errScan
+			}
+
+			batch := make([]Entry, 0, len(keys))
+			for _, key := range keys {
+				if !internalKey(key) && filter.accept(key) {
+					batch = append(batch, Entry{Key: key})
+				}
+			}
+
+			if batch, err = readBatch(ctx, shards[index], batch); err != nil {
+				return err
+			}
+
+			// resume from the next shard once this one is complete
+			resume := ""
+			if next != 0 {
+				resume = fmt.Sprintf("%d:%d", index, next)
+			} else if index+1 < len(shards) {
+				resume = fmt.Sprintf("%d:0", index+1)
+			}
+
+			if err = fn(batch, resume); err != nil {
+				return err
+			}
+
+			if next == 0 {
+				break
+			}
+			position = next
+		}
+	}
+
+	return nil
+}
+
+// parseCursor returns the shard index and SCAN cursor held by a cursor
+func parseCursor(cursor string, shards int) (int, uint64, error) {
+	if cursor == "" {
+		return 0, 0, nil
+	}
+
+	shard, position, ok := strings.Cut(cursor, ":")
+	index, errIndex := strconv.Atoi(shard)
+	scan, errScan := strconv.ParseUint(position, 10, 64)
+	if !ok || errIndex != nil || errScan != nil || index < 0 || index >= shards {
+		return 0, 0, ErrInvalidCursor{Cursor: cursor}
+	}
+
+	return index, scan, nil
+}
+
+// readBatch reads the values and TTLs of a batch of scanned keys in a
+// pipeline, returning the entries that still exist
+func readBatch(ctx context.Context, shard *redis.Client, batch []Entry) ([]Entry, error) {
+	if len(batch) == 0 {
+		return batch, nil
+	}
+
+	values := make([]*redis.StringCmd, len(batch))
+	ttls := make([]*redis.DurationCmd, len(batch))
+
+	_, err := shard.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, entry := range batch {
+			values[i] = pipe.Get(ctx, entry.Key)
+			ttls[i] = pipe.PTTL(ctx, entry.Key)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	read := batch[:0]
+	for i, entry := range batch {
+		// skip keys that expired or were deleted since being scanned
+		data, errGet := values[i].Bytes()
+		if errGet != nil {
+			continue
+		}
+
+		entry.Data = data
+		if ttl := ttls[i].Val(); ttl > 0 {
+			entry.Expires = time.Now().Add(ttl)
+		}
+		read = append(read, entry)
+	}
+
+	return read, nil
+}
+
+// internalKey returns true for keys LOD keeps alongside cached tiles, which
+// loose key patterns may match
+func internalKey(key string) bool {
+	return strings.HasPrefix(key, generationPrefix) || strings.HasPrefix(key, tagPrefix) ||
+		strings.HasPrefix(key, hotPrefix) || strings.HasPrefix(key, blobPrefix) ||
+		strings.HasPrefix(key, heatPrefix) || strings.HasPrefix(key, lockPrefix)
+}
+
+// Stats returns usage stats, which Redis can't report per proxy
+func (r *redisBackend) Stats() BackendStats {
+	return BackendStats{
+		Entries: -1,
+		Size:    -1,
+		Cap:     -1,
+	}
+}
+
+// Close releases this backend's reference to the shared client
+func (r *redisBackend) Close() error {
+	releaseRedis(r.key)
+	return nil
+}
+
+// scanCount is the number of keys requested per SCAN iteration
+const scanCount = 500
+
+// templateParam matches parameter tokens within cache key templates
+var templateParam = regexp.MustCompile(`\{[^}]+}`)
+
+// KeyPattern returns a glob pattern matching every cache key that the
+// proxy's cache key template can produce, including the generation prefix
+// of versioned proxies
+func KeyPattern(proxy config.Proxy) string {
+	pattern := templateParam.ReplaceAllString(proxy.Cache.KeyTemplate, "*")
+	if proxy.Cache.Versioned {
+		return "v*:" + pattern
+	}
+	return pattern
+}
+
+// forEachShard calls fn with a client for every shard of the given client,
+// each master node for Redis Cluster, or the single server otherwise
+func forEachShard(ctx context.Context, client redis.UniversalClient,
+	fn func(ctx context.Context, shard *redis.Client) error) error {
+	switch client := client.(type) {
+	case *redis.ClusterClient:
+		return client.ForEachMaster(ctx, fn)
+	case *redis.Client:
+		return fn(ctx, client)
+	}
+
+	return nil
+}
+
+// shardsOf returns a client for every shard of the given client, ordered by
+// address so that shards are always visited in the same order
+func shardsOf(ctx context.Context, client redis.UniversalClient) ([]*redis.Client, error) {
+	lock := sync.Mutex{}
+	shards := make([]*redis.Client, 0)
+
+	err := forEachShard(ctx, client, func(_ context.Context, shard *redis.Client) error {
+		lock.Lock()
+		defer lock.Unlock()
+		shards = append(shards, shard)
+		return nil
+	})
+
+	sort.Slice(shards, func(i, j int) bool {
+		return shards[i].Options().Addr < shards[j].Options().Addr
+	})
+
+	return shards, err
+}
+
+// deleteKeys deletes the given keys individually in a pipeline, since a
+// multi-key DEL fails when the keys hash to different cluster slots
+func deleteKeys(ctx context.Context, shard *redis.Client, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	_, err := shard.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		return nil
+	})
+
+	return err
+}
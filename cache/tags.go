@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/util"
+)
+
+// tagPrefix prefixes the Redis sets indexing cache keys by tag
+const tagPrefix = "lod:tag:"
+
+// tagIndexCap is the maximum number of keys indexed by tag in memory
+const tagIndexCap = 1000000
+
+// tagIndex indexes the keys of tiles cached by this instance by the
+// surrogate keys, or tags, they were served with
+type tagIndex struct {
+	lock sync.Mutex                     // guards tags and keys
+	tags map[string]map[string]struct{} // keys by tag
+	keys map[string][]string            // tags by key
+}
+
+// add indexes the key under each of the given tags
+func (t *tagIndex) add(key string, tags []string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.tags == nil {
+		t.tags = make(map[string]map[string]struct{})
+		t.keys = make(map[string][]string)
+	}
+
+	if _, ok := t.keys[key]; !ok && len(t.keys) >= tagIndexCap {
+		return false
+	}
+
+	t.unlink(key)
+	t.keys[key] = tags
+	for _, tag := range tags {
+		if t.tags[tag] == nil {
+			t.tags[tag] = make(map[string]struct{})
+		}
+		t.tags[tag][key] = struct{}{}
+	}
+
+	return true
+}
+
+// remove drops the key from the index
+func (t *tagIndex) remove(key string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.unlink(key)
+}
+
+// take drops every key indexed under the tag from the index and returns them
+func (t *tagIndex) take(tag string) []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	keys := make([]string, 0, len(t.tags[tag]))
+	for key := range t.tags[tag] {
+		keys = append(keys, key)
+	}
+
+	for _, key := range keys {
+		t.unlink(key)
+	}
+
+	return keys
+}
+
+// reset drops every key from the index
+func (t *tagIndex) reset() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.tags = nil
+	t.keys = nil
+}
+
+// unlink drops the key from the index, the lock must be held
+func (t *tagIndex) unlink(key string) {
+	for _, tag := range t.keys[key] {
+		delete(t.tags[tag], key)
+		if len(t.tags[tag]) == 0 {
+			delete(t.tags, tag)
+		}
+	}
+	delete(t.keys, key)
+}
+
+// tagKey returns the key of the Redis set indexing the proxy's keys by tag
+func tagKey(proxy, tag string) string {
+	return tagPrefix + proxy + ":" + tag
+}
+
+// tagShared indexes the key by the given tags in Redis so that any instance
+// can purge the tagged tiles from all shared cache levels. The sets expire
+// no sooner than any tile they index, and never if any shared level keeps
+// tiles without expiry. Failures are logged, returning the last of them.
+func (c *Cache) tagShared(ctx context.Context, key string, tags []string) error {
+	client := c.Redis()
+	if client == nil {
+		return nil
+	}
+
+	ttl := c.Proxy().Cache.MaxSharedTTL()
+
+	var failed error
+
+	// index tags individually since their sets may live in different cluster slots
+	for _, tag := range tags {
+		_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SAdd(ctx, tagKey(c.Proxy().Name, tag), key)
+			if ttl > 0 {
+				pipe.Expire(ctx, tagKey(c.Proxy().Name, tag), ttl)
+			}
+			return nil
+		})
+		if err != nil {
+			util.Error(str.CCache, str.ECacheTag, key, tag, err.Error())
+			failed = err
+		}
+	}
+
+	return failed
+}
+
+// InvalidateTag removes every tile tagged with the given tag from all cache
+// levels, returning the number of tiles purged. Tiles in shared levels are
+// found through the tag's Redis set, and tiles in local levels through
+// this instance's index. Peers must purge their local levels themselves.
+func (c *Cache) InvalidateTag(ctx context.Context, tag string) (int, error) {
+	keys := make(map[string]struct{})
+	for _, key := range c.tags.take(tag) {
+		keys[key] = struct{}{}
+	}
+
+	if client := c.Redis(); client != nil {
+		members, err := client.SMembers(ctx, tagKey(c.Proxy().Name, tag)).Result()
+		if err != nil {
+			return 0, err
+		}
+
+		for _, key := range members {
+			keys[key] = struct{}{}
+		}
+	}
+
+	for key := range keys {
+		if err := c.Invalidate(key, ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	if client := c.Redis(); client != nil {
+		if err := client.Del(ctx, tagKey(c.Proxy().Name, tag)).Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(keys), nil
+}
+
+// InvalidateTagInternal removes every tile tagged with the given tag from
+// the cache levels local to this instance, returning the number of tiles purged
+func (c *Cache) InvalidateTagInternal(tag string) (int, error) {
+	keys := c.tags.take(tag)
+
+	for _, key := range keys {
+		if err := c.InvalidateInternal(key); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(keys), nil
+}
@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// windowSize is the number of one second slots kept by rolling windows
+	windowSize = 60
+
+	// latencyBucketCount is the number of latency buckets
+	latencyBucketCount = 32
+)
+
+// latencyBuckets are the upper bounds in seconds of the latency buckets of
+// rolling windows and their Prometheus histograms, from 0.1ms to about 29s
+var latencyBuckets = prometheus.ExponentialBuckets(0.0001, 1.5, latencyBucketCount)
+
+// window is a rolling window of latency samples over the past minute, kept
+// as a histogram per second so memory use is fixed regardless of traffic
+type window struct {
+	lock  sync.Mutex
+	slots [windowSize]slot
+}
+
+// slot holds the samples observed within one second
+type slot struct {
+	second  int64 // unix second of the samples, reset when reused
+	summary       // samples observed within the second
+}
+
+// summary is a latency histogram, which can be merged with other summaries
+type summary struct {
+	count   uint64                         // number of samples
+	sum     float64                        // sum of samples in seconds
+	buckets [latencyBucketCount + 1]uint64 // samples by bucket, with a final overflow bucket
+}
+
+// observe records a sample in the slot of the current second
+func (w *window) observe(d time.Duration) {
+	now := time.Now().Unix()
+	seconds := d.Seconds()
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	s := &w.slots[now%windowSize]
+	if s.second != now {
+		*s = slot{second: now}
+	}
+
+	s.count++
+	s.sum += seconds
+	s.buckets[sort.SearchFloat64s(latencyBuckets, seconds)]++
+}
+
+// summary merges the slots within the past minute
+func (w *window) summary() summary {
+	now := time.Now().Unix()
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var merged summary
+	for i := range w.slots {
+		if now-w.slots[i].second < windowSize {
+			merged.merge(w.slots[i].summary)
+		}
+	}
+	return merged
+}
+
+// merge adds the samples of another summary
+func (s *summary) merge(other summary) {
+	s.count += other.count
+	s.sum += other.sum
+	for i := range s.buckets {
+		s.buckets[i] += other.buckets[i]
+	}
+}
+
+// rate returns the average number of samples per second
+func (s summary) rate() float64 {
+	return float64(s.count) / windowSize
+}
+
+// latency returns the average, 75th and 99th percentile latencies
+func (s summary) latency() Latency {
+	if s.count == 0 {
+		return Latency{}
+	}
+
+	return Latency{
+		Avg: s.sum / float64(s.count) * 1000,
+		P75: s.quantile(0.75) * 1000,
+		P99: s.quantile(0.99) * 1000,
+	}
+}
+
+// quantile estimates the given quantile in seconds, interpolating linearly
+// within the bucket it falls in
+func (s summary) quantile(q float64) float64 {
+	rank := q * float64(s.count)
+
+	var seen float64
+	for i, n := range s.buckets {
+		if n == 0 || seen+float64(n) < rank {
+			seen += float64(n)
+			continue
+		}
+
+		// samples beyond the last bound are reported at the last bound
+		if i == latencyBucketCount {
+			return latencyBuckets[i-1]
+		}
+
+		lower := 0.0
+		if i > 0 {
+			lower = latencyBuckets[i-1]
+		}
+		return lower + (latencyBuckets[i]-lower)*(rank-seen)/float64(n)
+	}
+
+	return 0
+}
+
+// Latency holds latencies in milliseconds over the past minute
+type Latency struct {
+	Avg float64 // average latency
+	P75 float64 // 75th percentile latency
+	P99 float64 // 99th percentile latency
+}
+
+// Traffic holds the request rate and latencies of one or more proxies over
+// the past minute
+type Traffic struct {
+	TPS      float64 // average tiles requested per second
+	Cache    Latency // latency of cache lookups, hit or miss
+	Upstream Latency // latency of upstream fetches
+}
+
+// Traffic returns the proxy's request rate and latencies over the past minute
+func (c *Cache) Traffic() Traffic {
+	lookups := c.lookups.summary()
+	return Traffic{
+		TPS:      lookups.rate(),
+		Cache:    lookups.latency(),
+		Upstream: c.upstream.summary().latency(),
+	}
+}
+
+// TotalTraffic returns the request rate and latencies of every proxy
+// combined over the past minute
+func TotalTraffic() Traffic {
+	var lookups, upstream summary
+	for _, c := range All() {
+		lookups.merge(c.lookups.summary())
+		upstream.merge(c.upstream.summary())
+	}
+
+	return Traffic{
+		TPS:      lookups.rate(),
+		Cache:    lookups.latency(),
+		Upstream: upstream.latency(),
+	}
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// TestWindow will test that rolling windows report the rate and latency
+// percentiles of the samples observed within the past minute
+func TestWindow(t *testing.T) {
+	var w window
+	for i := 1; i <= 100; i++ {
+		w.observe(time.Duration(i) * time.Millisecond)
+	}
+
+	s := w.summary()
+	if s.count != 100 || s.rate() != 100.0/windowSize {
+		t.Fatalf("expected 100 samples, got %d", s.count)
+	}
+
+	latency := s.latency()
+	if latency.Avg < 50 || latency.Avg > 51 {
+		t.Errorf("expected average of 50.5ms, got %f", latency.Avg)
+	}
+
+	// bucket bounds grow by half, so estimates are within that of the truth
+	if latency.P75 < 75/1.5 || latency.P75 > 75*1.5 {
+		t.Errorf("expected 75th percentile near 75ms, got %f", latency.P75)
+	}
+	if latency.P99 < 99/1.5 || latency.P99 > 99*1.5 {
+		t.Errorf("expected 99th percentile near 99ms, got %f", latency.P99)
+	}
+
+	// samples older than the window are dropped
+	for i := range w.slots {
+		w.slots[i].second -= windowSize
+	}
+	if s = w.summary(); s.count != 0 {
+		t.Errorf("expected expired samples to be dropped, got %d", s.count)
+	}
+}
+
+// TestTotalTrafficReload will test that combined traffic can be read while
+// reloads add and delete cache instances
+func TestTotalTrafficReload(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			TotalTraffic()
+		}
+	}()
+
+	for i := 0; i < 1000; i++ {
+		c, _, _ := newTestCache()
+		cachesLock.Lock()
+		Caches[c.Proxy().Name] = c
+		cachesLock.Unlock()
+
+		// no proxies are configured, so every cache instance is deleted
+		WipeOldCaches()
+	}
+
+	<-done
+}
@@ -0,0 +1,255 @@
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/util"
+)
+
+// InstanceID uniquely identifies this LOD instance within the cluster
+var InstanceID = genInstanceID()
+
+var (
+	// lock guards the cluster connection state below
+	lock sync.RWMutex
+	// client is the Redis client used for cluster membership
+	client *redis.Client
+	// bus carries commands and acknowledgements between peers
+	bus transport
+	// unsubscribe from the cluster command channel
+	unsubscribe func()
+	// stopHeartbeat stops refreshing this instance's registration
+	stopHeartbeat context.CancelFunc
+	// settings of the currently connected cluster
+	settings config.Cluster
+)
+
+// Init connects to the configured cluster channel and begins applying
+// commands broadcast by peers. Any existing cluster connection is closed
+// first, so Init is safe to call again after a configuration reload.
+func Init() error {
+	Stop()
+
+	cluster := config.Get().Instance.Cluster
+	if !cluster.Enabled {
+		return nil
+	}
+
+	if cluster.RedisTLS {
+		cluster.RedisOpts.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	newClient := redis.NewClient(cluster.RedisOpts)
+
+	// ping Redis to verify connectivity
+	if err := newClient.Ping(context.Background()).Err(); err != nil {
+		_ = newClient.Close()
+		return ErrInitCluster{Err: err}
+	}
+
+	newBus := redisTransport{client: newClient}
+
+	// wait for subscription confirmation before accepting commands
+	messages, unsubscribeCommands, err := newBus.Subscribe(context.Background(), cluster.Channel)
+	if err != nil {
+		_ = newClient.Close()
+		return ErrInitCluster{Err: err}
+	}
+
+	heartbeatCtx, cancel := context.WithCancel(context.Background())
+
+	lock.Lock()
+	client = newClient
+	bus = newBus
+	unsubscribe = unsubscribeCommands
+	settings = cluster
+	stopHeartbeat = cancel
+	lock.Unlock()
+
+	go listen(newBus, messages)
+
+	// register this instance in the cluster membership registry
+	go heartbeat(heartbeatCtx, newClient, cluster)
+
+	util.Info(str.CCluster, str.MClusterJoined, InstanceID, cluster.Channel)
+	return nil
+}
+
+// Stop deregisters this instance and closes the cluster connection if one is open
+func Stop() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if stopHeartbeat != nil {
+		stopHeartbeat()
+		stopHeartbeat = nil
+	}
+
+	if unsubscribe != nil {
+		unsubscribe()
+		unsubscribe = nil
+	}
+	bus = nil
+
+	if client != nil {
+		// remove our registration so peers stop listing this instance
+		_ = client.Del(context.Background(), memberKey(settings, InstanceID)).Err()
+		_ = client.Close()
+		client = nil
+	}
+}
+
+// Enabled returns true if this instance is connected to a cluster
+func Enabled() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	return bus != nil
+}
+
+// listen applies commands received on the cluster channel until it closes
+func listen(t transport, messages <-chan string) {
+	for message := range messages {
+		var cmd Command
+		if err := json.Unmarshal([]byte(message), &cmd); err != nil {
+			util.Error(str.CCluster, str.EClusterDecode, err.Error())
+			continue
+		}
+
+		// ignore our own broadcasts, they're applied before being sent
+		if cmd.Origin == InstanceID {
+			continue
+		}
+
+		go respond(t, cmd)
+	}
+}
+
+// respond applies a command received from a peer and publishes an
+// acknowledgement to the command's reply channel
+func respond(t transport, cmd Command) {
+	tiles, err := apply(cmd)
+
+	ack := Ack{
+		Instance: InstanceID,
+		OK:       err == nil,
+		Tiles:    tiles,
+	}
+
+	if err != nil {
+		ack.Error = err.Error()
+		util.Error(str.CCluster, str.EClusterApply, cmd.Op, cmd.Origin, err.Error())
+	} else {
+		util.Info(str.CCluster, str.MClusterApplied, cmd.Op, cmd.Proxy, cmd.Origin)
+	}
+
+	payload, err := json.Marshal(ack)
+	if err != nil {
+		util.Error(str.CCluster, str.EClusterAck, cmd.ID, err.Error())
+		return
+	}
+
+	if _, err = t.Publish(context.Background(), cmd.ReplyTo, payload); err != nil {
+		util.Error(str.CCluster, str.EClusterAck, cmd.ID, err.Error())
+	}
+}
+
+// Broadcast publishes the command to all peers in the cluster and waits for
+// their acknowledgements until all receivers respond or the configured
+// acknowledgement timeout elapses
+func Broadcast(ctx context.Context, cmd Command) BroadcastResult {
+	lock.RLock()
+	t := bus
+	cluster := settings
+	lock.RUnlock()
+
+	cmd.ID = genID()
+	cmd.Origin = InstanceID
+	cmd.ReplyTo = fmt.Sprintf("%s:ack:%s", cluster.Channel, cmd.ID)
+
+	result := BroadcastResult{
+		Instance:     InstanceID,
+		Acknowledged: make([]Ack, 0),
+	}
+
+	if t == nil {
+		result.Error = "cluster not connected"
+		return result
+	}
+
+	payload, err := json.Marshal(cmd)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	// subscribe to the reply channel before publishing so no ack is missed
+	acks, unsubscribeReplies, err := t.Subscribe(ctx, cmd.ReplyTo)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer unsubscribeReplies()
+
+	receivers, err := t.Publish(ctx, cluster.Channel, payload)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	// every receiver other than ourselves is expected to acknowledge
+	expected := receivers - 1
+	timeout := time.NewTimer(cluster.AckTimeoutDuration)
+	defer timeout.Stop()
+
+	for len(result.Acknowledged) < expected {
+		select {
+		case message := <-acks:
+			var ack Ack
+			if errAck := json.Unmarshal([]byte(message), &ack); errAck != nil {
+				util.Error(str.CCluster, str.EClusterDecode, errAck.Error())
+				continue
+			}
+			result.Acknowledged = append(result.Acknowledged, ack)
+		case <-timeout.C:
+			result.Missing = expected - len(result.Acknowledged)
+			util.Error(str.CCluster, str.EClusterTimeout, cmd.Op, result.Missing)
+			return result
+		case <-ctx.Done():
+			result.Missing = expected - len(result.Acknowledged)
+			return result
+		}
+	}
+
+	util.Info(str.CCluster, str.MClusterBroadcast, cmd.Op, cmd.Proxy, len(result.Acknowledged))
+	return result
+}
+
+// genInstanceID generates a unique ID for this instance from its hostname
+func genInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "lod"
+	}
+	return fmt.Sprintf("%s-%s", hostname, genID()[:8])
+}
+
+// genID generates a random hex identifier
+func genID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,337 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/packet"
+	"github.com/dechristopher/lod/tile"
+)
+
+// fakeTransport delivers messages between subscribers in memory
+type fakeTransport struct {
+	lock        sync.Mutex
+	subscribers map[string][]chan string
+}
+
+// newFakeTransport returns an empty fakeTransport
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{subscribers: make(map[string][]chan string)}
+}
+
+func (f *fakeTransport) Subscribe(_ context.Context, channel string) (<-chan string, func(), error) {
+	messages := make(chan string, 16)
+
+	f.lock.Lock()
+	f.subscribers[channel] = append(f.subscribers[channel], messages)
+	f.lock.Unlock()
+
+	return messages, func() {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+
+		subscribers := f.subscribers[channel]
+		for i, subscriber := range subscribers {
+			if subscriber == messages {
+				f.subscribers[channel] = append(subscribers[:i], subscribers[i+1:]...)
+				close(messages)
+				return
+			}
+		}
+	}, nil
+}
+
+func (f *fakeTransport) Publish(_ context.Context, channel string, payload []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, subscriber := range f.subscribers[channel] {
+		subscriber <- string(payload)
+	}
+	return len(f.subscribers[channel]), nil
+}
+
+// connect points the cluster at the transport until the test completes
+func connect(t *testing.T, fake transport, ackTimeout time.Duration) {
+	lock.Lock()
+	bus = fake
+	settings = config.Cluster{Channel: "lod", AckTimeoutDuration: ackTimeout}
+	lock.Unlock()
+
+	t.Cleanup(func() {
+		lock.Lock()
+		bus = nil
+		settings = config.Cluster{}
+		lock.Unlock()
+	})
+}
+
+// peer subscribes to the command channel like another instance would,
+// acknowledging the commands it receives if ack is set
+func peer(t *testing.T, f *fakeTransport, ack bool) {
+	messages, unsubscribe, _ := f.Subscribe(context.Background(), "lod")
+	t.Cleanup(unsubscribe)
+
+	go func() {
+		for message := range messages {
+			var cmd Command
+			if err := json.Unmarshal([]byte(message), &cmd); err != nil || !ack {
+				continue
+			}
+			go respond(f, cmd)
+		}
+	}()
+}
+
+// TestBroadcastAcks will test that broadcasts wait on the acknowledgement
+// of every peer, but not on their own
+func TestBroadcastAcks(t *testing.T) {
+	f := newFakeTransport()
+	connect(t, f, 5*time.Second)
+
+	// this instance ignores its own broadcasts
+	self, unsubscribe, _ := f.Subscribe(context.Background(), "lod")
+	defer unsubscribe()
+	go listen(f, self)
+
+	peer(t, f, true)
+	peer(t, f, true)
+
+	result := Broadcast(context.Background(), Command{Op: OpFlush})
+	if result.Error != "" || result.Missing != 0 || len(result.Acknowledged) != 2 {
+		t.Fatalf("expected two acknowledgements, got %+v", result)
+	}
+
+	for _, ack := range result.Acknowledged {
+		if !ack.OK {
+			t.Errorf("expected successful acknowledgement, got %+v", ack)
+		}
+	}
+}
+
+// TestBroadcastTimeout will test that peers failing to acknowledge a
+// broadcast within the acknowledgement timeout are reported missing
+func TestBroadcastTimeout(t *testing.T) {
+	f := newFakeTransport()
+	connect(t, f, 100*time.Millisecond)
+
+	self, unsubscribe, _ := f.Subscribe(context.Background(), "lod")
+	defer unsubscribe()
+	go listen(f, self)
+
+	peer(t, f, true)
+	peer(t, f, false)
+
+	start := time.Now()
+	result := Broadcast(context.Background(), Command{Op: OpFlush})
+	if result.Missing != 1 || len(result.Acknowledged) != 1 {
+		t.Errorf("expected one acknowledgement and one missing, got %+v", result)
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected broadcast to give up after the ack timeout, took %s", elapsed)
+	}
+
+	// failed commands are acknowledged with their error
+	result = Broadcast(context.Background(), Command{Op: "unknown"})
+	if len(result.Acknowledged) != 1 || result.Acknowledged[0].OK || result.Acknowledged[0].Error == "" {
+		t.Errorf("expected failed acknowledgement, got %+v", result)
+	}
+}
+
+// TestBroadcastNotConnected will test that broadcasts fail without a cluster
+func TestBroadcastNotConnected(t *testing.T) {
+	if result := Broadcast(context.Background(), Command{Op: OpFlush}); result.Error == "" {
+		t.Errorf("expected broadcast to fail while not connected, got %+v", result)
+	}
+}
+
+// testCache builds an in-memory cache for the named proxy, along with an
+// object storage level shared with other instances if requested, and
+// registers it until the test completes
+func testCache(t *testing.T, name, upstream string, shared bool) *cache.Cache {
+	proxy := config.Proxy{
+		Name:       name,
+		TileURL:    upstream + "/{z}/{x}/{y}.pbf",
+		NumWorkers: 2,
+		Cache: config.Cache{
+			KeyTemplate:    "{z}/{x}/{y}",
+			Layers:         []string{config.LayerMemory},
+			MemEnabled:     true,
+			MemCap:         64,
+			MemTTLDuration: time.Hour,
+		},
+	}
+
+	if shared {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		proxy.Cache.Layers = append(proxy.Cache.Layers, config.LayerS3)
+		proxy.Cache.S3Enabled = true
+		proxy.Cache.S3Endpoint = server.URL
+		proxy.Cache.S3Bucket = "tiles"
+		proxy.Cache.S3PathStyle = true
+		proxy.Cache.S3Prefix = name + "/"
+	}
+
+	c, err := cache.New(proxy)
+	if err != nil {
+		t.Fatalf("failed to build cache: %s", err)
+	}
+
+	proxies := config.Get().Proxies
+	config.Get().Proxies = append(proxies, proxy)
+	cache.Caches[name] = c
+	t.Cleanup(func() {
+		config.Get().Proxies = proxies
+		delete(cache.Caches, name)
+	})
+
+	return c
+}
+
+// memoryKeys returns the keys held in the memory level of the cache
+func memoryKeys(t *testing.T, c *cache.Cache) map[string]bool {
+	page, err := c.Keys(context.Background(), config.LayerMemory, "*", "", 1000)
+	if err != nil {
+		t.Fatalf("failed to list keys: %s", err)
+	}
+
+	keys := make(map[string]bool)
+	for _, key := range page.Keys {
+		keys[key.Key] = true
+	}
+	return keys
+}
+
+// store caches a tile under each key, tagged with the given tag
+func store(c *cache.Cache, tag string, keys ...string) {
+	for _, key := range keys {
+		c.Set(key, packet.Encode([]byte("tile"), map[string]string{}), nil, []string{tag}, true)
+	}
+}
+
+// TestApply will test applying every operation received from peers to the
+// local cache levels
+func TestApply(t *testing.T) {
+	fetched := make(chan string, 16)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched <- r.URL.Path
+		_, _ = w.Write([]byte("tile"))
+	}))
+	defer upstream.Close()
+
+	local := testCache(t, "local", upstream.URL, false)
+	shared := testCache(t, "shared", upstream.URL, true)
+	root := tile.Tile{Zoom: 1, X: 0, Y: 0}
+	tiles := root.DeepChildren(2)
+
+	t.Run("flush", func(t *testing.T) {
+		store(local, "a", "1/0/0")
+		if _, err := apply(Command{Op: OpFlush, Proxy: "local"}); err != nil {
+			t.Fatalf("failed to flush: %s", err)
+		}
+		if keys := memoryKeys(t, local); len(keys) != 0 {
+			t.Errorf("expected flushed cache, got %v", keys)
+		}
+	})
+
+	t.Run("invalidate", func(t *testing.T) {
+		store(shared, "a", "1/0/0", "2/1/1", "3/0/0")
+		n, err := apply(Command{Op: OpInvalidate, Proxy: "shared", Tile: root, MaxZoom: 2})
+		if err != nil || n != len(tiles) {
+			t.Fatalf("expected %d tiles invalidated, got %d error=%v", len(tiles), n, err)
+		}
+		if keys := memoryKeys(t, shared); len(keys) != 1 || !keys["3/0/0"] {
+			t.Errorf("expected only tiles beyond max zoom to remain, got %v", keys)
+		}
+		_, _ = shared.DeleteKeysInternal("*")
+	})
+
+	t.Run("prime shared", func(t *testing.T) {
+		store(shared, "a", "1/0/0")
+		n, err := apply(Command{Op: OpPrime, Proxy: "shared", Tile: root, MaxZoom: 2})
+		if err != nil || n != len(tiles) {
+			t.Fatalf("expected %d tiles primed, got %d error=%v", len(tiles), n, err)
+		}
+		// the originating instance primed the shared levels already
+		if len(fetched) != 0 {
+			t.Errorf("expected no upstream fetches, got %d", len(fetched))
+		}
+		if keys := memoryKeys(t, shared); len(keys) != 0 {
+			t.Errorf("expected stale local copies to be dropped, got %v", keys)
+		}
+	})
+
+	t.Run("prime local", func(t *testing.T) {
+		n, err := apply(Command{Op: OpPrime, Proxy: "local", Tile: root, MaxZoom: 2})
+		if err != nil || n != len(tiles) {
+			t.Fatalf("expected %d tiles primed, got %d error=%v", len(tiles), n, err)
+		}
+		if len(fetched) != len(tiles) {
+			t.Errorf("expected %d upstream fetches, got %d", len(tiles), len(fetched))
+		}
+		keys := memoryKeys(t, local)
+		for _, primed := range tiles {
+			if !keys[primed.InjectString("{z}/{x}/{y}")] {
+				t.Errorf("expected tile %s to be primed, got %v", primed.String(), keys)
+			}
+		}
+		_, _ = local.DeleteKeysInternal("*")
+	})
+
+	t.Run("generation", func(t *testing.T) {
+		if _, err := apply(Command{Op: OpGeneration, Proxy: "local"}); err != nil {
+			t.Errorf("failed to forget generations: %s", err)
+		}
+		if _, err := apply(Command{Op: OpGeneration, Proxy: "missing"}); err == nil {
+			t.Error("expected unknown proxy to fail")
+		}
+	})
+
+	t.Run("purge_tag", func(t *testing.T) {
+		store(local, "a", "1/0/0", "1/1/0")
+		store(local, "b", "1/0/1")
+		n, err := apply(Command{Op: OpPurgeTag, Proxy: "local", Tag: "a"})
+		if err != nil || n != 2 {
+			t.Fatalf("expected 2 tiles purged, got %d error=%v", n, err)
+		}
+		if keys := memoryKeys(t, local); len(keys) != 1 || !keys["1/0/1"] {
+			t.Errorf("expected only untagged tiles to remain, got %v", keys)
+		}
+		_, _ = local.DeleteKeysInternal("*")
+	})
+
+	t.Run("delete_keys", func(t *testing.T) {
+		store(local, "a", "1/0/0", "1/1/0", "2/0/0")
+		n, err := apply(Command{Op: OpDeleteKeys, Proxy: "local", Match: "1/*"})
+		if err != nil || n != 2 {
+			t.Fatalf("expected 2 keys deleted, got %d error=%v", n, err)
+		}
+		if keys := memoryKeys(t, local); len(keys) != 1 || !keys["2/0/0"] {
+			t.Errorf("expected only unmatched keys to remain, got %v", keys)
+		}
+		_, _ = local.DeleteKeysInternal("*")
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		if _, err := apply(Command{Op: "unknown", Proxy: "local"}); err == nil {
+			t.Error("expected unknown operation to fail")
+		}
+		if _, err := apply(Command{Op: OpInvalidate, Proxy: "missing"}); err == nil {
+			t.Error("expected unknown proxy to fail")
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/helpers"
+	"github.com/dechristopher/lod/jobs"
+	"github.com/dechristopher/lod/tile"
+)
+
+// Operations that can be broadcast across the cluster
+const (
+	OpFlush      = "flush"       // flush the in-memory cache of a proxy, or all proxies
+	OpInvalidate = "invalidate"  // invalidate a tile and its children up to a max zoom
+	OpPrime      = "prime"       // re-prime a tile and its children up to a max zoom
+	OpGeneration = "generation"  // re-read the namespace generations of a proxy after a bump
+	OpPurgeTag   = "purge_tag"   // purge every tile tagged with a tag from the local caches
+	OpDeleteKeys = "delete_keys" // delete every key matching a glob pattern from the local caches
+)
+
+// Command is an administrative operation broadcast to all cluster peers
+type Command struct {
+	ID       string            `json:"id"`                 // unique ID of this command
+	Origin   string            `json:"origin"`             // ID of the instance that issued the command
+	ReplyTo  string            `json:"reply_to"`           // channel to publish acknowledgements to
+	Op       string            `json:"op"`                 // operation to perform
+	Proxy    string            `json:"proxy,omitempty"`    // proxy to operate on, empty to flush all
+	Tile     tile.Tile         `json:"tile"`               // root tile to invalidate or prime
+	MaxZoom  int               `json:"max_zoom"`           // max zoom to deepen to from the root tile
+	Endpoint string            `json:"endpoint,omitempty"` // dynamic endpoint value used to build cache keys
+	Params   map[string]string `json:"params,omitempty"`   // URL parameter values used to build cache keys
+	Tag      string            `json:"tag,omitempty"`      // tag of the tiles to purge
+	Match    string            `json:"match,omitempty"`    // glob pattern of the keys to delete
+}
+
+// Ack is a peer's acknowledgement of a broadcast Command
+type Ack struct {
+	Instance string `json:"instance"`        // ID of the acknowledging instance
+	OK       bool   `json:"ok"`              // whether the command was applied successfully
+	Tiles    int    `json:"tiles"`           // number of tiles the command was applied to
+	Error    string `json:"error,omitempty"` // error encountered while applying the command
+}
+
+// BroadcastResult summarizes the acknowledgements of a broadcast Command
+type BroadcastResult struct {
+	Instance     string `json:"instance"`        // ID of the broadcasting instance
+	Acknowledged []Ack  `json:"acknowledged"`    // acknowledgements received from peers
+	Missing      int    `json:"missing"`         // number of peers that received but never acknowledged
+	Error        string `json:"error,omitempty"` // error encountered while broadcasting
+}
+
+// apply a command received from a peer to the local caches, returning the
+// number of tiles affected
+func apply(cmd Command) (int, error) {
+	if cmd.Op == OpFlush {
+		return 0, flush(cmd.Proxy)
+	}
+
+	if cmd.Op == OpGeneration {
+		return 0, forgetGenerations(cmd.Proxy)
+	}
+
+	if cmd.Op == OpPurgeTag {
+		return purgeTag(cmd.Proxy, cmd.Tag)
+	}
+
+	if cmd.Op == OpDeleteKeys {
+		return deleteKeys(cmd.Proxy, cmd.Match)
+	}
+
+	if cmd.Op != OpInvalidate && cmd.Op != OpPrime {
+		return 0, fmt.Errorf("unknown operation '%s'", cmd.Op)
+	}
+
+	c := cache.Get(cmd.Proxy)
+	if c == nil {
+		return 0, fmt.Errorf("no proxy configured with name '%s'", cmd.Proxy)
+	}
+
+	tiles := cmd.Tile.DeepChildren(cmd.MaxZoom)
+
+	// proxies without a shared Redis or object storage cache must fetch
+	// fresh tiles themselves
+	if cmd.Op == OpPrime && !c.Shared() {
+		result := jobs.Run(context.Background(), jobs.Job{
+			Cache:    c,
+			Tiles:    tiles,
+			Mode:     config.ScheduleModePrime,
+			Endpoint: cmd.Endpoint,
+			Params:   cmd.Params,
+		})
+		return result.Succeeded, nil
+	}
+
+	// otherwise the originating instance already updated the shared caches, so we only
+	// need to drop our stale in-memory copies of the tiles
+	for _, t := range tiles {
+		key, err := helpers.CacheKey(*c.Proxy(), t, cmd.Endpoint, cmd.Params)
+		if err != nil {
+			return 0, err
+		}
+		if err = c.InvalidateInternal(key); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(tiles), nil
+}
+
+// flush the in-memory cache of the named proxy, or all proxies if no name given
+func flush(proxyName string) error {
+	for _, proxy := range config.Get().Proxies {
+		if proxyName != "" && proxy.Name != proxyName {
+			continue
+		}
+
+		c := cache.Get(proxy.Name)
+		if c == nil {
+			continue
+		}
+
+		if err := c.FlushInternal(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// forgetGenerations drops the cached namespace generations of the named proxy
+// so that a generation bumped by a peer takes effect immediately
+func forgetGenerations(proxyName string) error {
+	c := cache.Get(proxyName)
+	if c == nil {
+		return fmt.Errorf("no proxy configured with name '%s'", proxyName)
+	}
+
+	c.ForgetGenerations()
+	return nil
+}
+
+// purgeTag purges every tile tagged with the given tag from the local caches
+// of the named proxy, since the originating instance already purged them
+// from the shared caches
+func purgeTag(proxyName, tag string) (int, error) {
+	c := cache.Get(proxyName)
+	if c == nil {
+		return 0, fmt.Errorf("no proxy configured with name '%s'", proxyName)
+	}
+
+	return c.InvalidateTagInternal(tag)
+}
+
+// deleteKeys deletes every key matching the glob pattern from the local
+// caches of the named proxy, since the originating instance already deleted
+// them from the shared caches
+func deleteKeys(proxyName, match string) (int, error) {
+	c := cache.Get(proxyName)
+	if c == nil {
+		return 0, fmt.Errorf("no proxy configured with name '%s'", proxyName)
+	}
+
+	return c.DeleteKeysInternal(match)
+}
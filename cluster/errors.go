@@ -0,0 +1,23 @@
+package cluster
+
+import "fmt"
+
+// ErrInitCluster is an error struct for errors encountered
+// while connecting to the cluster communication channel
+type ErrInitCluster struct {
+	Err error
+}
+
+// Error returns the string representation of ErrInitCluster
+func (e ErrInitCluster) Error() string {
+	return fmt.Sprintf("cluster: failed to connect to cluster channel, got error %s", e.Err.Error())
+}
+
+// ErrNotConnected is an error struct returned when a cluster
+// operation is attempted without a cluster connection
+type ErrNotConnected struct{}
+
+// Error returns the string representation of ErrNotConnected
+func (e ErrNotConnected) Error() string {
+	return "cluster: not connected to a cluster"
+}
@@ -0,0 +1,163 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/util"
+)
+
+// Member is the registration of a live LOD instance within the cluster
+type Member struct {
+	ID        string   `json:"id"`        // unique instance ID
+	Version   string   `json:"version"`   // LOD version the instance runs
+	Address   string   `json:"address"`   // base URL peers reach the instance at
+	BootTime  int64    `json:"boot"`      // time started, unix timestamp
+	Proxies   []string `json:"proxies"`   // names of the proxies the instance serves
+	Heartbeat int64    `json:"heartbeat"` // time of the last heartbeat, unix timestamp
+}
+
+// memberKey returns the Redis key a member's registration is stored at
+func memberKey(cluster config.Cluster, id string) string {
+	return fmt.Sprintf("%s:member:%s", cluster.Channel, id)
+}
+
+// Self returns the registration of this instance
+func Self() Member {
+	lock.RLock()
+	cluster := settings
+	lock.RUnlock()
+
+	address := cluster.Address
+	if address == "" {
+		hostname, err := os.Hostname()
+		if err != nil || hostname == "" {
+			hostname = "localhost"
+		}
+		address = fmt.Sprintf("http://%s:%d", hostname, config.GetPort())
+	}
+
+	proxies := make([]string, 0, len(config.Get().Proxies))
+	for _, proxy := range config.Get().Proxies {
+		proxies = append(proxies, proxy.Name)
+	}
+
+	return Member{
+		ID:        InstanceID,
+		Version:   config.Version,
+		Address:   address,
+		BootTime:  util.BootTime.UnixMilli(),
+		Proxies:   proxies,
+		Heartbeat: time.Now().UnixMilli(),
+	}
+}
+
+// heartbeat registers this instance immediately and then refreshes its
+// registration every heartbeat interval until the context is cancelled
+func heartbeat(ctx context.Context, c *redis.Client, cluster config.Cluster) {
+	key := memberKey(cluster, InstanceID)
+	ticker := time.NewTicker(cluster.HeartbeatDuration)
+	defer ticker.Stop()
+
+	for {
+		register(ctx, c, key, cluster)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// register writes this instance's registration with a TTL of three
+// heartbeats, so instances that die without deregistering expire quickly
+func register(ctx context.Context, c *redis.Client, key string, cluster config.Cluster) {
+	payload, err := json.Marshal(Self())
+	if err != nil {
+		util.Error(str.CCluster, str.EClusterRegister, err.Error())
+		return
+	}
+
+	if err = c.Set(ctx, key, payload, 3*cluster.HeartbeatDuration).Err(); err != nil && ctx.Err() == nil {
+		util.Error(str.CCluster, str.EClusterRegister, err.Error())
+	}
+}
+
+// Members returns the registrations of all live instances in the cluster,
+// including this one, ordered by boot time
+func Members(ctx context.Context) ([]Member, error) {
+	lock.RLock()
+	c := client
+	cluster := settings
+	lock.RUnlock()
+
+	if c == nil {
+		return nil, ErrNotConnected{}
+	}
+
+	keys := make([]string, 0)
+	iter := c.Scan(ctx, 0, memberKey(cluster, "*"), 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	members := make([]Member, 0, len(keys))
+	if len(keys) == 0 {
+		return members, nil
+	}
+
+	values, err := c.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range values {
+		// registration may have expired between the scan and the get
+		raw, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		var member Member
+		if errDecode := json.Unmarshal([]byte(raw), &member); errDecode != nil {
+			util.Error(str.CCluster, str.EClusterDecode, errDecode.Error())
+			continue
+		}
+		members = append(members, member)
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].BootTime < members[j].BootTime
+	})
+
+	return members, nil
+}
+
+// GetMember returns the registration of the live instance with the given ID,
+// or nil if no such instance is registered
+func GetMember(ctx context.Context, id string) (*Member, error) {
+	members, err := Members(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range members {
+		if members[i].ID == id {
+			return &members[i], nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,59 @@
+package cluster
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// transport carries commands and acknowledgements between cluster peers
+type transport interface {
+	// Subscribe to the channel, returning its messages once subscribed and
+	// a function that unsubscribes
+	Subscribe(ctx context.Context, channel string) (<-chan string, func(), error)
+	// Publish the payload to the channel, returning the number of
+	// subscribers that received it
+	Publish(ctx context.Context, channel string, payload []byte) (int, error)
+}
+
+// redisTransport is a transport over Redis pub/sub
+type redisTransport struct {
+	client *redis.Client
+}
+
+// Subscribe to the channel, returning its messages once Redis confirms the
+// subscription and a function that unsubscribes
+func (t redisTransport) Subscribe(ctx context.Context, channel string) (<-chan string, func(), error) {
+	pubsub := t.client.Subscribe(ctx, channel)
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, nil, err
+	}
+
+	messages := make(chan string)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(messages)
+		for message := range pubsub.Channel() {
+			select {
+			case messages <- message.Payload:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return messages, func() {
+		close(done)
+		_ = pubsub.Close()
+	}, nil
+}
+
+// Publish the payload to the channel, returning the number of subscribers
+// that received it
+func (t redisTransport) Publish(ctx context.Context, channel string, payload []byte) (int, error) {
+	receivers, err := t.client.Publish(ctx, channel, payload).Result()
+	return int(receivers), err
+}
@@ -10,8 +10,10 @@ import (
 	"github.com/joho/godotenv"
 
 	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/cluster"
 	"github.com/dechristopher/lod/config"
 	"github.com/dechristopher/lod/env"
+	"github.com/dechristopher/lod/jobs"
 	"github.com/dechristopher/lod/str"
 	"github.com/dechristopher/lod/util"
 	"github.com/dechristopher/lod/www"
@@ -51,6 +53,22 @@ func main() {
 		os.Exit(1)
 	}
 
+	// connect to the cluster if configured
+	if err := cluster.Init(); err != nil {
+		util.Error(str.CMain, str.EConfig, err.Error())
+		os.Exit(1)
+	}
+
+	// start scheduled cache jobs
+	jobs.StartScheduler()
+
+	// warm caches before serving, or while serving if gated by readiness
+	if config.Get().Instance.WarmupGate {
+		go jobs.Warmup()
+	} else {
+		jobs.Warmup()
+	}
+
 	// serve LOD endpoints
 	www.Serve()
 }
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/dechristopher/lod/archive"
+	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/util"
+)
+
+// main entry point to the LOD cache archive tool
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Print(str.HelpCacheTool)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	config.File = flags.String(str.FConfigFile, "config.toml", str.FConfigFileUsage)
+	proxy := flags.String("proxy", "", "Name of the proxy whose cache to export or import.")
+	layer := flags.String("layer", config.LayerRedis, "Cache level to export from or import into.")
+	path := flags.String("file", "", "Path of the cache archive.")
+	resume := flags.Bool("resume", false, "Resume an interrupted export of the archive.")
+	skip := flags.Int("skip", 0, "Number of archive records to skip when resuming an import.")
+	_ = flags.Parse(os.Args[2:])
+
+	if *proxy == "" || *path == "" {
+		fmt.Print(str.HelpCacheTool)
+		os.Exit(2)
+	}
+
+	// the memory level of this process starts empty and is lost on exit
+	if *layer == config.LayerMemory {
+		util.Error(str.CArchive, str.ELocalLayer, *layer)
+		os.Exit(2)
+	}
+
+	c, err := loadCache(*proxy)
+	if err != nil {
+		util.Error(str.CArchive, str.EConfig, err.Error())
+		os.Exit(1)
+	}
+
+	// stop cleanly on interrupt so the transfer can be resumed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	switch os.Args[1] {
+	case "export":
+		err = export(ctx, c, *layer, *path, *resume)
+	case "import":
+		err = restore(ctx, c, *layer, *path, *skip)
+	default:
+		fmt.Print(str.HelpCacheTool)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// loadCache loads the configuration and builds the named proxy's cache
+func loadCache(name string) (*cache.Cache, error) {
+	if err := config.Load(); err != nil {
+		return nil, err
+	}
+
+	if err := cache.BuildInstance(name); err != nil {
+		return nil, err
+	}
+
+	c := cache.Get(name)
+	if c == nil {
+		return nil, fmt.Errorf("no proxy configured with name '%s'", name)
+	}
+
+	return c, nil
+}
+
+// export writes the cache level to the archive at path, resuming from the
+// last scan batch of an existing archive if requested
+func export(ctx context.Context, c *cache.Cache, layer, path string, resume bool) error {
+	from, offset, err := resumePoint(path, resume)
+	if err != nil {
+		util.Error(str.CArchive, str.EExport, layer, c.Proxy().Name, "", err.Error())
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		util.Error(str.CArchive, str.EExport, layer, c.Proxy().Name, from, err.Error())
+		return err
+	}
+	defer file.Close()
+
+	// drop the records of the batch being resumed, and any partially
+	// written record after them
+	if err = file.Truncate(offset); err == nil {
+		_, err = file.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		util.Error(str.CArchive, str.EExport, layer, c.Proxy().Name, from, err.Error())
+		return err
+	}
+
+	writer := archive.AppendWriter(file)
+	if offset == 0 {
+		if writer, err = archive.NewWriter(file); err != nil {
+			util.Error(str.CArchive, str.EExport, layer, c.Proxy().Name, from, err.Error())
+			return err
+		}
+	}
+
+	result, err := c.Export(ctx, layer, from, writer, func(progress cache.Transfer) {
+		util.Info(str.CArchive, str.MExportProgress, layer, c.Proxy().Name, progress.Entries, progress.Cursor)
+	})
+	if err != nil {
+		util.Error(str.CArchive, str.EExport, layer, c.Proxy().Name, result.Cursor, err.Error())
+		return err
+	}
+
+	util.Info(str.CArchive, str.MExport, layer, c.Proxy().Name, result.Entries)
+	return nil
+}
+
+// resumePoint returns the scan cursor of the last batch of an existing
+// archive, which may be incomplete, and the size of the archive up to that
+// batch's records, or empty values to start over
+func resumePoint(path string, resume bool) (string, int64, error) {
+	if !resume {
+		return "", 0, nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	// start over if the archive was created but nothing was written to it
+	if info, errStat := file.Stat(); errStat == nil && info.Size() == 0 {
+		return "", 0, nil
+	}
+
+	reader, err := archive.NewReader(file)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// records of a batch share the cursor the batch was scanned from
+	from, offset := "", int64(0)
+	for first := true; ; first = false {
+		start := reader.Offset()
+		record, errRead := reader.Read()
+		if errRead != nil {
+			break
+		}
+		if first || record.Cursor != from {
+			from, offset = record.Cursor, start
+		}
+	}
+
+	// start over if the archive holds no complete records
+	if offset == 0 {
+		return "", 0, nil
+	}
+
+	return from, offset, nil
+}
+
+// restore imports the archive at path into the cache level
+func restore(ctx context.Context, c *cache.Cache, layer, path string, skip int) error {
+	file, err := os.Open(path)
+	if err != nil {
+		util.Error(str.CArchive, str.EImport, layer, c.Proxy().Name, skip, err.Error())
+		return err
+	}
+	defer file.Close()
+
+	reader, err := archive.NewReader(file)
+	if err != nil {
+		util.Error(str.CArchive, str.EImport, layer, c.Proxy().Name, skip, err.Error())
+		return err
+	}
+
+	result, err := c.Import(ctx, layer, reader, skip, func(progress cache.Transfer) {
+		util.Info(str.CArchive, str.MImportProgress, layer, c.Proxy().Name, progress.Entries, progress.Next)
+	})
+	if err != nil {
+		util.Error(str.CArchive, str.EImport, layer, c.Proxy().Name, result.Next, err.Error())
+		return err
+	}
+
+	util.Info(str.CArchive, str.MImport, layer, c.Proxy().Name, result.Entries, result.Expired)
+	return nil
+}
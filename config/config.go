@@ -3,7 +3,9 @@ package config
 import (
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"regexp"
 	"strings"
@@ -13,7 +15,9 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/gofiber/fiber/v2"
 
+	"github.com/dechristopher/lod/cron"
 	"github.com/dechristopher/lod/env"
+	"github.com/dechristopher/lod/packet"
 	"github.com/dechristopher/lod/str"
 	"github.com/dechristopher/lod/util"
 )
@@ -35,6 +39,32 @@ var (
 
 	// default number of cache workers
 	defaultNumWorkers = 8
+
+	// maximum zoom level reachable by scheduled jobs
+	maxScheduleZoom = 24
+
+	// MaxWarmupHotKeys is the maximum number of popular keys tracked per proxy
+	MaxWarmupHotKeys = 100000
+
+	// default and maximum number of most requested tiles ranked per proxy
+	defaultHeatmapTopK = 1000
+	MaxHeatmapTopK     = 100000
+
+	// MaxMetricEndpointLabels is the maximum number of distinct dynamic
+	// endpoints labeled in a proxy's metrics
+	MaxMetricEndpointLabels = 1000
+
+	// default time allowed for each proxy's warmup
+	defaultWarmupTimeout = 5 * time.Minute
+
+	// default minimum size in bytes of tiles stored compressed
+	defaultCompressionThreshold = 1024
+
+	// default cluster pub/sub channel, acknowledgement timeout and heartbeat interval
+	defaultClusterChannel     = "lod:cluster"
+	defaultClusterAckTimeout  = 2 * time.Second
+	defaultClusterHeartbeat   = 5 * time.Second
+	defaultClusterPeerTimeout = 30 * time.Second
 )
 
 // Capabilities of the LOD instance (the configuration)
@@ -46,26 +76,54 @@ type Capabilities struct {
 
 // Instance configuration for LOD
 type Instance struct {
-	Port           int    `json:"port" toml:"port"`                       // configured LOD port
-	Environment    string `json:"environment"`                            // configured LOD environment
-	AdminDisabled  bool   `json:"admin_disabled" toml:"admin_disabled"`   // whether the admin endpoints are disabled
-	AdminToken     string `json:"-" toml:"admin_token"`                   // admin endpoint auth bearer token
-	MetricsEnabled bool   `json:"metrics_enabled" toml:"metrics_enabled"` // whether metrics are enabled
+	Port           int               `json:"port" toml:"port"`                       // configured LOD port
+	Environment    string            `json:"environment"`                            // configured LOD environment
+	AdminDisabled  bool              `json:"admin_disabled" toml:"admin_disabled"`   // whether the admin endpoints are disabled
+	AdminToken     string            `json:"-" toml:"admin_token"`                   // admin endpoint auth bearer token
+	MetricsEnabled bool              `json:"metrics_enabled" toml:"metrics_enabled"` // whether metrics are enabled
+	Cluster        Cluster           `json:"cluster" toml:"cluster"`                 // cluster communication configuration
+	Redis          []RedisConnection `json:"redis" toml:"redis"`                     // shared redis connections proxies can reference by name
+	WarmupGate     bool              `json:"warmup_gate" toml:"warmup_gate"`         // serve while warming caches, reporting readiness at /ready, instead of warming before serving
+}
+
+// Cluster configuration for intra-cluster communication between LOD instances
+// over Redis pub/sub. Administrative operations on one instance are broadcast
+// to and applied by every other instance subscribed to the same channel.
+type Cluster struct {
+	Enabled bool `json:"enabled" toml:"enabled"` // whether cluster-wide operations are enabled
+	// Example: redis://<user>:<password>@<host>:<port>/<db_number>
+	RedisURL            string         `json:"-" toml:"redis_url"`               // full redis connection URL for parsing, SENSITIVE
+	RedisTLS            bool           `json:"redis_tls" toml:"redis_tls"`       // whether to use TLS when connecting to the redis server
+	RedisOpts           *redis.Options `json:"-" toml:"-"`                       // internal redis options, first parsed with config
+	Channel             string         `json:"channel" toml:"channel"`           // pub/sub channel shared by all instances, default lod:cluster
+	AckTimeout          string         `json:"ack_timeout" toml:"ack_timeout"`   // how long to wait for peer acknowledgements, default 2s
+	AckTimeoutDuration  time.Duration  `json:"-" toml:"-"`                       // parsed duration from AckTimeout
+	Address             string         `json:"address" toml:"address"`           // base URL peers reach this instance at, default http://<hostname>:<port>
+	Heartbeat           string         `json:"heartbeat" toml:"heartbeat"`       // interval to refresh this instance's registration, default 5s
+	HeartbeatDuration   time.Duration  `json:"-" toml:"-"`                       // parsed duration from Heartbeat
+	PeerTimeout         string         `json:"peer_timeout" toml:"peer_timeout"` // timeout of admin requests fanned out to peers, default 30s
+	PeerTimeoutDuration time.Duration  `json:"-" toml:"-"`                       // parsed duration from PeerTimeout
 }
 
 // Proxy represents a configuration for a single endpoint proxy instance
 type Proxy struct {
-	Name             string   `json:"name" toml:"name"`                 // display name for this proxy
-	TileURL          string   `json:"tile_url" toml:"tile_url"`         // templated tileserver URL that this instance will hit
-	HasEndpointParam bool     `json:"has_endpoint_param"`               // internal variable to track whether this proxy has a dynamic endpoint configured
-	CorsOrigins      string   `json:"cors_origins" toml:"cors_origins"` // allowed CORS origins, comma separated
-	PullHeaders      []string `json:"pull_headers" toml:"pull_headers"` // additional headers to pull and cache from the tileserver
-	DeleteHeaders    []string `json:"del_headers" toml:"del_headers"`   // headers to exclude from the tileserver response
-	AddHeaders       []Header `json:"add_headers" toml:"add_headers"`   // headers to inject into upstream requests to tileserver
-	AccessToken      string   `json:"-" toml:"access_token"`            // optional access token for incoming requests
-	NumWorkers       int      `json:"num_workers" toml:"num_workers"`   // optionally limit number of cache workers for priming and invalidation jobs
-	Params           []Param  `json:"params" toml:"params"`             // URL query parameter configurations for this instance
-	Cache            Cache    `json:"cache" toml:"cache"`               // cache configuration for this proxy instance
+	Name             string      `json:"name" toml:"name"`                 // display name for this proxy
+	TileURL          string      `json:"tile_url" toml:"tile_url"`         // templated tileserver URL that this instance will hit
+	HasEndpointParam bool        `json:"has_endpoint_param"`               // internal variable to track whether this proxy has a dynamic endpoint configured
+	CorsOrigins      string      `json:"cors_origins" toml:"cors_origins"` // allowed CORS origins, comma separated
+	PullHeaders      []string    `json:"pull_headers" toml:"pull_headers"` // additional headers to pull and cache from the tileserver
+	DeleteHeaders    []string    `json:"del_headers" toml:"del_headers"`   // headers to exclude from the tileserver response
+	TagHeader        string      `json:"tag_header" toml:"tag_header"`     // tileserver header listing tags to index tiles by, ex: Surrogate-Key
+	AddHeaders       []Header    `json:"add_headers" toml:"add_headers"`   // headers to inject into upstream requests to tileserver
+	AccessToken      string      `json:"-" toml:"access_token"`            // optional access token for incoming requests
+	NumWorkers       int         `json:"num_workers" toml:"num_workers"`   // optionally limit number of cache workers for priming and invalidation jobs
+	Params           []Param     `json:"params" toml:"params"`             // URL query parameter configurations for this instance
+	Cache            Cache       `json:"cache" toml:"cache"`               // cache configuration for this proxy instance
+	ClientCache      ClientCache `json:"client_cache" toml:"client_cache"` // caching headers sent to clients and CDNs
+	Warmup           Warmup      `json:"warmup" toml:"warmup"`             // tiles loaded into the local cache levels at startup
+	Schedules        []Schedule  `json:"schedules" toml:"schedules"`       // recurring cache jobs run internally for this proxy
+	Metrics          Metrics     `json:"metrics" toml:"metrics"`           // optional labels of the proxy's Prometheus metrics
+	Heatmap          Heatmap     `json:"heatmap" toml:"heatmap"`           // sampled counting of the proxy's most requested tiles
 }
 
 // Header to inject in upstream request to tileserver
@@ -80,25 +138,309 @@ type Param struct {
 	Default string `json:"default" toml:"default"` // default parameter value if none provided in URL
 }
 
+// Warmup configures the tiles loaded into a proxy's local cache levels at
+// startup from its shared cache levels, or optionally from the upstream.
+// Tiles can be listed in a file, targeted by a bounding box and zoom range,
+// or be the most popular keys tracked in Redis by previous instances.
+type Warmup struct {
+	TileFile        string            `json:"tile_file" toml:"tile_file"` // path to a file of z/x/y tiles, one per line
+	BBox            []float64         `json:"bbox" toml:"bbox"`           // bounding box target [min_lon, min_lat, max_lon, max_lat]
+	MinZoom         int               `json:"min_zoom" toml:"min_zoom"`   // minimum zoom level of tiles in the bounding box
+	MaxZoom         int               `json:"max_zoom" toml:"max_zoom"`   // maximum zoom level of tiles in the bounding box
+	Endpoint        string            `json:"endpoint" toml:"endpoint"`   // dynamic endpoint value, required if the proxy uses {e}
+	Params          map[string]string `json:"params" toml:"params"`       // configured URL parameter values used to build keys
+	HotKeys         int               `json:"hot_keys" toml:"hot_keys"`   // number of the most popular keys to track in redis and warm, 0 to disable
+	Upstream        bool              `json:"upstream" toml:"upstream"`   // fetch listed tiles missing from every cache level from the upstream
+	Timeout         string            `json:"timeout" toml:"timeout"`     // maximum duration of the warmup, default 5m
+	TimeoutDuration time.Duration     `json:"-" toml:"-"`                 // parsed duration from Timeout
+}
+
+// Enabled returns true if any tiles are configured to be warmed
+func (w Warmup) Enabled() bool {
+	return w.TileFile != "" || w.BBox != nil || w.HotKeys > 0
+}
+
+// Heatmap configures sampled counting of tile requests, ranking the most
+// requested tiles in memory bounded by the number of tiles ranked, and
+// optionally summing the counts of every instance in Redis
+type Heatmap struct {
+	SampleRate float64 `json:"sample_rate" toml:"sample_rate"` // fraction of requests counted, 0 to disable
+	TopK       int     `json:"top_k" toml:"top_k"`             // number of most requested tiles ranked, default 1000
+	Redis      bool    `json:"redis" toml:"redis"`             // sum the counts of every instance in redis
+}
+
+// Enabled returns true if tile requests are counted
+func (h Heatmap) Enabled() bool {
+	return h.SampleRate > 0
+}
+
+// Metrics configures optional labels of a proxy's Prometheus metrics, which
+// are capped since every distinct label value creates a new series
+type Metrics struct {
+	EndpointLabels int `json:"endpoint_labels" toml:"endpoint_labels"` // distinct dynamic endpoints to label per-zoom request counts with, 0 to disable
+}
+
+// ClientCache configures the caching headers sent to clients and to any CDN
+// in front of LOD. Rules replace the whole policy for tiles within a range
+// of zoom levels, the first matching rule winning.
+type ClientCache struct {
+	MaxAge                       string        `json:"max_age" toml:"max_age"`                               // Cache-Control max-age, ex: 1h
+	MaxAgeDuration               time.Duration `json:"-" toml:"-"`                                           // parsed duration from MaxAge
+	SMaxAge                      string        `json:"s_maxage" toml:"s_maxage"`                             // Cache-Control s-maxage for shared caches, ex: 24h
+	SMaxAgeDuration              time.Duration `json:"-" toml:"-"`                                           // parsed duration from SMaxAge
+	StaleWhileRevalidate         string        `json:"stale_while_revalidate" toml:"stale_while_revalidate"` // Cache-Control stale-while-revalidate, ex: 10m
+	StaleWhileRevalidateDuration time.Duration `json:"-" toml:"-"`                                           // parsed duration from StaleWhileRevalidate
+	Immutable                    bool          `json:"immutable" toml:"immutable"`                           // add the Cache-Control immutable directive
+	SurrogateControl             string        `json:"surrogate_control" toml:"surrogate_control"`           // Surrogate-Control header value, ex: max-age=86400
+	CDNCacheControl              string        `json:"cdn_cache_control" toml:"cdn_cache_control"`           // CDN-Cache-Control header value, ex: max-age=86400
+	MinZoom                      int           `json:"min_zoom" toml:"min_zoom"`                             // lowest zoom level a rule applies to
+	MaxZoom                      int           `json:"max_zoom" toml:"max_zoom"`                             // highest zoom level a rule applies to
+	Rules                        []ClientCache `json:"rules" toml:"rules"`                                   // policies overriding this one by zoom range
+}
+
+// Policy returns the first rule applying to tiles at the given zoom level,
+// or the proxy-wide policy if none apply
+func (c ClientCache) Policy(zoom int) ClientCache {
+	for _, rule := range c.Rules {
+		if zoom >= rule.MinZoom && zoom <= rule.MaxZoom {
+			return rule
+		}
+	}
+	return c
+}
+
+// CacheControl returns the Cache-Control header value of the policy, or an
+// empty string if it sets no Cache-Control directives
+func (c ClientCache) CacheControl() string {
+	var directives []string
+	if c.MaxAge != "" {
+		directives = append(directives, fmt.Sprintf("max-age=%d", int64(c.MaxAgeDuration.Seconds())))
+	}
+	if c.SMaxAge != "" {
+		directives = append(directives, fmt.Sprintf("s-maxage=%d", int64(c.SMaxAgeDuration.Seconds())))
+	}
+	if c.StaleWhileRevalidate != "" {
+		directives = append(directives, fmt.Sprintf("stale-while-revalidate=%d",
+			int64(c.StaleWhileRevalidateDuration.Seconds())))
+	}
+	if c.Immutable {
+		directives = append(directives, "immutable")
+	}
+	return strings.Join(directives, ", ")
+}
+
+// Schedule modes supported by scheduled cache jobs
+const (
+	ScheduleModePrime      = "prime"      // fetch and re-cache every targeted tile
+	ScheduleModeInvalidate = "invalidate" // remove every targeted tile from the caches
+	ScheduleModeRefresh    = "refresh"    // fetch every targeted tile, re-caching only those that changed
+)
+
+// Schedule configuration for a recurring seeding or refresh job that LOD runs
+// internally against a proxy. Exactly one target (Tile, BBox or GeometryFile)
+// must be configured.
+type Schedule struct {
+	Name         string            `json:"name" toml:"name"`                   // display name for this schedule, defaults to its position
+	Cron         string            `json:"cron" toml:"cron"`                   // cron expression, ex: "0 3 * * *", "@hourly", "@every 6h"
+	Mode         string            `json:"mode" toml:"mode"`                   // one of prime, invalidate or refresh
+	Tile         string            `json:"tile" toml:"tile"`                   // root tile target formatted as z/x/y
+	BBox         []float64         `json:"bbox" toml:"bbox"`                   // bounding box target [min_lon, min_lat, max_lon, max_lat]
+	GeometryFile string            `json:"geometry_file" toml:"geometry_file"` // path to a GeoJSON or WKT (.wkt) geometry target
+	MinZoom      int               `json:"min_zoom" toml:"min_zoom"`           // minimum zoom level to operate on
+	MaxZoom      int               `json:"max_zoom" toml:"max_zoom"`           // maximum zoom level to operate on
+	Endpoint     string            `json:"endpoint" toml:"endpoint"`           // dynamic endpoint value, required if the proxy uses {e}
+	Params       map[string]string `json:"params" toml:"params"`               // configured URL parameter values to use for the job
+	Spec         cron.Schedule     `json:"-" toml:"-"`                         // parsed cron schedule
+}
+
 // Cache configuration for a Proxy instance
 // Cache TTLs are set using Go's built-in time.ParseDuration
 // Valid time units are "ns", "us" (or "µs"), "ms", "s", "m", "h".
 // For example: 1h, 300s, 1000ms, 2h35m, etc.
 type Cache struct {
-	MemEnabled     bool          `json:"mem_enabled" toml:"mem_enabled"`     // whether the in-memory cache is enabled
-	MemCap         int           `json:"mem_cap" toml:"mem_cap"`             // maximum capacity in MB of the in-memory cache
-	MemTTL         string        `json:"mem_ttl" toml:"mem_ttl"`             // in-memory cache TTL, ex: 1h, 30s, 1000ms, etc
-	MemTTLDuration time.Duration `json:"-" toml:"-"`                         // parsed duration from MemTTL
-	RedisEnabled   bool          `json:"redis_enabled" toml:"redis_enabled"` // whether the redis cache is enabled
+	MemEnabled      bool          `json:"mem_enabled" toml:"mem_enabled"`     // whether the in-memory cache is enabled
+	MemCap          int           `json:"mem_cap" toml:"mem_cap"`             // maximum capacity in MB of the in-memory cache
+	MemTTL          string        `json:"mem_ttl" toml:"mem_ttl"`             // in-memory cache TTL, ex: 1h, 30s, 1000ms, etc
+	MemTTLDuration  time.Duration `json:"-" toml:"-"`                         // parsed duration from MemTTL
+	DiskEnabled     bool          `json:"disk_enabled" toml:"disk_enabled"`   // whether the on-disk cache is enabled
+	DiskPath        string        `json:"disk_path" toml:"disk_path"`         // directory to store on-disk cache entries in
+	DiskCap         int           `json:"disk_cap" toml:"disk_cap"`           // maximum capacity in MB of the on-disk cache
+	DiskTTL         string        `json:"disk_ttl" toml:"disk_ttl"`           // on-disk cache TTL, ex: 1h, 30s, 1000ms, etc
+	DiskTTLDuration time.Duration `json:"-" toml:"-"`                         // parsed duration from DiskTTL
+	RedisEnabled    bool          `json:"redis_enabled" toml:"redis_enabled"` // whether the redis cache is enabled
 	// Note: our redis cache does not have a max cap on tiles. It will grow unbounded, so
 	// you must use a TTL to avoid capping out your cluster if you have a large tile set.
 	RedisTTL         string        `json:"redis_ttl" toml:"redis_ttl"` // redis tile cache TTL, ex: 1h, 30s, 1000ms, etc
 	RedisTTLDuration time.Duration `json:"-" toml:"-"`                 // parsed duration from RedisTTL
 	// Example: redis://<user>:<password>@<host>:<port>/<db_number>
-	RedisURL    string         `json:"-" toml:"redis_url"`               // full redis connection URL for parsing, SENSITIVE
-	RedisTLS    bool           `json:"redis_tls" toml:"redis_tls"`       // whether to use TLS when connecting to the redis server
-	RedisOpts   *redis.Options `json:"-" toml:"-"`                       // internal redis options, first parsed with config
-	KeyTemplate string         `json:"key_template" toml:"key_template"` // cache key template, supports XYZ and URL parameters
+	RedisURL string `json:"-" toml:"redis_url"`         // full redis connection URL for parsing, SENSITIVE, standalone mode only
+	RedisTLS bool   `json:"redis_tls" toml:"redis_tls"` // whether to use TLS when connecting to the redis server
+	// Redis Cluster and Sentinel deployments are configured with a list of
+	// seed or sentinel addresses instead of a connection URL
+	RedisMode             string           `json:"redis_mode" toml:"redis_mode"`               // one of standalone (default), cluster or sentinel
+	RedisAddrs            []string         `json:"redis_addrs" toml:"redis_addrs"`             // cluster seed or sentinel host:port addresses
+	RedisMasterName       string           `json:"redis_master_name" toml:"redis_master_name"` // sentinel master name, sentinel mode only
+	RedisUsername         string           `json:"-" toml:"redis_username"`                    // redis ACL username, SENSITIVE
+	RedisPassword         string           `json:"-" toml:"redis_password"`                    // redis password, SENSITIVE
+	RedisSentinelPassword string           `json:"-" toml:"redis_sentinel_password"`           // sentinel password if different, SENSITIVE
+	RedisDB               int              `json:"redis_db" toml:"redis_db"`                   // database number, sentinel mode only
+	Redis                 string           `json:"redis" toml:"redis"`                         // name of a shared instance-level redis connection, overrides inline settings
+	RedisConnection       *RedisConnection `json:"-" toml:"-"`                                 // internal resolved redis connection, first parsed with config
+	// Memcached is an external cache level alternative or addition to redis.
+	// Keys are spread over the server list with consistent hashing.
+	MemcachedEnabled         bool          `json:"memcached_enabled" toml:"memcached_enabled"`     // whether the memcached cache is enabled
+	MemcachedServers         []string      `json:"memcached_servers" toml:"memcached_servers"`     // memcached host:port addresses
+	MemcachedTTL             string        `json:"memcached_ttl" toml:"memcached_ttl"`             // memcached tile cache TTL, ex: 1h, 30s, 1000ms, etc
+	MemcachedTTLDuration     time.Duration `json:"-" toml:"-"`                                     // parsed duration from MemcachedTTL
+	MemcachedItemSize        int           `json:"memcached_item_size" toml:"memcached_item_size"` // maximum item size in KB configured on the servers, default 1024
+	MemcachedChunk           bool          `json:"memcached_chunk" toml:"memcached_chunk"`         // split oversized tiles across several items instead of skipping them
+	MemcachedTimeout         string        `json:"memcached_timeout" toml:"memcached_timeout"`     // timeout of each memcached operation, default 500ms
+	MemcachedTimeoutDuration time.Duration `json:"-" toml:"-"`                                     // parsed duration from MemcachedTimeout
+	// S3-compatible object storage is a durable cache level behind redis.
+	// Objects are stored under S3Prefix followed by the templated cache key.
+	S3Enabled     bool          `json:"s3_enabled" toml:"s3_enabled"`       // whether the object storage cache is enabled
+	S3Endpoint    string        `json:"s3_endpoint" toml:"s3_endpoint"`     // base URL of the object storage service
+	S3Bucket      string        `json:"s3_bucket" toml:"s3_bucket"`         // bucket to store tiles in
+	S3Region      string        `json:"s3_region" toml:"s3_region"`         // bucket region, default us-east-1
+	S3AccessKey   string        `json:"-" toml:"s3_access_key"`             // access key ID, SENSITIVE
+	S3SecretKey   string        `json:"-" toml:"s3_secret_key"`             // secret access key, SENSITIVE
+	S3PathStyle   bool          `json:"s3_path_style" toml:"s3_path_style"` // use path-style bucket addressing, required by most MinIO deployments
+	S3Prefix      string        `json:"s3_prefix" toml:"s3_prefix"`         // object key prefix, default {proxy name}/
+	S3TTL         string        `json:"s3_ttl" toml:"s3_ttl"`               // object storage tile TTL, ex: 720h, or "0" for no expiry
+	S3TTLDuration time.Duration `json:"-" toml:"-"`                         // parsed duration from S3TTL
+	Versioned     bool          `json:"versioned" toml:"versioned"`         // fold a generation number stored in redis into every cache key
+	KeyTemplate   string        `json:"key_template" toml:"key_template"`   // cache key template, supports XYZ and URL parameters
+	Layers        []string      `json:"layers" toml:"layers"`               // order enabled cache levels are checked in, default memory, disk, redis, memcached, s3
+	TTLRules      []TTLRule     `json:"ttl_rules" toml:"ttl_rules"`         // TTL overrides by zoom range and dynamic endpoint, first match wins
+	// Upstream caching headers can be honored to derive the TTL of each tile,
+	// capping the TTLs of every cache level. Uncacheable tiles aren't cached.
+	HonorUpstream          bool          `json:"honor_upstream" toml:"honor_upstream"`     // derive tile TTLs from upstream Cache-Control, Expires and Age headers
+	UpstreamMinTTL         string        `json:"upstream_min_ttl" toml:"upstream_min_ttl"` // lowest TTL derived from upstream headers, default 0
+	UpstreamMinTTLDuration time.Duration `json:"-" toml:"-"`                               // parsed duration from UpstreamMinTTL
+	UpstreamMaxTTL         string        `json:"upstream_max_ttl" toml:"upstream_max_ttl"` // highest TTL derived from upstream headers, default unlimited
+	UpstreamMaxTTLDuration time.Duration `json:"-" toml:"-"`                               // parsed duration from UpstreamMaxTTL
+	// Tile packets are checksummed when cached and verified when read back.
+	Checksum    string `json:"checksum" toml:"checksum"`         // checksum algorithm of new tile packets, one of sha256 (default), crc32c or xxhash
+	TrustMemory bool   `json:"trust_memory" toml:"trust_memory"` // skip checksum verification of in-memory cache hits
+	// Tile data can be stored compressed, and is sent to clients as stored
+	// if they accept the encoding or decompressed otherwise.
+	Compression          string `json:"compression" toml:"compression"`                     // compression codec of cached tile data, one of none (default), gzip or zstd
+	CompressionThreshold int    `json:"compression_threshold" toml:"compression_threshold"` // minimum tile size in bytes to compress, default 1024
+	// Identical tile data, like empty ocean tiles, can be stored once by
+	// content hash in the in-memory and Redis levels.
+	Dedup bool `json:"dedup" toml:"dedup"` // store tile data by content hash, with cache keys referencing it
+}
+
+// TTLRule overrides the in-memory and redis TTLs of tiles within a range of
+// zoom levels, optionally only for tiles of a single dynamic endpoint
+type TTLRule struct {
+	MinZoom          int           `json:"min_zoom" toml:"min_zoom"`   // lowest zoom level the rule applies to
+	MaxZoom          int           `json:"max_zoom" toml:"max_zoom"`   // highest zoom level the rule applies to
+	Endpoint         string        `json:"endpoint" toml:"endpoint"`   // dynamic endpoint the rule applies to, or all if empty
+	MemTTL           string        `json:"mem_ttl" toml:"mem_ttl"`     // in-memory cache TTL, or the cache-wide TTL if empty
+	MemTTLDuration   time.Duration `json:"-" toml:"-"`                 // parsed duration from MemTTL
+	RedisTTL         string        `json:"redis_ttl" toml:"redis_ttl"` // redis cache TTL, or the cache-wide TTL if empty
+	RedisTTLDuration time.Duration `json:"-" toml:"-"`                 // parsed duration from RedisTTL
+}
+
+// Matches returns true if the rule applies to tiles at the given zoom
+// level of the given dynamic endpoint
+func (r TTLRule) Matches(zoom int, endpoint string) bool {
+	return zoom >= r.MinZoom && zoom <= r.MaxZoom && (r.Endpoint == "" || r.Endpoint == endpoint)
+}
+
+// TTLRule returns the first TTL rule applying to tiles at the given zoom
+// level of the given dynamic endpoint, or nil if none apply
+func (c Cache) TTLRule(zoom int, endpoint string) *TTLRule {
+	for i := range c.TTLRules {
+		if c.TTLRules[i].Matches(zoom, endpoint) {
+			return &c.TTLRules[i]
+		}
+	}
+	return nil
+}
+
+// MaxMemTTL returns the longest in-memory TTL of the cache and its TTL rules
+func (c Cache) MaxMemTTL() time.Duration {
+	ttl := c.MemTTLDuration
+	for _, rule := range c.TTLRules {
+		if rule.MemTTL != "" && rule.MemTTLDuration > ttl {
+			ttl = rule.MemTTLDuration
+		}
+	}
+	return ttl
+}
+
+// MaxRedisTTL returns the longest Redis TTL of the cache and its TTL rules
+func (c Cache) MaxRedisTTL() time.Duration {
+	ttl := c.RedisTTLDuration
+	for _, rule := range c.TTLRules {
+		if rule.RedisTTL != "" && rule.RedisTTLDuration > ttl {
+			ttl = rule.RedisTTLDuration
+		}
+	}
+	return ttl
+}
+
+// MaxSharedTTL returns the longest TTL tiles can be stored with in any
+// enabled shared cache level, including TTL rules, or zero if tiles can be
+// stored without expiry
+func (c Cache) MaxSharedTTL() time.Duration {
+	var ttls []time.Duration
+	if c.RedisEnabled {
+		ttls = append(ttls, c.RedisTTLDuration)
+		for _, rule := range c.TTLRules {
+			if rule.RedisTTL != "" {
+				ttls = append(ttls, rule.RedisTTLDuration)
+			}
+		}
+	}
+	if c.MemcachedEnabled {
+		ttls = append(ttls, c.MemcachedTTLDuration)
+	}
+	if c.S3Enabled {
+		ttls = append(ttls, c.S3TTLDuration)
+	}
+
+	var max time.Duration
+	for _, ttl := range ttls {
+		if ttl <= 0 {
+			return 0
+		}
+		if ttl > max {
+			max = ttl
+		}
+	}
+	return max
+}
+
+// Cache levels, in their default order, that can be reordered with Cache.Layers
+const (
+	LayerMemory    = "memory"    // in-memory cache
+	LayerDisk      = "disk"      // on-disk cache
+	LayerRedis     = "redis"     // external redis cache
+	LayerMemcached = "memcached" // external memcached cache
+	LayerS3        = "s3"        // object storage cache
+)
+
+// defaultLayers is the default order cache levels are checked in
+var defaultLayers = []string{LayerMemory, LayerDisk, LayerRedis, LayerMemcached, LayerS3}
+
+// LayerEnabled returns true if the named cache level is enabled
+func (c Cache) LayerEnabled(name string) bool {
+	switch name {
+	case LayerMemory:
+		return c.MemEnabled
+	case LayerDisk:
+		return c.DiskEnabled
+	case LayerRedis:
+		return c.RedisEnabled
+	case LayerMemcached:
+		return c.MemcachedEnabled
+	case LayerS3:
+		return c.S3Enabled
+	}
+	return false
 }
 
 var defaultCache = Cache{
@@ -107,12 +449,12 @@ var defaultCache = Cache{
 	KeyTemplate: "{z}/{x}/{y}",
 }
 
-var zeroCache = Cache{
-	MemCap:      0,
-	MemTTL:      "",
-	RedisTTL:    "",
-	RedisURL:    "",
-	KeyTemplate: "",
+// isZero returns true if no cache properties were configured
+func (c Cache) isZero() bool {
+	return c.MemCap == 0 && c.MemTTL == "" && c.DiskPath == "" && c.RedisTTL == "" &&
+		c.RedisURL == "" && len(c.RedisAddrs) == 0 && c.Redis == "" && len(c.MemcachedServers) == 0 && c.S3Bucket == "" &&
+		c.KeyTemplate == "" && len(c.Layers) == 0 && len(c.TTLRules) == 0 &&
+		!c.HonorUpstream && c.Checksum == "" && !c.TrustMemory && c.Compression == "" && !c.Dedup
 }
 
 // Get returns a pointer to the global configuration
@@ -203,7 +545,7 @@ func setDefaults(cap *Capabilities) {
 	}
 
 	for i := range cap.Proxies {
-		if cap.Proxies[i].Cache == zeroCache {
+		if cap.Proxies[i].Cache.isZero() {
 			cap.Proxies[i].Cache = defaultCache
 		}
 
@@ -232,11 +574,81 @@ func validateCapabilities(c *Capabilities) error {
 		return ErrInvalidPort{Port: c.Instance.Port}
 	}
 
+	// validate cluster configuration
+	if err := validateCluster(&c.Instance.Cluster); err != nil {
+		return err
+	}
+
+	// validate shared redis connection definitions
+	if err := validateRedisConnections(c); err != nil {
+		return err
+	}
+
 	// validate each provided proxy endpoint configuration
 	for num := range c.Proxies {
 		if err := validateProxy(num, &c.Proxies[num]); err != nil {
 			return err
 		}
+
+		// resolve the redis connection used by the proxy's external cache
+		if c.Proxies[num].Cache.RedisEnabled {
+			if err := resolveRedisConnection(c, &c.Proxies[num]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateCluster validates the cluster configuration if enabled
+func validateCluster(cluster *Cluster) error {
+	if !cluster.Enabled {
+		return nil
+	}
+
+	var errParse error
+	cluster.RedisOpts, errParse = redis.ParseURL(cluster.RedisURL)
+	if errParse != nil {
+		return ErrInvalidClusterRedisURL{
+			URL: cluster.RedisURL,
+			Err: errParse,
+		}
+	}
+
+	if cluster.Channel == "" {
+		cluster.Channel = defaultClusterChannel
+	}
+
+	cluster.AckTimeoutDuration = defaultClusterAckTimeout
+	if cluster.AckTimeout != "" {
+		ackTimeout, err := time.ParseDuration(cluster.AckTimeout)
+		if err != nil || ackTimeout <= 0 {
+			return ErrInvalidClusterAckTimeout{Timeout: cluster.AckTimeout}
+		}
+		cluster.AckTimeoutDuration = ackTimeout
+	}
+
+	cluster.HeartbeatDuration = defaultClusterHeartbeat
+	if cluster.Heartbeat != "" {
+		heartbeat, err := time.ParseDuration(cluster.Heartbeat)
+		if err != nil || heartbeat < time.Second {
+			return ErrInvalidClusterHeartbeat{Heartbeat: cluster.Heartbeat}
+		}
+		cluster.HeartbeatDuration = heartbeat
+	}
+
+	cluster.PeerTimeoutDuration = defaultClusterPeerTimeout
+	if cluster.PeerTimeout != "" {
+		peerTimeout, err := time.ParseDuration(cluster.PeerTimeout)
+		if err != nil || peerTimeout <= 0 {
+			return ErrInvalidClusterPeerTimeout{Timeout: cluster.PeerTimeout}
+		}
+		cluster.PeerTimeoutDuration = peerTimeout
+	}
+
+	if cluster.Address != "" && !util.IsUrl(cluster.Address) {
+		return ErrInvalidClusterAddress{Address: cluster.Address}
 	}
 
 	return nil
@@ -259,14 +671,21 @@ func (p *Proxy) registerHeader(header string) {
 }
 
 // DoPullHeaders will fill the given header map with configured headers
-// extracted from proxied requests to store alongside tile data in TilePackets
-func (p *Proxy) DoPullHeaders(resp *fiber.Response, headers map[string]string) {
+// extracted from proxied requests to store alongside tile data in TilePackets,
+// returning the space separated tags found in the tag header, if configured
+func (p *Proxy) DoPullHeaders(resp *fiber.Response, headers map[string]string) []string {
 	for _, header := range p.PullHeaders {
 		headerValue := resp.Header.Peek(header)
 		if len(headerValue) > 0 {
 			headers[header] = string(headerValue)
 		}
 	}
+
+	if p.TagHeader == "" {
+		return nil
+	}
+
+	return strings.Fields(string(resp.Header.Peek(p.TagHeader)))
 }
 
 // DoDeleteHeaders will strip headers from the response that are part of the
@@ -333,18 +752,47 @@ func validateProxy(num int, proxy *Proxy) error {
 		return errCache
 	}
 
+	// validate the proxy's client caching headers
+	if errClientCache := validateClientCache(proxy); errClientCache != nil {
+		return errClientCache
+	}
+
 	// validate the proxy's parameter configurations
 	if errParams := validateParams(proxy); errParams != nil {
 		return errParams
 	}
 
+	// validate the proxy's startup warmup
+	if errWarmup := validateWarmup(proxy); errWarmup != nil {
+		return errWarmup
+	}
+
+	// validate the proxy's scheduled jobs
+	if errSchedules := validateSchedules(proxy); errSchedules != nil {
+		return errSchedules
+	}
+
+	// validate the proxy's tile request heatmap
+	if errHeatmap := validateHeatmap(proxy); errHeatmap != nil {
+		return errHeatmap
+	}
+
+	// endpoint labels are capped to bound the number of series
+	if proxy.Metrics.EndpointLabels < 0 || proxy.Metrics.EndpointLabels > MaxMetricEndpointLabels {
+		return ErrInvalidMetricLabels{
+			ProxyName: proxy.Name,
+			Labels:    proxy.Metrics.EndpointLabels,
+		}
+	}
+
 	return nil
 }
 
 // validateCache will validate a proxy endpoint's cache configuration
 func validateCache(proxy *Proxy) error {
 	// ensure at least one cache is enabled
-	if !proxy.Cache.MemEnabled && !proxy.Cache.RedisEnabled {
+	if !proxy.Cache.MemEnabled && !proxy.Cache.DiskEnabled &&
+		!proxy.Cache.RedisEnabled && !proxy.Cache.MemcachedEnabled && !proxy.Cache.S3Enabled {
 		return ErrNoCacheEnabled{
 			ProxyName: proxy.Name,
 		}
@@ -355,11 +803,64 @@ func validateCache(proxy *Proxy) error {
 		return err
 	}
 
+	// validate on-disk cache configuration
+	if err := validateDiskCache(proxy); err != nil {
+		return err
+	}
+
 	// validate external cache configuration
 	if err := validateExternalCache(proxy); err != nil {
 		return err
 	}
 
+	// validate memcached cache configuration
+	if err := validateMemcachedCache(proxy); err != nil {
+		return err
+	}
+
+	// validate object storage cache configuration
+	if err := validateObjectCache(proxy); err != nil {
+		return err
+	}
+
+	// validate the order of cache levels
+	if err := validateLayers(proxy); err != nil {
+		return err
+	}
+
+	// validate zoom and endpoint specific TTL rules
+	if err := validateTTLRules(proxy); err != nil {
+		return err
+	}
+
+	// validate bounds of TTLs derived from upstream headers
+	if err := validateUpstreamTTLs(proxy); err != nil {
+		return err
+	}
+
+	// tile packets must be checksummed with a known algorithm
+	if _, ok := packet.ParseChecksum(proxy.Cache.Checksum); !ok {
+		return ErrInvalidChecksum{
+			ProxyName: proxy.Name,
+			Checksum:  proxy.Cache.Checksum,
+		}
+	}
+
+	// tile data must be compressed with a known codec
+	if err := validateCompression(proxy); err != nil {
+		return err
+	}
+
+	// generations of versioned proxies are stored in redis
+	if proxy.Cache.Versioned && !proxy.Cache.RedisEnabled {
+		return ErrVersionedNoRedis{ProxyName: proxy.Name}
+	}
+
+	// tiles in shared levels are found by tag through an index in redis
+	if proxy.TagHeader != "" && (proxy.Cache.MemcachedEnabled || proxy.Cache.S3Enabled) && !proxy.Cache.RedisEnabled {
+		return ErrTagsNoRedis{ProxyName: proxy.Name}
+	}
+
 	if !strings.Contains(proxy.Cache.KeyTemplate, "{z}") {
 		return ErrMissingCacheTemplate{
 			ProxyName: proxy.Name,
@@ -387,6 +888,57 @@ func validateCache(proxy *Proxy) error {
 	return nil
 }
 
+// validateHeatmap validates the sample rate and size of the tile request
+// heatmap, defaulting the number of ranked tiles
+func validateHeatmap(proxy *Proxy) error {
+	heatmap := &proxy.Heatmap
+	invalid := func(reason string) error {
+		return ErrInvalidHeatmap{ProxyName: proxy.Name, Reason: reason}
+	}
+
+	if heatmap.SampleRate < 0 || heatmap.SampleRate > 1 {
+		return invalid("sample_rate must be within [0, 1]")
+	}
+
+	if heatmap.TopK < 0 || heatmap.TopK > MaxHeatmapTopK {
+		return invalid(fmt.Sprintf("top_k must be within [0, %d]", MaxHeatmapTopK))
+	}
+
+	if heatmap.TopK == 0 {
+		heatmap.TopK = defaultHeatmapTopK
+	}
+
+	// instance counts are summed in redis
+	if heatmap.Redis && !proxy.Cache.RedisEnabled {
+		return invalid("redis requires redis_enabled")
+	}
+
+	return nil
+}
+
+// validateCompression validates the compression codec and threshold
+func validateCompression(proxy *Proxy) error {
+	if _, ok := packet.ParseCodec(proxy.Cache.Compression); !ok {
+		return ErrInvalidCompression{
+			ProxyName: proxy.Name,
+			Reason:    fmt.Sprintf("unknown codec '%s', must be one of none, gzip or zstd", proxy.Cache.Compression),
+		}
+	}
+
+	if proxy.Cache.CompressionThreshold < 0 {
+		return ErrInvalidCompression{
+			ProxyName: proxy.Name,
+			Reason:    "compression_threshold must not be negative",
+		}
+	}
+
+	if proxy.Cache.CompressionThreshold == 0 {
+		proxy.Cache.CompressionThreshold = defaultCompressionThreshold
+	}
+
+	return nil
+}
+
 // validateInternalCache validates internal cache configuration
 func validateInternalCache(proxy *Proxy) error {
 	// parse and validate in-memory cache parameters if enabled
@@ -418,21 +970,84 @@ func validateInternalCache(proxy *Proxy) error {
 	return nil
 }
 
-// validateExternalCache validates external cache configuration
-func validateExternalCache(proxy *Proxy) error {
-	// parse and validate redis cache parameters if enabled
-	if proxy.Cache.RedisEnabled {
-		// validate URL
-		var errParse error
-		proxy.Cache.RedisOpts, errParse = redis.ParseURL(proxy.Cache.RedisURL)
-		if errParse != nil {
-			return ErrInvalidRedisURL{
+// validateLayers validates the configured order of cache levels, which must
+// list every enabled level exactly once, defaulting to the standard order
+func validateLayers(proxy *Proxy) error {
+	if len(proxy.Cache.Layers) == 0 {
+		for _, layer := range defaultLayers {
+			if proxy.Cache.LayerEnabled(layer) {
+				proxy.Cache.Layers = append(proxy.Cache.Layers, layer)
+			}
+		}
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for _, layer := range proxy.Cache.Layers {
+		if !proxy.Cache.LayerEnabled(layer) {
+			return ErrInvalidCacheLayers{
+				ProxyName: proxy.Name,
+				Reason:    fmt.Sprintf("layer '%s' is unknown or not enabled", layer),
+			}
+		}
+
+		if seen[layer] {
+			return ErrInvalidCacheLayers{
+				ProxyName: proxy.Name,
+				Reason:    fmt.Sprintf("layer '%s' is listed more than once", layer),
+			}
+		}
+		seen[layer] = true
+	}
+
+	for _, layer := range defaultLayers {
+		if proxy.Cache.LayerEnabled(layer) && !seen[layer] {
+			return ErrInvalidCacheLayers{
 				ProxyName: proxy.Name,
-				URL:       proxy.Cache.RedisURL,
-				Err:       errParse,
+				Reason:    fmt.Sprintf("enabled layer '%s' is missing", layer),
 			}
 		}
+	}
+
+	return nil
+}
+
+// validateDiskCache validates on-disk cache configuration
+func validateDiskCache(proxy *Proxy) error {
+	// parse and validate on-disk cache parameters if enabled
+	if proxy.Cache.DiskEnabled {
+		if proxy.Cache.DiskPath == "" {
+			return ErrNoDiskPath{ProxyName: proxy.Name}
+		}
+
+		if proxy.Cache.DiskCap < 1 {
+			return ErrInvalidDiskCap{ProxyName: proxy.Name}
+		}
+
+		if proxy.Cache.DiskTTL != "" {
+			diskTTL, err := time.ParseDuration(proxy.Cache.DiskTTL)
+			if err != nil || diskTTL < 0 {
+				return ErrInvalidDiskTTL{
+					ProxyName: proxy.Name,
+					TTL:       proxy.Cache.DiskTTL,
+				}
+			}
 
+			proxy.Cache.DiskTTLDuration = diskTTL
+		} else {
+			// set TTL duration to zero if none specified, entries then only
+			// leave the on-disk cache when evicted or invalidated
+			proxy.Cache.DiskTTLDuration = 0
+		}
+	}
+
+	return nil
+}
+
+// validateExternalCache validates external cache configuration
+func validateExternalCache(proxy *Proxy) error {
+	// parse and validate redis cache parameters if enabled
+	if proxy.Cache.RedisEnabled {
 		// validate that TTL is sane
 		if proxy.Cache.RedisTTL != "" {
 			redisTTL, err := time.ParseDuration(proxy.Cache.RedisTTL)
@@ -461,6 +1076,255 @@ func validateExternalCache(proxy *Proxy) error {
 	return nil
 }
 
+// validateTTLRules validates the proxy's zoom and endpoint specific TTL rules
+func validateTTLRules(proxy *Proxy) error {
+	for i := range proxy.Cache.TTLRules {
+		rule := &proxy.Cache.TTLRules[i]
+		invalid := func(reason string) error {
+			return ErrInvalidTTLRule{ProxyName: proxy.Name, Number: i + 1, Reason: reason}
+		}
+
+		if rule.MinZoom < 0 || rule.MaxZoom < rule.MinZoom {
+			return invalid(fmt.Sprintf("invalid zoom range %d-%d", rule.MinZoom, rule.MaxZoom))
+		}
+
+		if rule.Endpoint != "" && !proxy.HasEndpointParam {
+			return invalid("endpoint given but the proxy has no dynamic endpoint")
+		}
+
+		if rule.MemTTL == "" && rule.RedisTTL == "" {
+			return invalid("at least one of mem_ttl or redis_ttl must be given")
+		}
+
+		if rule.MemTTL != "" {
+			memTTL, err := time.ParseDuration(rule.MemTTL)
+			// in-memory entries must expire
+			if err != nil || memTTL <= 0 {
+				return invalid(fmt.Sprintf("invalid mem_ttl of '%s'", rule.MemTTL))
+			}
+			if !proxy.Cache.MemEnabled {
+				return invalid("mem_ttl given but the in-memory cache is disabled")
+			}
+			rule.MemTTLDuration = memTTL
+		}
+
+		if rule.RedisTTL != "" {
+			redisTTL, err := time.ParseDuration(rule.RedisTTL)
+			if err != nil || redisTTL < 0 {
+				return invalid(fmt.Sprintf("invalid redis_ttl of '%s'", rule.RedisTTL))
+			}
+			if !proxy.Cache.RedisEnabled {
+				return invalid("redis_ttl given but the redis cache is disabled")
+			}
+			rule.RedisTTLDuration = redisTTL
+		}
+	}
+
+	return nil
+}
+
+// validateUpstreamTTLs validates the bounds of TTLs derived from upstream headers
+func validateUpstreamTTLs(proxy *Proxy) error {
+	bounds := []struct {
+		name     string
+		value    string
+		duration *time.Duration
+	}{
+		{"upstream_min_ttl", proxy.Cache.UpstreamMinTTL, &proxy.Cache.UpstreamMinTTLDuration},
+		{"upstream_max_ttl", proxy.Cache.UpstreamMaxTTL, &proxy.Cache.UpstreamMaxTTLDuration},
+	}
+
+	for _, bound := range bounds {
+		if bound.value == "" {
+			*bound.duration = 0
+			continue
+		}
+
+		duration, err := time.ParseDuration(bound.value)
+		if err != nil || duration < 0 {
+			return ErrInvalidUpstreamTTL{
+				ProxyName: proxy.Name,
+				Reason:    fmt.Sprintf("invalid %s of '%s'", bound.name, bound.value),
+			}
+		}
+		*bound.duration = duration
+	}
+
+	if proxy.Cache.UpstreamMaxTTLDuration > 0 &&
+		proxy.Cache.UpstreamMaxTTLDuration < proxy.Cache.UpstreamMinTTLDuration {
+		return ErrInvalidUpstreamTTL{
+			ProxyName: proxy.Name,
+			Reason:    "upstream_max_ttl must not be lower than upstream_min_ttl",
+		}
+	}
+
+	return nil
+}
+
+// validateClientCache validates the proxy's client caching headers and rules
+func validateClientCache(proxy *Proxy) error {
+	if reason := parseClientCache(&proxy.ClientCache); reason != "" {
+		return ErrInvalidClientCache{ProxyName: proxy.Name, Reason: reason}
+	}
+
+	for i := range proxy.ClientCache.Rules {
+		rule := &proxy.ClientCache.Rules[i]
+		invalid := func(reason string) error {
+			return ErrInvalidClientCache{ProxyName: proxy.Name, Reason: fmt.Sprintf("rule #%d %s", i+1, reason)}
+		}
+
+		if rule.MinZoom < 0 || rule.MaxZoom < rule.MinZoom {
+			return invalid(fmt.Sprintf("has invalid zoom range %d-%d", rule.MinZoom, rule.MaxZoom))
+		}
+
+		if len(rule.Rules) > 0 {
+			return invalid("may not have rules of its own")
+		}
+
+		if reason := parseClientCache(rule); reason != "" {
+			return invalid(reason)
+		}
+	}
+
+	return nil
+}
+
+// parseClientCache parses the durations of a client caching policy, returning
+// the reason the policy is invalid, if any
+func parseClientCache(c *ClientCache) string {
+	durations := []struct {
+		name     string
+		value    string
+		duration *time.Duration
+	}{
+		{"max_age", c.MaxAge, &c.MaxAgeDuration},
+		{"s_maxage", c.SMaxAge, &c.SMaxAgeDuration},
+		{"stale_while_revalidate", c.StaleWhileRevalidate, &c.StaleWhileRevalidateDuration},
+	}
+
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+
+		duration, err := time.ParseDuration(d.value)
+		if err != nil || duration < 0 {
+			return fmt.Sprintf("invalid %s of '%s'", d.name, d.value)
+		}
+		*d.duration = duration
+	}
+
+	return ""
+}
+
+// validateMemcachedCache validates memcached cache configuration
+func validateMemcachedCache(proxy *Proxy) error {
+	if !proxy.Cache.MemcachedEnabled {
+		return nil
+	}
+
+	if len(proxy.Cache.MemcachedServers) == 0 {
+		return ErrInvalidMemcachedCache{
+			ProxyName: proxy.Name,
+			Reason:    "memcached_servers must list at least one server",
+		}
+	}
+
+	for _, server := range proxy.Cache.MemcachedServers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			return ErrInvalidMemcachedCache{
+				ProxyName: proxy.Name,
+				Reason:    fmt.Sprintf("server '%s' must be a host:port address", server),
+			}
+		}
+	}
+
+	if proxy.Cache.MemcachedItemSize == 0 {
+		proxy.Cache.MemcachedItemSize = 1024
+	} else if proxy.Cache.MemcachedItemSize < 0 {
+		return ErrInvalidMemcachedCache{
+			ProxyName: proxy.Name,
+			Reason:    "memcached_item_size must be at least 1 KB",
+		}
+	}
+
+	if proxy.Cache.MemcachedTTL != "" {
+		memcachedTTL, err := time.ParseDuration(proxy.Cache.MemcachedTTL)
+		if err != nil || memcachedTTL < 0 {
+			return ErrInvalidMemcachedCache{
+				ProxyName: proxy.Name,
+				Reason: fmt.Sprintf("invalid memcached_ttl of '%s', valid time units are "+
+					"\"ns\", \"us\" (or \"µs\"), \"ms\", \"s\", \"m\", \"h\"", proxy.Cache.MemcachedTTL),
+			}
+		}
+
+		proxy.Cache.MemcachedTTLDuration = memcachedTTL
+	} else {
+		// items never expire if no TTL specified, leaving
+		// memcached only when evicted or invalidated
+		proxy.Cache.MemcachedTTLDuration = 0
+	}
+
+	proxy.Cache.MemcachedTimeoutDuration = 500 * time.Millisecond
+	if proxy.Cache.MemcachedTimeout != "" {
+		timeout, err := time.ParseDuration(proxy.Cache.MemcachedTimeout)
+		if err != nil || timeout <= 0 {
+			return ErrInvalidMemcachedCache{
+				ProxyName: proxy.Name,
+				Reason:    fmt.Sprintf("invalid memcached_timeout of '%s'", proxy.Cache.MemcachedTimeout),
+			}
+		}
+
+		proxy.Cache.MemcachedTimeoutDuration = timeout
+	}
+
+	return nil
+}
+
+// validateObjectCache validates object storage cache configuration
+func validateObjectCache(proxy *Proxy) error {
+	if !proxy.Cache.S3Enabled {
+		return nil
+	}
+
+	endpoint, err := url.Parse(proxy.Cache.S3Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return ErrInvalidObjectCache{
+			ProxyName: proxy.Name,
+			Reason:    fmt.Sprintf("s3_endpoint '%s' must be an absolute URL", proxy.Cache.S3Endpoint),
+		}
+	}
+
+	if proxy.Cache.S3Bucket == "" {
+		return ErrInvalidObjectCache{
+			ProxyName: proxy.Name,
+			Reason:    "s3_bucket must be configured",
+		}
+	}
+
+	if proxy.Cache.S3Prefix == "" {
+		proxy.Cache.S3Prefix = proxy.Name + "/"
+	}
+
+	if proxy.Cache.S3TTL != "" {
+		s3TTL, errTTL := time.ParseDuration(proxy.Cache.S3TTL)
+		if errTTL != nil || s3TTL < 0 {
+			return ErrInvalidObjectCache{
+				ProxyName: proxy.Name,
+				Reason: fmt.Sprintf("invalid s3_ttl of '%s', valid time units are "+
+					"\"ns\", \"us\" (or \"µs\"), \"ms\", \"s\", \"m\", \"h\"", proxy.Cache.S3TTL),
+			}
+		}
+
+		proxy.Cache.S3TTLDuration = s3TTL
+	} else {
+		// objects never expire if no TTL specified
+		proxy.Cache.S3TTLDuration = 0
+	}
+
+	return nil
+}
+
 // validateParams ensures configured params have valid and non-overlapping names
 func validateParams(proxy *Proxy) error {
 	if len(proxy.Params) == 0 {
@@ -493,6 +1357,129 @@ func validateParams(proxy *Proxy) error {
 	return nil
 }
 
+// tilePattern matches root tile targets formatted as z/x/y
+var tilePattern = regexp.MustCompile(`^\d+/\d+/\d+$`)
+
+// validateSchedules ensures configured schedules have valid cron expressions,
+// exactly one target, sane zoom ranges and a supported mode
+func validateSchedules(proxy *Proxy) error {
+	for i := range proxy.Schedules {
+		schedule := &proxy.Schedules[i]
+
+		if schedule.Name == "" {
+			schedule.Name = fmt.Sprintf("schedule-%d", i+1)
+		}
+
+		spec, err := cron.Parse(schedule.Cron)
+		if err != nil {
+			return ErrInvalidScheduleCron{
+				ProxyName: proxy.Name,
+				Schedule:  schedule.Name,
+				Err:       err,
+			}
+		}
+		schedule.Spec = spec
+
+		invalid := func(reason string) error {
+			return ErrInvalidSchedule{
+				ProxyName: proxy.Name,
+				Schedule:  schedule.Name,
+				Reason:    reason,
+			}
+		}
+
+		switch schedule.Mode {
+		case ScheduleModePrime, ScheduleModeInvalidate, ScheduleModeRefresh:
+		default:
+			return invalid(fmt.Sprintf("unknown mode '%s', expected one of %s, %s or %s", schedule.Mode,
+				ScheduleModePrime, ScheduleModeInvalidate, ScheduleModeRefresh))
+		}
+
+		targets := 0
+		if schedule.Tile != "" {
+			targets++
+			if !tilePattern.MatchString(schedule.Tile) {
+				return invalid(fmt.Sprintf("tile '%s' must be formatted as z/x/y", schedule.Tile))
+			}
+		}
+		if schedule.BBox != nil {
+			targets++
+			if len(schedule.BBox) != 4 || schedule.BBox[0] >= schedule.BBox[2] || schedule.BBox[1] >= schedule.BBox[3] {
+				return invalid("bbox must be [min_lon, min_lat, max_lon, max_lat]")
+			}
+		}
+		if schedule.GeometryFile != "" {
+			targets++
+			if _, errStat := os.Stat(schedule.GeometryFile); errStat != nil {
+				return invalid(fmt.Sprintf("geometry file unreadable: %s", errStat.Error()))
+			}
+		}
+		if targets != 1 {
+			return invalid("exactly one of tile, bbox or geometry_file must be configured")
+		}
+
+		if schedule.MinZoom < 0 || schedule.MaxZoom > maxScheduleZoom || schedule.MinZoom > schedule.MaxZoom {
+			return invalid(fmt.Sprintf("zoom range [%d, %d] must be within [0, %d]",
+				schedule.MinZoom, schedule.MaxZoom, maxScheduleZoom))
+		}
+
+		if proxy.HasEndpointParam && schedule.Endpoint == "" {
+			return invalid("endpoint must be set for proxies with a dynamic {e} endpoint")
+		}
+	}
+
+	return nil
+}
+
+// validateWarmup validates the proxy's startup warmup configuration
+func validateWarmup(proxy *Proxy) error {
+	warmup := &proxy.Warmup
+	invalid := func(reason string) error {
+		return ErrInvalidWarmup{ProxyName: proxy.Name, Reason: reason}
+	}
+
+	if warmup.TileFile != "" {
+		if _, err := os.Stat(warmup.TileFile); err != nil {
+			return invalid(fmt.Sprintf("tile file unreadable: %s", err.Error()))
+		}
+	}
+
+	if warmup.BBox != nil {
+		if len(warmup.BBox) != 4 || warmup.BBox[0] >= warmup.BBox[2] || warmup.BBox[1] >= warmup.BBox[3] {
+			return invalid("bbox must be [min_lon, min_lat, max_lon, max_lat]")
+		}
+
+		if warmup.MinZoom < 0 || warmup.MaxZoom > maxScheduleZoom || warmup.MinZoom > warmup.MaxZoom {
+			return invalid(fmt.Sprintf("zoom range [%d, %d] must be within [0, %d]",
+				warmup.MinZoom, warmup.MaxZoom, maxScheduleZoom))
+		}
+	}
+
+	if (warmup.TileFile != "" || warmup.BBox != nil) && proxy.HasEndpointParam && warmup.Endpoint == "" {
+		return invalid("endpoint must be set for proxies with a dynamic {e} endpoint")
+	}
+
+	if warmup.HotKeys < 0 || warmup.HotKeys > MaxWarmupHotKeys {
+		return invalid(fmt.Sprintf("hot_keys must be within [0, %d]", MaxWarmupHotKeys))
+	}
+
+	// popular keys are tracked in redis
+	if warmup.HotKeys > 0 && !proxy.Cache.RedisEnabled {
+		return invalid("hot_keys requires redis_enabled")
+	}
+
+	warmup.TimeoutDuration = defaultWarmupTimeout
+	if warmup.Timeout != "" {
+		timeout, err := time.ParseDuration(warmup.Timeout)
+		if err != nil || timeout <= 0 {
+			return invalid(fmt.Sprintf("invalid timeout of '%s'", warmup.Timeout))
+		}
+		warmup.TimeoutDuration = timeout
+	}
+
+	return nil
+}
+
 // GetPort returns the configured primary HTTP port
 // or DefaultPort if none configured
 func GetPort() int {
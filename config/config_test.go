@@ -0,0 +1,94 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+// TestMaxSharedTTL will test finding the longest TTL tiles can be stored
+// with in the enabled shared cache levels
+func TestMaxSharedTTL(t *testing.T) {
+	tests := []struct {
+		name  string
+		cache Cache
+		ttl   time.Duration
+	}{
+		{"none", Cache{MemEnabled: true, MemTTLDuration: time.Hour}, 0},
+		{"redis", Cache{RedisEnabled: true, RedisTTLDuration: time.Hour}, time.Hour},
+		{"rule", Cache{RedisEnabled: true, RedisTTLDuration: time.Hour, TTLRules: []TTLRule{
+			{RedisTTL: "48h", RedisTTLDuration: 48 * time.Hour},
+		}}, 48 * time.Hour},
+		{"s3", Cache{RedisEnabled: true, RedisTTLDuration: time.Hour,
+			S3Enabled: true, S3TTLDuration: 720 * time.Hour}, 720 * time.Hour},
+		{"never", Cache{RedisEnabled: true, RedisTTLDuration: time.Hour,
+			MemcachedEnabled: true}, 0},
+	}
+
+	for _, test := range tests {
+		if ttl := test.cache.MaxSharedTTL(); ttl != test.ttl {
+			t.Errorf("%s: expected %s, got %s", test.name, test.ttl, ttl)
+		}
+	}
+}
+
+// TestClientCachePolicy will test picking the client caching policy of a
+// zoom level and formatting its Cache-Control header
+func TestClientCachePolicy(t *testing.T) {
+	proxy := &Proxy{Name: "test", ClientCache: ClientCache{
+		MaxAge:               "1h",
+		StaleWhileRevalidate: "10m",
+		SurrogateControl:     "max-age=86400",
+		Rules: []ClientCache{
+			{MinZoom: 0, MaxZoom: 8, MaxAge: "24h", SMaxAge: "168h", Immutable: true},
+			{MinZoom: 6, MaxZoom: 12, MaxAge: "30m"},
+			{MinZoom: 13, MaxZoom: 14},
+		},
+	}}
+
+	if err := validateClientCache(proxy); err != nil {
+		t.Fatalf("failed to validate client cache: %s", err)
+	}
+
+	tests := []struct {
+		zoom             int
+		cacheControl     string
+		surrogateControl string
+	}{
+		{0, "max-age=86400, s-maxage=604800, immutable", ""},
+		{8, "max-age=86400, s-maxage=604800, immutable", ""},
+		{9, "max-age=1800", ""},
+		{13, "", ""},
+		{15, "max-age=3600, stale-while-revalidate=600", "max-age=86400"},
+	}
+
+	for _, test := range tests {
+		policy := proxy.ClientCache.Policy(test.zoom)
+		if cacheControl := policy.CacheControl(); cacheControl != test.cacheControl {
+			t.Errorf("zoom %d: expected Cache-Control %q, got %q", test.zoom, test.cacheControl, cacheControl)
+		}
+		if policy.SurrogateControl != test.surrogateControl {
+			t.Errorf("zoom %d: expected Surrogate-Control %q, got %q",
+				test.zoom, test.surrogateControl, policy.SurrogateControl)
+		}
+	}
+}
+
+// TestClientCacheInvalid will test rejecting invalid client caching policies
+func TestClientCacheInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ClientCache
+	}{
+		{"duration", ClientCache{MaxAge: "soon"}},
+		{"negative", ClientCache{SMaxAge: "-1h"}},
+		{"zoom range", ClientCache{Rules: []ClientCache{{MinZoom: 8, MaxZoom: 4}}}},
+		{"nested", ClientCache{Rules: []ClientCache{{MaxZoom: 4, Rules: []ClientCache{{}}}}}},
+		{"rule duration", ClientCache{Rules: []ClientCache{{MaxZoom: 4, MaxAge: "1 hour"}}}},
+	}
+
+	for _, test := range tests {
+		if err := validateClientCache(&Proxy{Name: "test", ClientCache: test.policy}); err == nil {
+			t.Errorf("%s: expected policy to be rejected", test.name)
+		}
+	}
+}
@@ -29,6 +29,64 @@ func (e ErrInvalidPort) Error() string {
 	return fmt.Sprintf("config:instance invalid port '%d', valid ports are 1-65535", e.Port)
 }
 
+// ErrInvalidClusterRedisURL is an error struct for invalid cluster
+// redis URL, caught during the instance validation phase
+type ErrInvalidClusterRedisURL struct {
+	URL string
+	Err error
+}
+
+// Error returns the string representation of ErrInvalidClusterRedisURL
+func (e ErrInvalidClusterRedisURL) Error() string {
+	return fmt.Sprintf("config:instance:cluster invalid Redis URL '%s': %s", e.URL, e.Err.Error())
+}
+
+// ErrInvalidClusterAckTimeout is an error struct for an invalid cluster
+// acknowledgement timeout, caught during the instance validation phase
+type ErrInvalidClusterAckTimeout struct {
+	Timeout string
+}
+
+// Error returns the string representation of ErrInvalidClusterAckTimeout
+func (e ErrInvalidClusterAckTimeout) Error() string {
+	return fmt.Sprintf("config:instance:cluster invalid ack timeout of '%s', "+
+		"valid time units are \"ns\", \"us\" (or \"µs\"), \"ms\", \"s\", \"m\", \"h\"", e.Timeout)
+}
+
+// ErrInvalidClusterHeartbeat is an error struct for an invalid cluster
+// heartbeat interval, caught during the instance validation phase
+type ErrInvalidClusterHeartbeat struct {
+	Heartbeat string
+}
+
+// Error returns the string representation of ErrInvalidClusterHeartbeat
+func (e ErrInvalidClusterHeartbeat) Error() string {
+	return fmt.Sprintf("config:instance:cluster invalid heartbeat of '%s', must be at least 1s", e.Heartbeat)
+}
+
+// ErrInvalidClusterPeerTimeout is an error struct for an invalid cluster
+// peer request timeout, caught during the instance validation phase
+type ErrInvalidClusterPeerTimeout struct {
+	Timeout string
+}
+
+// Error returns the string representation of ErrInvalidClusterPeerTimeout
+func (e ErrInvalidClusterPeerTimeout) Error() string {
+	return fmt.Sprintf("config:instance:cluster invalid peer timeout of '%s', "+
+		"valid time units are \"ns\", \"us\" (or \"µs\"), \"ms\", \"s\", \"m\", \"h\"", e.Timeout)
+}
+
+// ErrInvalidClusterAddress is an error struct for an invalid cluster
+// advertised address, caught during the instance validation phase
+type ErrInvalidClusterAddress struct {
+	Address string
+}
+
+// Error returns the string representation of ErrInvalidClusterAddress
+func (e ErrInvalidClusterAddress) Error() string {
+	return fmt.Sprintf("config:instance:cluster invalid address '%s', must be a URL like http://host:port", e.Address)
+}
+
 // ErrProxyNoName is an error struct for a proxy defined
 // without a name, caught during the proxy param validation phase
 type ErrProxyNoName struct {
@@ -78,8 +136,8 @@ func (e ErrMissingTileURLTemplate) Error() string {
 		e.ProxyName, e.TileURL, e.Parameter)
 }
 
-// ErrNoCacheEnabled is an error struct thrown when neither
-// the internal nor external cache are enabled
+// ErrNoCacheEnabled is an error struct thrown when none of
+// the internal, on-disk or external caches are enabled
 type ErrNoCacheEnabled struct {
 	ProxyName string
 }
@@ -116,6 +174,44 @@ func (e ErrInvalidMemTTL) Error() string {
 		e.ProxyName, e.TTL)
 }
 
+// ErrNoDiskPath is an error struct for an on-disk cache
+// without a directory, caught during the proxy validation phase
+type ErrNoDiskPath struct {
+	ProxyName string
+}
+
+// Error returns the string representation of ErrNoDiskPath
+func (e ErrNoDiskPath) Error() string {
+	return fmt.Sprintf("config:proxy(%s):cache on-disk cache requires a disk_path",
+		e.ProxyName)
+}
+
+// ErrInvalidDiskCap is an error struct for invalid on-disk
+// cache capacity, caught during the proxy validation phase
+type ErrInvalidDiskCap struct {
+	ProxyName string
+}
+
+// Error returns the string representation of ErrInvalidDiskCap
+func (e ErrInvalidDiskCap) Error() string {
+	return fmt.Sprintf("config:proxy(%s):cache cannot have zero or negative disk capacity",
+		e.ProxyName)
+}
+
+// ErrInvalidDiskTTL is an error struct for invalid on-disk
+// cache TTL, caught during the proxy cache validation phase
+type ErrInvalidDiskTTL struct {
+	ProxyName string
+	TTL       string
+}
+
+// Error returns the string representation of ErrInvalidDiskTTL
+func (e ErrInvalidDiskTTL) Error() string {
+	return fmt.Sprintf("config:proxy(%s):cache invalid disk TTL of '%s', "+
+		"valid time units are \"ns\", \"us\" (or \"µs\"), \"ms\", \"s\", \"m\", \"h\"",
+		e.ProxyName, e.TTL)
+}
+
 // ErrInvalidRedisTTL is an error struct for invalid redis
 // cache TTL, caught during the proxy cache validation phase
 type ErrInvalidRedisTTL struct {
@@ -144,6 +240,162 @@ func (e ErrInvalidRedisTTL) Error() string {
 		e.ProxyName, e.TTL)
 }
 
+// ErrInvalidRedisMode is an error struct for an invalid or incomplete redis
+// deployment mode configuration, caught during the proxy cache validation phase
+type ErrInvalidRedisMode struct {
+	ProxyName string
+	Mode      string
+	Reason    string
+}
+
+// Error returns the string representation of ErrInvalidRedisMode
+func (e ErrInvalidRedisMode) Error() string {
+	return fmt.Sprintf("config:proxy(%s):cache invalid Redis mode '%s': %s",
+		e.ProxyName, e.Mode, e.Reason)
+}
+
+// ErrInvalidRedisConnection is an error struct for an invalid shared redis
+// connection definition, caught during the instance validation phase
+type ErrInvalidRedisConnection struct {
+	Name   string
+	Reason string
+}
+
+// Error returns the string representation of ErrInvalidRedisConnection
+func (e ErrInvalidRedisConnection) Error() string {
+	return fmt.Sprintf("config:instance:redis(%s) invalid connection: %s",
+		e.Name, e.Reason)
+}
+
+// ErrUnknownRedisConnection is an error struct for a proxy cache referencing
+// a shared redis connection that was never defined
+type ErrUnknownRedisConnection struct {
+	ProxyName string
+	Name      string
+}
+
+// Error returns the string representation of ErrUnknownRedisConnection
+func (e ErrUnknownRedisConnection) Error() string {
+	return fmt.Sprintf("config:proxy(%s):cache references unknown redis connection '%s'",
+		e.ProxyName, e.Name)
+}
+
+// ErrInvalidObjectCache is an error struct for an invalid object storage
+// cache configuration, caught during the proxy cache validation phase
+type ErrInvalidObjectCache struct {
+	ProxyName string
+	Reason    string
+}
+
+// Error returns the string representation of ErrInvalidObjectCache
+func (e ErrInvalidObjectCache) Error() string {
+	return fmt.Sprintf("config:proxy(%s):cache invalid object storage configuration: %s",
+		e.ProxyName, e.Reason)
+}
+
+// ErrInvalidMemcachedCache is an error struct for an invalid memcached
+// cache configuration, caught during the proxy cache validation phase
+type ErrInvalidMemcachedCache struct {
+	ProxyName string
+	Reason    string
+}
+
+// Error returns the string representation of ErrInvalidMemcachedCache
+func (e ErrInvalidMemcachedCache) Error() string {
+	return fmt.Sprintf("config:proxy(%s):cache invalid memcached configuration: %s",
+		e.ProxyName, e.Reason)
+}
+
+// ErrInvalidTTLRule is an error struct for an invalid zoom
+// or endpoint specific TTL rule, caught during the proxy cache validation phase
+type ErrInvalidTTLRule struct {
+	ProxyName string
+	Number    int
+	Reason    string
+}
+
+// Error returns the string representation of ErrInvalidTTLRule
+func (e ErrInvalidTTLRule) Error() string {
+	return fmt.Sprintf("config:proxy(%s):cache ttl rule #%d is invalid: %s",
+		e.ProxyName, e.Number, e.Reason)
+}
+
+// ErrInvalidUpstreamTTL is an error struct for invalid bounds of TTLs
+// derived from upstream headers, caught during the proxy cache validation phase
+type ErrInvalidUpstreamTTL struct {
+	ProxyName string
+	Reason    string
+}
+
+// Error returns the string representation of ErrInvalidUpstreamTTL
+func (e ErrInvalidUpstreamTTL) Error() string {
+	return fmt.Sprintf("config:proxy(%s):cache invalid upstream TTL bounds: %s",
+		e.ProxyName, e.Reason)
+}
+
+// ErrInvalidChecksum is an error struct for unknown tile packet checksum
+// algorithms, caught during the proxy cache validation phase
+type ErrInvalidChecksum struct {
+	ProxyName string
+	Checksum  string
+}
+
+// Error returns the string representation of ErrInvalidChecksum
+func (e ErrInvalidChecksum) Error() string {
+	return fmt.Sprintf("config:proxy(%s):cache invalid checksum '%s', must be one of sha256, crc32c or xxhash",
+		e.ProxyName, e.Checksum)
+}
+
+// ErrInvalidCompression is an error struct for invalid compression settings
+// of cached tile data, caught during the proxy cache validation phase
+type ErrInvalidCompression struct {
+	ProxyName string
+	Reason    string
+}
+
+// Error returns the string representation of ErrInvalidCompression
+func (e ErrInvalidCompression) Error() string {
+	return fmt.Sprintf("config:proxy(%s):cache invalid compression: %s",
+		e.ProxyName, e.Reason)
+}
+
+// ErrVersionedNoRedis is an error struct thrown when a versioned
+// proxy has no redis cache to store its generations in
+type ErrVersionedNoRedis struct {
+	ProxyName string
+}
+
+// Error returns the string representation of ErrVersionedNoRedis
+func (e ErrVersionedNoRedis) Error() string {
+	return fmt.Sprintf("config:proxy(%s):cache versioned caches require redis_enabled",
+		e.ProxyName)
+}
+
+// ErrTagsNoRedis is an error struct thrown when a proxy indexes tiles by tag
+// in shared cache levels without a redis cache to store the tag index in
+type ErrTagsNoRedis struct {
+	ProxyName string
+}
+
+// Error returns the string representation of ErrTagsNoRedis
+func (e ErrTagsNoRedis) Error() string {
+	return fmt.Sprintf("config:proxy(%s):cache tag_header with memcached or s3 levels requires redis_enabled",
+		e.ProxyName)
+}
+
+// ErrInvalidCacheLayers is an error struct for an invalid cache level
+// order, caught during the proxy cache validation phase
+type ErrInvalidCacheLayers struct {
+	ProxyName string
+	Reason    string
+}
+
+// Error returns the string representation of ErrInvalidCacheLayers
+func (e ErrInvalidCacheLayers) Error() string {
+	return fmt.Sprintf("config:proxy(%s):cache invalid layers: %s",
+		e.ProxyName, e.Reason)
+}
+
 // ErrMissingCacheTemplate is an error struct for a proxy cache key template
 // without a required parameter, caught during the proxy param validation phase
 type ErrMissingCacheTemplate struct {
@@ -158,6 +410,32 @@ func (e ErrMissingCacheTemplate) Error() string {
 		e.ProxyName, e.Template, e.Parameter)
 }
 
+// ErrInvalidClientCache is an error struct for invalid client caching
+// headers, caught during the proxy validation phase
+type ErrInvalidClientCache struct {
+	ProxyName string
+	Reason    string
+}
+
+// Error returns the string representation of ErrInvalidClientCache
+func (e ErrInvalidClientCache) Error() string {
+	return fmt.Sprintf("config:proxy(%s):client_cache %s",
+		e.ProxyName, e.Reason)
+}
+
+// ErrInvalidWarmup is an error struct for an invalid startup
+// warmup configuration, caught during the proxy validation phase
+type ErrInvalidWarmup struct {
+	ProxyName string
+	Reason    string
+}
+
+// Error returns the string representation of ErrInvalidWarmup
+func (e ErrInvalidWarmup) Error() string {
+	return fmt.Sprintf("config:proxy(%s):warmup %s",
+		e.ProxyName, e.Reason)
+}
+
 // ErrParamNoName is an error struct for a proxy parameter
 // without a name, caught during the proxy param validation phase
 type ErrParamNoName struct {
@@ -183,3 +461,56 @@ func (e ErrParamNameDuplicate) Error() string {
 	return fmt.Sprintf("config:proxy(%s):params duplicate parameter with name '%s'",
 		e.ProxyName, e.Parameter.Name)
 }
+
+// ErrInvalidScheduleCron is an error struct for a proxy schedule with an
+// unparseable cron expression, caught during the proxy schedule validation phase
+type ErrInvalidScheduleCron struct {
+	ProxyName string
+	Schedule  string
+	Err       error
+}
+
+// Error returns the string representation of ErrInvalidScheduleCron
+func (e ErrInvalidScheduleCron) Error() string {
+	return fmt.Sprintf("config:proxy(%s):schedules(%s) %s",
+		e.ProxyName, e.Schedule, e.Err.Error())
+}
+
+// ErrInvalidSchedule is an error struct for a misconfigured proxy
+// schedule, caught during the proxy schedule validation phase
+type ErrInvalidSchedule struct {
+	ProxyName string
+	Schedule  string
+	Reason    string
+}
+
+// Error returns the string representation of ErrInvalidSchedule
+func (e ErrInvalidSchedule) Error() string {
+	return fmt.Sprintf("config:proxy(%s):schedules(%s) %s",
+		e.ProxyName, e.Schedule, e.Reason)
+}
+
+// ErrInvalidMetricLabels is an error struct for a number of metric endpoint
+// labels outside of the allowed range
+type ErrInvalidMetricLabels struct {
+	ProxyName string
+	Labels    int
+}
+
+// Error returns the string representation of ErrInvalidMetricLabels
+func (e ErrInvalidMetricLabels) Error() string {
+	return fmt.Sprintf("config:proxy(%s):metrics endpoint_labels %d must be between 0 and %d",
+		e.ProxyName, e.Labels, MaxMetricEndpointLabels)
+}
+
+// ErrInvalidHeatmap is an error struct for a misconfigured proxy tile
+// request heatmap, caught during the proxy heatmap validation phase
+type ErrInvalidHeatmap struct {
+	ProxyName string
+	Reason    string
+}
+
+// Error returns the string representation of ErrInvalidHeatmap
+func (e ErrInvalidHeatmap) Error() string {
+	return fmt.Sprintf("config:proxy(%s):heatmap %s", e.ProxyName, e.Reason)
+}
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis deployment modes supported by the external cache
+const (
+	RedisModeStandalone = "standalone" // a single Redis server, configured with a connection URL
+	RedisModeCluster    = "cluster"    // a Redis Cluster, configured with seed addresses
+	RedisModeSentinel   = "sentinel"   // a Sentinel-managed failover group, configured with sentinel addresses
+)
+
+// RedisConnection is a named Redis connection definition. Connections are
+// defined once at the instance level and referenced by name from any number of
+// proxies, which then share a single client and connection pool.
+// Connection timeouts are set using Go's built-in time.ParseDuration
+type RedisConnection struct {
+	Name string `json:"name" toml:"name"` // name proxies reference this connection by
+	// Example: redis://<user>:<password>@<host>:<port>/<db_number>
+	URL              string                  `json:"-" toml:"url"`                         // full redis connection URL for parsing, SENSITIVE, standalone mode only
+	TLS              bool                    `json:"tls" toml:"tls"`                       // whether to use TLS when connecting to the redis server
+	Mode             string                  `json:"mode" toml:"mode"`                     // one of standalone (default), cluster or sentinel
+	Addrs            []string                `json:"addrs" toml:"addrs"`                   // cluster seed or sentinel host:port addresses
+	MasterName       string                  `json:"master_name" toml:"master_name"`       // sentinel master name, sentinel mode only
+	Username         string                  `json:"-" toml:"username"`                    // redis ACL username, SENSITIVE
+	Password         string                  `json:"-" toml:"password"`                    // redis password, SENSITIVE
+	SentinelPassword string                  `json:"-" toml:"sentinel_password"`           // sentinel password if different, SENSITIVE
+	DB               int                     `json:"db" toml:"db"`                         // database number, standalone and sentinel modes only
+	PoolSize         int                     `json:"pool_size" toml:"pool_size"`           // maximum connections per node, default 10 per CPU
+	MinIdleConns     int                     `json:"min_idle_conns" toml:"min_idle_conns"` // minimum idle connections kept open per node
+	DialTimeout      string                  `json:"dial_timeout" toml:"dial_timeout"`     // timeout for establishing new connections, default 5s
+	ReadTimeout      string                  `json:"read_timeout" toml:"read_timeout"`     // timeout for socket reads, default 3s
+	WriteTimeout     string                  `json:"write_timeout" toml:"write_timeout"`   // timeout for socket writes, default ReadTimeout
+	PoolTimeout      string                  `json:"pool_timeout" toml:"pool_timeout"`     // time to wait for a free pooled connection, default ReadTimeout + 1s
+	IdleTimeout      string                  `json:"idle_timeout" toml:"idle_timeout"`     // time after which idle connections are closed, default 5m
+	Shared           bool                    `json:"shared" toml:"-"`                      // whether this connection was defined at the instance level
+	Options          *redis.UniversalOptions `json:"-" toml:"-"`                           // internal client options, first parsed with config
+}
+
+// errRedisURL wraps redis URL parse errors so they can be reported distinctly
+type errRedisURL struct {
+	err error
+}
+
+func (e errRedisURL) Error() string {
+	return e.err.Error()
+}
+
+// build validates the connection settings and parses them into client options
+func (r *RedisConnection) build() error {
+	options := &redis.UniversalOptions{}
+
+	switch r.Mode {
+	case "", RedisModeStandalone:
+		r.Mode = RedisModeStandalone
+
+		parsed, err := redis.ParseURL(r.URL)
+		if err != nil {
+			return errRedisURL{err: err}
+		}
+
+		options.Addrs = []string{parsed.Addr}
+		options.DB = parsed.DB
+		options.Username = parsed.Username
+		options.Password = parsed.Password
+		options.TLSConfig = parsed.TLSConfig
+	case RedisModeCluster, RedisModeSentinel:
+		if len(r.Addrs) == 0 {
+			return fmt.Errorf("at least one address must be configured")
+		}
+
+		if r.Mode == RedisModeSentinel && r.MasterName == "" {
+			return fmt.Errorf("a master name must be configured")
+		}
+
+		options.Addrs = r.Addrs
+		options.DB = r.DB
+		options.Username = r.Username
+		options.Password = r.Password
+		options.SentinelPassword = r.SentinelPassword
+		options.MasterName = r.MasterName
+	default:
+		return fmt.Errorf("expected mode to be one of %s, %s or %s",
+			RedisModeStandalone, RedisModeCluster, RedisModeSentinel)
+	}
+
+	if r.TLS && options.TLSConfig == nil {
+		options.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	if r.PoolSize < 0 || r.MinIdleConns < 0 {
+		return fmt.Errorf("pool_size and min_idle_conns cannot be negative")
+	}
+	options.PoolSize = r.PoolSize
+	options.MinIdleConns = r.MinIdleConns
+
+	timeouts := []struct {
+		name  string
+		value string
+		dest  *time.Duration
+	}{
+		{"dial_timeout", r.DialTimeout, &options.DialTimeout},
+		{"read_timeout", r.ReadTimeout, &options.ReadTimeout},
+		{"write_timeout", r.WriteTimeout, &options.WriteTimeout},
+		{"pool_timeout", r.PoolTimeout, &options.PoolTimeout},
+		{"idle_timeout", r.IdleTimeout, &options.IdleTimeout},
+	}
+
+	for _, timeout := range timeouts {
+		if timeout.value == "" {
+			continue
+		}
+
+		duration, err := time.ParseDuration(timeout.value)
+		if err != nil || duration < 0 {
+			return fmt.Errorf("invalid %s of '%s', valid time units are "+
+				"\"ns\", \"us\" (or \"µs\"), \"ms\", \"s\", \"m\", \"h\"", timeout.name, timeout.value)
+		}
+		*timeout.dest = duration
+	}
+
+	r.Options = options
+	return nil
+}
+
+// Fingerprint uniquely identifies the connection settings, so that clients
+// can be shared between identical connection definitions and replaced when a
+// definition changes across configuration reloads
+func (r *RedisConnection) Fingerprint() string {
+	return strings.Join([]string{
+		r.Name, r.Mode, r.URL, strings.Join(r.Addrs, ","), r.MasterName,
+		r.Username, r.Password, r.SentinelPassword, fmt.Sprint(r.DB, r.TLS, r.PoolSize, r.MinIdleConns),
+		r.DialTimeout, r.ReadTimeout, r.WriteTimeout, r.PoolTimeout, r.IdleTimeout,
+	}, "|")
+}
+
+// validateRedisConnections validates all instance-level redis connection definitions
+func validateRedisConnections(c *Capabilities) error {
+	names := make(map[string]bool)
+
+	for i := range c.Instance.Redis {
+		conn := &c.Instance.Redis[i]
+
+		if conn.Name == "" {
+			return ErrInvalidRedisConnection{
+				Name:   fmt.Sprintf("#%d", i+1),
+				Reason: "defined without a name",
+			}
+		}
+
+		if names[conn.Name] {
+			return ErrInvalidRedisConnection{
+				Name:   conn.Name,
+				Reason: "duplicate connection name",
+			}
+		}
+		names[conn.Name] = true
+
+		conn.Shared = true
+		if err := conn.build(); err != nil {
+			return ErrInvalidRedisConnection{
+				Name:   conn.Name,
+				Reason: err.Error(),
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveRedisConnection resolves the redis connection a proxy's cache uses,
+// either a shared instance-level connection referenced by name or one built
+// from the proxy's own inline redis settings
+func resolveRedisConnection(c *Capabilities, proxy *Proxy) error {
+	if proxy.Cache.Redis != "" {
+		for i := range c.Instance.Redis {
+			if c.Instance.Redis[i].Name == proxy.Cache.Redis {
+				proxy.Cache.RedisMode = c.Instance.Redis[i].Mode
+				proxy.Cache.RedisConnection = &c.Instance.Redis[i]
+				return nil
+			}
+		}
+
+		return ErrUnknownRedisConnection{
+			ProxyName: proxy.Name,
+			Name:      proxy.Cache.Redis,
+		}
+	}
+
+	// build a connection from inline settings, identical inline settings
+	// across proxies still share a single client
+	conn := &RedisConnection{
+		Name:             "inline",
+		URL:              proxy.Cache.RedisURL,
+		TLS:              proxy.Cache.RedisTLS,
+		Mode:             proxy.Cache.RedisMode,
+		Addrs:            proxy.Cache.RedisAddrs,
+		MasterName:       proxy.Cache.RedisMasterName,
+		Username:         proxy.Cache.RedisUsername,
+		Password:         proxy.Cache.RedisPassword,
+		SentinelPassword: proxy.Cache.RedisSentinelPassword,
+		DB:               proxy.Cache.RedisDB,
+	}
+
+	if err := conn.build(); err != nil {
+		if errURL, ok := err.(errRedisURL); ok {
+			return ErrInvalidRedisURL{
+				ProxyName: proxy.Name,
+				URL:       proxy.Cache.RedisURL,
+				Err:       errURL.err,
+			}
+		}
+
+		return ErrInvalidRedisMode{
+			ProxyName: proxy.Name,
+			Mode:      proxy.Cache.RedisMode,
+			Reason:    err.Error(),
+		}
+	}
+
+	proxy.Cache.RedisMode = conn.Mode
+	proxy.Cache.RedisConnection = conn
+	return nil
+}
@@ -0,0 +1,262 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression that can compute its next activation
+type Schedule interface {
+	// Next returns the next activation time strictly after the given time
+	Next(t time.Time) time.Time
+}
+
+// specSchedule is a standard five field cron schedule where each field is a
+// bitset of the values that field may take
+type specSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar track whether the day fields were wildcards, which
+	// changes how the two fields are combined when matching a day
+	domStar, dowStar bool
+}
+
+// everySchedule fires at a fixed interval, used for "@every <duration>"
+type everySchedule struct {
+	interval time.Duration
+}
+
+// bounds of a single cron field
+type bounds struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minutes = bounds{0, 59, nil}
+	hours   = bounds{0, 23, nil}
+	doms    = bounds{1, 31, nil}
+	months  = bounds{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dows = bounds{0, 6, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// macros are shorthand expressions for common schedules
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse a standard five field cron expression (minute, hour, day of month,
+// month, day of week), one of the @yearly/@monthly/@weekly/@daily/@hourly
+// macros or an "@every <duration>" interval expression
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+
+	if strings.HasPrefix(expr, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimSpace(expr[len("@every "):]))
+		if err != nil {
+			return nil, ErrInvalidExpression{Expression: expr, Reason: err.Error()}
+		}
+		if interval < time.Minute {
+			return nil, ErrInvalidExpression{Expression: expr, Reason: "interval must be at least 1m"}
+		}
+		return everySchedule{interval: interval}, nil
+	}
+
+	if macro, ok := macros[expr]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, ErrInvalidExpression{
+			Expression: expr,
+			Reason:     fmt.Sprintf("expected 5 fields, got %d", len(fields)),
+		}
+	}
+
+	var schedule specSchedule
+	var err error
+
+	fieldBounds := []bounds{minutes, hours, doms, months, dows}
+	fieldSets := []*uint64{&schedule.minute, &schedule.hour, &schedule.dom, &schedule.month, &schedule.dow}
+
+	for i, field := range fields {
+		*fieldSets[i], err = parseField(field, fieldBounds[i])
+		if err != nil {
+			return nil, ErrInvalidExpression{Expression: expr, Reason: err.Error()}
+		}
+	}
+
+	// allow 7 as an alias for sunday
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+
+	schedule.domStar = fields[2] == "*" || fields[2] == "?"
+	schedule.dowStar = fields[4] == "*" || fields[4] == "?"
+
+	return schedule, nil
+}
+
+// parseField parses a comma separated cron field into a bitset
+func parseField(field string, b bounds) (uint64, error) {
+	var set uint64
+
+	for _, part := range strings.Split(field, ",") {
+		bits, err := parseRange(part, b)
+		if err != nil {
+			return 0, err
+		}
+		set |= bits
+	}
+
+	return set, nil
+}
+
+// parseRange parses a single range expression such as *, */5, 1-5, 1-10/2 or 3
+func parseRange(expr string, b bounds) (uint64, error) {
+	step := 1
+	rangeExpr := expr
+
+	if i := strings.Index(expr, "/"); i >= 0 {
+		var err error
+		rangeExpr = expr[:i]
+		step, err = strconv.Atoi(expr[i+1:])
+		if err != nil || step < 1 {
+			return 0, fmt.Errorf("invalid step in '%s'", expr)
+		}
+	}
+
+	start, end := b.min, b.max
+	// day of week accepts 7 as sunday
+	if b.max == 6 {
+		end = 7
+	}
+
+	switch {
+	case rangeExpr == "*" || rangeExpr == "?":
+		if b.max == 6 {
+			end = 6
+		}
+	case strings.Contains(rangeExpr, "-"):
+		parts := strings.SplitN(rangeExpr, "-", 2)
+		var err error
+		if start, err = parseValue(parts[0], b); err != nil {
+			return 0, err
+		}
+		if end, err = parseValue(parts[1], b); err != nil {
+			return 0, err
+		}
+	default:
+		value, err := parseValue(rangeExpr, b)
+		if err != nil {
+			return 0, err
+		}
+		start = value
+		// a single value with a step runs to the end of the field
+		if step == 1 {
+			end = value
+		} else if b.max == 6 {
+			end = 6
+		}
+	}
+
+	if start > end {
+		return 0, fmt.Errorf("range start greater than end in '%s'", expr)
+	}
+
+	var bits uint64
+	for i := start; i <= end; i += step {
+		bits |= 1 << uint(i)
+	}
+
+	return bits, nil
+}
+
+// parseValue parses a single numeric or named field value within bounds
+func parseValue(value string, b bounds) (int, error) {
+	if named, ok := b.names[strings.ToLower(value)]; ok {
+		return named, nil
+	}
+
+	num, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value '%s'", value)
+	}
+
+	max := b.max
+	// day of week accepts 7 as sunday
+	if b.max == 6 {
+		max = 7
+	}
+
+	if num < b.min || num > max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", num, b.min, max)
+	}
+
+	return num, nil
+}
+
+// Next returns the next activation time strictly after t, truncated to the minute
+func (s specSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	// give up after five years, the expression can never be satisfied
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches reports whether the day of month and day of week fields match the
+// given time. If both are restricted, either one matching is sufficient.
+func (s specSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+// Next returns the next activation time strictly after t
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
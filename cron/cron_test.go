@@ -0,0 +1,59 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+var base = time.Date(2023, time.March, 14, 10, 17, 30, 0, time.UTC)
+
+// TestNext will test that parsed expressions compute the expected next activation
+func TestNext(t *testing.T) {
+	cases := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2023, time.March, 14, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2023, time.March, 14, 10, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2023, time.March, 15, 3, 0, 0, 0, time.UTC)},
+		{"30 2 1 * *", time.Date(2023, time.April, 1, 2, 30, 0, 0, time.UTC)},
+		{"0 0 * * sun", time.Date(2023, time.March, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2023, time.March, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 9-17/4 * * mon-fri", time.Date(2023, time.March, 14, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * fri", time.Date(2023, time.March, 17, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2023, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", base.Add(90 * time.Minute)},
+	}
+
+	for _, c := range cases {
+		schedule, err := Parse(c.expr)
+		if err != nil {
+			t.Fatalf("failed to parse '%s': %s", c.expr, err.Error())
+		}
+
+		if next := schedule.Next(base); !next.Equal(c.expected) {
+			t.Errorf("bad next activation for '%s', got=%s expected=%s", c.expr, next, c.expected)
+		}
+	}
+}
+
+// TestParseInvalid will test that malformed expressions are rejected
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"@every 10s",
+		"@every soon",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("expected error parsing '%s'", expr)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package cron
+
+import "fmt"
+
+// ErrInvalidExpression is an error struct for cron
+// expressions that fail to parse
+type ErrInvalidExpression struct {
+	Expression string
+	Reason     string
+}
+
+// Error returns the string representation of ErrInvalidExpression
+func (e ErrInvalidExpression) Error() string {
+	return fmt.Sprintf("cron: invalid expression '%s': %s", e.Expression, e.Reason)
+}
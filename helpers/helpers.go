@@ -1,16 +1,26 @@
 package helpers
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/dechristopher/lod/cache"
 	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/packet"
 	"github.com/dechristopher/lod/str"
 	"github.com/dechristopher/lod/tile"
+	"github.com/dechristopher/lod/util"
+)
+
+// Caching headers understood by CDNs
+const (
+	HeaderSurrogateControl = "Surrogate-Control"
+	HeaderCDNCacheControl  = "CDN-Cache-Control"
 )
 
 // BuildTileUrl will substitute URL tile params into the proxy tile URL
@@ -27,18 +37,20 @@ func BuildTileUrl(proxy config.Proxy, ctx *fiber.Ctx, tileOverride ...tile.Tile)
 		currentTile = &tileOverride[0]
 	}
 
+	return TileUrl(proxy, *currentTile, ctx.Params(str.ParamEndpoint), GetParamsFromCtx(ctx))
+}
+
+// TileUrl substitutes the given tile, dynamic endpoint and URL parameter
+// values into the proxy tile URL without needing a request context
+func TileUrl(proxy config.Proxy, t tile.Tile, endpoint string, paramsMap map[string]string) (string, error) {
 	// replace XYZ values in the tile URL
-	baseUrl := currentTile.InjectString(proxy.TileURL)
+	baseUrl := t.InjectString(proxy.TileURL)
 
 	// replace dynamic endpoint parameter in URL if configured
 	if proxy.HasEndpointParam {
-		endpoint := ctx.Params(str.ParamEndpoint)
 		baseUrl = strings.ReplaceAll(baseUrl, str.EndpointTemplate, endpoint)
 	}
 
-	// fetch params from context for possible addition to URL
-	paramsMap := GetParamsFromCtx(ctx)
-
 	// if no query parameters, return baseUrl
 	if paramsMap == nil {
 		return baseUrl, nil
@@ -77,26 +89,38 @@ func BuildCacheKey(proxy config.Proxy, ctx *fiber.Ctx, tileOverride ...tile.Tile
 		currentTile = &tileOverride[0]
 	}
 
+	return CacheKey(proxy, *currentTile, ctx.Params(str.ParamEndpoint), GetParamsFromCtx(ctx))
+}
+
+// CacheKey puts together a cache key from the configured template using the
+// given tile, dynamic endpoint and URL parameter values. Keys of versioned
+// proxies can't be built while no namespace generation is known, returning
+// cache.ErrUnknownGeneration.
+func CacheKey(proxy config.Proxy, t tile.Tile, endpoint string, paramsMap map[string]string) (string, error) {
 	// replace XYZ values in the key template
-	key := currentTile.InjectString(proxy.Cache.KeyTemplate)
+	key := t.InjectString(proxy.Cache.KeyTemplate)
 
 	// replace dynamic endpoint parameter in cache key if configured
 	if proxy.HasEndpointParam && strings.Contains(key, str.EndpointTemplate) {
-		endpoint := ctx.Params(str.ParamEndpoint)
 		key = strings.ReplaceAll(key, str.EndpointTemplate, endpoint)
 	}
 
-	// fetch params from context for possible substitution
-	paramsMap := GetParamsFromCtx(ctx)
-	if paramsMap == nil {
-		return key, nil
-	}
-
 	// replace params by name in the key template if any exist
 	for param, val := range paramsMap {
 		key = strings.ReplaceAll(key, fmt.Sprintf("{%s}", param), val)
 	}
 
+	// fold the current namespace generation into keys of versioned proxies
+	if proxy.Cache.Versioned {
+		if c := cache.Get(proxy.Name); c != nil {
+			generation, err := c.Generation(context.Background(), endpoint)
+			if err != nil {
+				return "", err
+			}
+			key = "v" + generation + ":" + key
+		}
+	}
+
 	return key, nil
 }
 
@@ -115,6 +139,27 @@ func FillParamsMap(proxy config.Proxy, ctx *fiber.Ctx) {
 	}
 }
 
+// DefaultParams returns the configured default parameter values for the
+// proxy, overridden by any of the given values
+func DefaultParams(proxy config.Proxy, overrides map[string]string) map[string]string {
+	paramsMap := make(map[string]string)
+	for _, param := range proxy.Params {
+		val := param.Default
+		if override, ok := overrides[param.Name]; ok {
+			val = override
+		}
+		if val != "" {
+			paramsMap[param.Name] = val
+		}
+	}
+
+	if len(paramsMap) == 0 {
+		return nil
+	}
+
+	return paramsMap
+}
+
 // GetParamsFromCtx will attempt to fetch the params map from the request
 // context locals if any parameters are present and valid
 func GetParamsFromCtx(ctx *fiber.Ctx) map[string]string {
@@ -124,6 +169,25 @@ func GetParamsFromCtx(ctx *fiber.Ctx) map[string]string {
 	return nil
 }
 
+// SetClientHeaders sets the caching headers configured for clients and CDNs
+// on the response, using the policy of the requested tile's zoom level
+func SetClientHeaders(ctx *fiber.Ctx, proxy config.Proxy) {
+	zoom, _ := ctx.ParamsInt(str.ParamZ)
+	policy := proxy.ClientCache.Policy(zoom)
+
+	if cacheControl := policy.CacheControl(); cacheControl != "" {
+		ctx.Set(fiber.HeaderCacheControl, cacheControl)
+	}
+
+	if policy.SurrogateControl != "" {
+		ctx.Set(HeaderSurrogateControl, policy.SurrogateControl)
+	}
+
+	if policy.CDNCacheControl != "" {
+		ctx.Set(HeaderCDNCacheControl, policy.CDNCacheControl)
+	}
+}
+
 // ProxyResponse is a container struct encapsulating data retrieved from the
 // upstream tile server during an agent-proxied request
 type ProxyResponse struct {
@@ -159,14 +223,15 @@ func FetchUpstream(tileUrl string, p config.Proxy) func() (interface{}, error) {
 		resp := fiber.AcquireResponse()
 		agent.SetResponse(resp)
 
-		// copy agent response, so we can transport its contents elsewhere while
-		// returning the agent and its request pool to the fiber memory pool
-		returnResponse := fiber.Response{}
-		resp.CopyTo(&returnResponse)
-
 		// make agent-proxied request
 		code, body, errs := agent.Bytes()
 
+		// copy agent response once the request filled it, so we can transport
+		// its contents elsewhere while returning the agent and its request
+		// pool to the fiber memory pool
+		returnResponse := fiber.Response{}
+		resp.CopyTo(&returnResponse)
+
 		// immediately release response instance back to memory pool
 		fiber.ReleaseResponse(resp)
 
@@ -190,8 +255,11 @@ type ProcessResponsePayload struct {
 	Cache     *cache.Cache
 	Proxy     config.Proxy
 	CacheKey  string
+	TTLs      cache.TTLs
 	Response  ProxyResponse
 	WriteData bool
+	NoStore   bool // write the tile without caching it
+	Wait      bool // cache the tile in every level before returning
 }
 
 // ProcessResponse will cache fetched tile data, wrangle headers, and return the
@@ -206,9 +274,19 @@ func ProcessResponse(payload ProcessResponsePayload) error {
 		tileData := make([]byte, len(payload.Response.Body))
 		copy(tileData, payload.Response.Body)
 
-		headers := map[string]string{}
-		// Store configured headers into the tile cache for this tile
-		payload.Proxy.DoPullHeaders(payload.Response.Resp, headers)
+		// record when and how the tile was fetched so its age can be reported
+		// on hits and it can be revalidated against upstream later
+		now := time.Now()
+		meta := packet.Meta{
+			Fetched:      now,
+			Status:       payload.Response.Code,
+			ETag:         string(payload.Response.Resp.Header.Peek(fiber.HeaderETag)),
+			LastModified: string(payload.Response.Resp.Header.Peek(fiber.HeaderLastModified)),
+		}
+		headers := make(map[string]string)
+		// Store configured headers into the tile cache for this tile, along
+		// with the tags to index the tile by for tag-based invalidation
+		tags := payload.Proxy.DoPullHeaders(payload.Response.Resp, headers)
 
 		// write data to parent fiber request context if write mode is specified
 		if payload.WriteData {
@@ -217,6 +295,9 @@ func ProcessResponse(payload ProcessResponsePayload) error {
 			// internals of the tileserver if you don't control what it returns
 			payload.Proxy.DoDeleteHeaders(payload.Ctx)
 
+			// set configured caching headers for clients and CDNs
+			SetClientHeaders(payload.Ctx, payload.Proxy)
+
 			// set 204 Status No Content if upstream tileserver returned no/empty tile
 			if payload.Response.Code == fiber.StatusNoContent {
 				payload.Ctx.Status(fiber.StatusNoContent)
@@ -229,8 +310,32 @@ func ProcessResponse(payload ProcessResponsePayload) error {
 			}
 		}
 
+		if payload.NoStore {
+			return nil
+		}
+
+		// derive the tile's TTL from upstream caching headers if configured,
+		// recording its expiry in the tile and capping every level's TTL at it
+		ttls := payload.TTLs
+		if payload.Proxy.Cache.HonorUpstream {
+			ttl, cacheable := UpstreamTTL(payload.Response.Resp, payload.Proxy.Cache, now)
+			if !cacheable {
+				util.DebugFlag("cache", str.CCache, str.DCacheUncacheable, payload.CacheKey)
+				return nil
+			}
+
+			if ttl > 0 {
+				meta.Expires = now.Add(ttl)
+				ttls = payload.Cache.CapTTLs(ttls, ttl)
+			}
+		}
+
+		if payload.Wait {
+			return payload.Cache.EncodeSetWait(payload.CacheKey, tileData, headers, meta, ttls, tags)
+		}
+
 		// spin off a routine to cache the tile without blocking the response
-		go payload.Cache.EncodeSet(payload.CacheKey, tileData, headers)
+		go payload.Cache.EncodeSet(payload.CacheKey, tileData, headers, meta, ttls, tags)
 	} else {
 		return ErrInvalidStatusCode{
 			StatusCode: payload.Response.Code,
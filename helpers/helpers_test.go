@@ -0,0 +1,156 @@
+package helpers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/packet"
+)
+
+// newTestProxy returns a proxy caching tiles in memory only
+func newTestProxy() config.Proxy {
+	return config.Proxy{
+		Name:        "test",
+		PullHeaders: []string{fiber.HeaderContentType},
+		TagHeader:   "Surrogate-Key",
+		Cache: config.Cache{
+			Layers:         []string{config.LayerMemory},
+			MemEnabled:     true,
+			MemCap:         1,
+			MemTTLDuration: time.Hour,
+		},
+	}
+}
+
+// fetchAndProcess fetches a tile from an upstream serving the given headers
+// through FetchUpstream and ProcessResponse, returning the proxy's cache
+func fetchAndProcess(t *testing.T, proxy config.Proxy, headers map[string]string) *cache.Cache {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for key, val := range headers {
+			w.Header().Set(key, val)
+		}
+		_, _ = w.Write([]byte("tile"))
+	}))
+	defer upstream.Close()
+
+	c, err := cache.New(proxy)
+	if err != nil {
+		t.Fatalf("failed to build cache: %s", err)
+	}
+
+	// process the fetched tile within a request routed like proxied tiles
+	app := fiber.New()
+	app.Get("/:z/:x/:y", func(ctx *fiber.Ctx) error {
+		response, errFetch := FetchUpstream(upstream.URL+ctx.Path(), proxy)()
+		if errFetch != nil {
+			return errFetch
+		}
+
+		return ProcessResponse(ProcessResponsePayload{
+			Ctx:       ctx,
+			Cache:     c,
+			Proxy:     proxy,
+			CacheKey:  "0/0/0",
+			Response:  response.(ProxyResponse),
+			WriteData: true,
+		})
+	})
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/0/0/0", nil))
+	if err != nil {
+		t.Fatalf("failed to request tile: %s", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+
+	return c
+}
+
+// waitCached returns the tile once it has been cached in the background
+func waitCached(t *testing.T, c *cache.Cache) *packet.Decoded {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if tile := c.Peek("0/0/0", context.Background()); tile != nil {
+			return tile
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatal("tile was not cached")
+	return nil
+}
+
+// TestFetchUpstreamHeaders will test that upstream response headers survive
+// FetchUpstream, so pulled headers are stored and tags are indexed
+func TestFetchUpstreamHeaders(t *testing.T) {
+	c := fetchAndProcess(t, newTestProxy(), map[string]string{
+		fiber.HeaderContentType: "application/x-protobuf",
+		"Surrogate-Key":         "roads water",
+	})
+	tile := waitCached(t, c)
+
+	if contentType := tile.Headers()[fiber.HeaderContentType]; contentType != "application/x-protobuf" {
+		t.Errorf("expected pulled content type header, got %q", contentType)
+	}
+
+	if n, err := c.InvalidateTagInternal("water"); err != nil || n != 1 {
+		t.Fatalf("expected one tile invalidated by tag, got %d: %v", n, err)
+	}
+
+	if c.Peek("0/0/0", context.Background()) != nil {
+		t.Error("expected tile to be invalidated by tag")
+	}
+}
+
+// TestHonorUpstream will test that tiles fetched from a real upstream are
+// cached according to its caching headers when configured to honor them
+func TestHonorUpstream(t *testing.T) {
+	proxy := newTestProxy()
+	proxy.Cache.HonorUpstream = true
+
+	before := time.Now()
+	tile := waitCached(t, fetchAndProcess(t, proxy, map[string]string{
+		fiber.HeaderCacheControl: "max-age=600",
+		fiber.HeaderAge:          "120",
+	}))
+
+	expires, ok := tile.Expires()
+	if !ok {
+		t.Fatal("expected tile to record its upstream expiry")
+	}
+	if lifetime := expires.Sub(before); lifetime < 8*time.Minute-time.Second || lifetime > 8*time.Minute+time.Second {
+		t.Errorf("expected tile to expire in 8m, got %s", lifetime)
+	}
+
+	c := fetchAndProcess(t, proxy, map[string]string{
+		fiber.HeaderCacheControl: "no-store",
+	})
+	if c.Peek("0/0/0", context.Background()) != nil {
+		t.Error("expected uncacheable tile not to be cached")
+	}
+}
+
+// TestFetchedMeta will test that fetched tiles keep the upstream validators
+// in their metadata
+func TestFetchedMeta(t *testing.T) {
+	tile := waitCached(t, fetchAndProcess(t, newTestProxy(), map[string]string{
+		fiber.HeaderETag:         `"abc123"`,
+		fiber.HeaderLastModified: "Wed, 01 Jun 2022 12:00:00 GMT",
+	}))
+
+	meta := tile.Meta()
+	if meta.ETag != `"abc123"` {
+		t.Errorf("expected upstream ETag, got %q", meta.ETag)
+	}
+	if meta.LastModified != "Wed, 01 Jun 2022 12:00:00 GMT" {
+		t.Errorf("expected upstream Last-Modified, got %q", meta.LastModified)
+	}
+}
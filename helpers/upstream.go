@@ -0,0 +1,96 @@
+package helpers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/dechristopher/lod/config"
+)
+
+// UpstreamTTL derives the TTL of a tile from the Cache-Control, Expires and
+// Age headers of the upstream response, clamped to the configured bounds.
+// It returns false if the tile must not be cached, and a TTL of zero if the
+// response carries no freshness information, in which case the configured
+// TTLs apply.
+func UpstreamTTL(resp *fiber.Response, cache config.Cache, now time.Time) (time.Duration, bool) {
+	lifetime, known, store := freshness(resp, now)
+	if !store {
+		return 0, false
+	}
+
+	if !known {
+		return 0, true
+	}
+
+	if lifetime < cache.UpstreamMinTTLDuration {
+		lifetime = cache.UpstreamMinTTLDuration
+	}
+
+	if cache.UpstreamMaxTTLDuration > 0 && lifetime > cache.UpstreamMaxTTLDuration {
+		lifetime = cache.UpstreamMaxTTLDuration
+	}
+
+	return lifetime, lifetime > 0
+}
+
+// freshness returns the remaining freshness lifetime of the upstream
+// response, whether the response specified one, and whether it may be stored
+func freshness(resp *fiber.Response, now time.Time) (time.Duration, bool, bool) {
+	var maxAge, sharedMaxAge time.Duration
+	var hasMaxAge, hasSharedMaxAge, noCache bool
+
+	for _, directive := range strings.Split(string(resp.Header.Peek(fiber.HeaderCacheControl)), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		value = strings.Trim(value, `"`)
+
+		switch strings.ToLower(name) {
+		case "no-store", "private":
+			return 0, false, false
+		case "no-cache":
+			noCache = true
+		case "max-age":
+			maxAge, hasMaxAge = parseSeconds(value)
+		case "s-maxage":
+			sharedMaxAge, hasSharedMaxAge = parseSeconds(value)
+		}
+	}
+
+	// age the response already accrued in upstream caches
+	age, _ := parseSeconds(string(resp.Header.Peek(fiber.HeaderAge)))
+
+	switch {
+	case noCache:
+		return 0, true, true
+	case hasSharedMaxAge:
+		return sharedMaxAge - age, true, true
+	case hasMaxAge:
+		return maxAge - age, true, true
+	}
+
+	expires := resp.Header.Peek(fiber.HeaderExpires)
+	if len(expires) == 0 {
+		return 0, false, true
+	}
+
+	// invalid dates, ex: 0, mean the response has already expired
+	expiresAt, err := http.ParseTime(string(expires))
+	if err != nil {
+		return 0, true, true
+	}
+
+	// expiry is absolute, so the accrued age doesn't apply
+	return expiresAt.Sub(now), true, true
+}
+
+// parseSeconds parses a non-negative number of seconds from a header value
+func parseSeconds(value string) (time.Duration, bool) {
+	seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/dechristopher/lod/config"
+)
+
+// TestUpstreamTTL will test deriving tile TTLs from upstream caching headers
+func TestUpstreamTTL(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	bounds := config.Cache{
+		UpstreamMinTTLDuration: time.Minute,
+		UpstreamMaxTTLDuration: time.Hour,
+	}
+
+	tests := []struct {
+		name      string
+		headers   map[string]string
+		ttl       time.Duration
+		cacheable bool
+	}{
+		{"none", nil, 0, true},
+		{"no-store", map[string]string{"Cache-Control": "public, no-store"}, 0, false},
+		{"private", map[string]string{"Cache-Control": "private, max-age=600"}, 0, false},
+		{"max-age", map[string]string{"Cache-Control": "max-age=600"}, 10 * time.Minute, true},
+		{"s-maxage", map[string]string{"Cache-Control": "max-age=600, s-maxage=1200"}, 20 * time.Minute, true},
+		{"age", map[string]string{"Cache-Control": "max-age=600", "Age": "300"}, 5 * time.Minute, true},
+		{"min", map[string]string{"Cache-Control": "no-cache"}, time.Minute, true},
+		{"max", map[string]string{"Cache-Control": "max-age=86400"}, time.Hour, true},
+		{"expires", map[string]string{"Expires": "Wed, 01 Jun 2022 12:30:00 GMT"}, 30 * time.Minute, true},
+		{"expired", map[string]string{"Expires": "0"}, time.Minute, true},
+	}
+
+	for _, test := range tests {
+		resp := fiber.AcquireResponse()
+		for key, val := range test.headers {
+			resp.Header.Set(key, val)
+		}
+
+		ttl, cacheable := UpstreamTTL(resp, bounds, now)
+		if ttl != test.ttl || cacheable != test.cacheable {
+			t.Errorf("%s: expected ttl=%s cacheable=%t, got ttl=%s cacheable=%t",
+				test.name, test.ttl, test.cacheable, ttl, cacheable)
+		}
+
+		fiber.ReleaseResponse(resp)
+	}
+}
@@ -0,0 +1,184 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/helpers"
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/tile"
+	"github.com/dechristopher/lod/util"
+)
+
+// Job describes a batch of tiles to invalidate, prime or refresh for a proxy
+type Job struct {
+	Cache    *cache.Cache      // cache instance of the proxy to operate on
+	Tiles    []tile.Tile       // tiles to operate on
+	Mode     string            // one of the config.ScheduleMode* values
+	Endpoint string            // dynamic endpoint value used to build URLs and keys
+	Params   map[string]string // URL parameter values used to build URLs and keys
+}
+
+// Result of a completed Job
+type Result struct {
+	Attempted int `json:"attempted"` // number of tiles the job operated on
+	Succeeded int `json:"succeeded"` // number of tiles successfully processed
+	Changed   int `json:"changed"`   // number of tiles written to the cache
+}
+
+// Run the given job to completion, returning counts of processed tiles
+func Run(ctx context.Context, job Job) Result {
+	result := Result{
+		Attempted: len(job.Tiles),
+	}
+
+	if job.Mode == config.ScheduleModeInvalidate {
+		// simply invalidate en masse
+		for _, tileToInvalidate := range job.Tiles {
+			if ctx.Err() != nil {
+				break
+			}
+
+			key, errKey := helpers.CacheKey(*job.Cache.Proxy(), tileToInvalidate, job.Endpoint, job.Params)
+			if errKey != nil {
+				util.Debug(str.CJobs, str.DInvalidateFail, tileToInvalidate.String(), errKey)
+				continue
+			}
+			if errInv := job.Cache.Invalidate(key, ctx); errInv != nil {
+				util.Debug(str.CJobs, str.DInvalidateFail, tileToInvalidate.String(), errInv)
+				continue
+			}
+			result.Succeeded++
+			result.Changed++
+		}
+
+		return result
+	}
+
+	// fetch and prime in place for the given tile to avoid invalidating tiles
+	// en masse and having missing tiles in the cache during the priming period
+	wg := &sync.WaitGroup{}
+	wg.Add(job.Cache.Proxy().NumWorkers)
+
+	jobs := make(chan tile.Tile, len(job.Tiles))
+	outcomes := make(chan bool, len(job.Tiles))
+
+	// spin up workers to make agent-proxied requests to the upstream
+	for numWorkers := 0; numWorkers < job.Cache.Proxy().NumWorkers; numWorkers++ {
+		go tileWorker(tileWorkerPayload{
+			ctx:       ctx,
+			job:       job,
+			jobs:      jobs,
+			outcomes:  outcomes,
+			waitGroup: wg,
+		})
+	}
+
+	// submit jobs to workers
+	for _, tileJob := range job.Tiles {
+		jobs <- tileJob
+	}
+
+	// signal that we're out of tiles to prime
+	close(jobs)
+
+	// wait until workers finish
+	wg.Wait()
+
+	// close outcomes channel after workers finish
+	close(outcomes)
+
+	// count successfully primed and changed tiles
+	for changed := range outcomes {
+		result.Succeeded++
+		if changed {
+			result.Changed++
+		}
+	}
+
+	return result
+}
+
+// tileWorkerPayload is a struct containing all the ingredients
+// needed for a tileWorker to operate on its job queue
+type tileWorkerPayload struct {
+	ctx       context.Context
+	job       Job
+	jobs      <-chan tile.Tile
+	outcomes  chan<- bool
+	waitGroup *sync.WaitGroup
+}
+
+// tileWorker is a worker function that's spun up during requests to prime and
+// refresh batches of tiles. Each successfully processed tile emits an outcome
+// reporting whether the tile was written to the cache.
+func tileWorker(payload tileWorkerPayload) {
+	defer payload.waitGroup.Done()
+
+	proxy := *payload.job.Cache.Proxy()
+
+	for tileJob := range payload.jobs {
+		// drain remaining jobs without processing them if cancelled
+		if payload.ctx.Err() != nil {
+			continue
+		}
+
+		url, err := helpers.TileUrl(proxy, tileJob, payload.job.Endpoint, payload.job.Params)
+		if err != nil {
+			util.Debug(str.CJobs, str.DPrimeFail, tileJob.String(), err.Error())
+			continue
+		}
+
+		cacheKey, err := helpers.CacheKey(proxy, tileJob, payload.job.Endpoint, payload.job.Params)
+		if err != nil {
+			util.Debug(str.CJobs, str.DPrimeFail, tileJob.String(), err.Error())
+			continue
+		}
+
+		response, errProxy := helpers.FetchUpstream(url, proxy)()
+		if errProxy != nil {
+			util.Debug(str.CJobs, str.DPrimeFail, tileJob.String(), errProxy.Error())
+			continue
+		}
+
+		// cast interface returned from the fetch to a proxyResponse
+		proxyResp, ok := response.(helpers.ProxyResponse)
+
+		// sanity check to ensure cast worked properly
+		if !ok {
+			util.Debug(str.CJobs, str.DPrimeFail, tileJob.String(), "bad upstream response")
+			continue
+		}
+
+		// skip re-caching tiles whose data has not changed when refreshing
+		if payload.job.Mode == config.ScheduleModeRefresh {
+			if cached := payload.job.Cache.Peek(cacheKey, payload.ctx); cached != nil && proxyResp.Code == 200 {
+				data, errData := cached.Decompress()
+				if errData == nil && bytes.Equal(data, proxyResp.Body) {
+					payload.outcomes <- false
+					continue
+				}
+			}
+		}
+
+		// cache tile data and headers, waiting on shared levels so that peers
+		// told about the job afterwards can read the tile from them
+		if err = helpers.ProcessResponse(helpers.ProcessResponsePayload{
+			Cache:    payload.job.Cache,
+			Proxy:    proxy,
+			CacheKey: cacheKey,
+			TTLs:     payload.job.Cache.TTLs(tileJob.Zoom, payload.job.Endpoint),
+			Response: proxyResp,
+			Wait:     true,
+		}); err != nil {
+			util.DebugFlag("primer", str.CJobs, str.DPrimeFail, tileJob.String(), err.Error())
+			continue
+		}
+
+		// signal successful tile
+		payload.outcomes <- true
+	}
+}
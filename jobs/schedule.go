@@ -0,0 +1,288 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twpayne/go-geos"
+
+	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/helpers"
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/tile"
+	"github.com/dechristopher/lod/util"
+)
+
+// Status of a configured schedule, reported by the admin API
+type Status struct {
+	Proxy        string     `json:"proxy"`                   // name of the proxy the schedule belongs to
+	Name         string     `json:"name"`                    // name of the schedule
+	Cron         string     `json:"cron"`                    // configured cron expression
+	Mode         string     `json:"mode"`                    // configured schedule mode
+	Running      bool       `json:"running"`                 // whether the schedule is currently running
+	NextRun      time.Time  `json:"next_run"`                // time of the next scheduled run
+	LastRun      *time.Time `json:"last_run,omitempty"`      // start time of the last run
+	LastDuration float64    `json:"last_duration,omitempty"` // duration of the last run in seconds
+	LastResult   *Result    `json:"last_result,omitempty"`   // tile counts of the last run
+	LastError    string     `json:"last_error,omitempty"`    // error encountered during the last run, if any
+}
+
+var (
+	// schedulerLock guards the scheduler state below
+	schedulerLock sync.Mutex
+	// cancelScheduler stops all running schedule loops
+	cancelScheduler context.CancelFunc
+	// statuses of all configured schedules keyed by proxy and schedule name
+	statuses = make(map[string]*Status)
+)
+
+// StartScheduler (re)starts a schedule loop for every schedule in the current
+// configuration, stopping any previously running loops. Status of schedules
+// that persist across a reload is kept.
+func StartScheduler() {
+	schedulerLock.Lock()
+	defer schedulerLock.Unlock()
+
+	if cancelScheduler != nil {
+		cancelScheduler()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelScheduler = cancel
+
+	newStatuses := make(map[string]*Status)
+
+	for _, proxy := range config.Get().Proxies {
+		for _, schedule := range proxy.Schedules {
+			id := statusKey(proxy.Name, schedule.Name)
+
+			status, ok := statuses[id]
+			if !ok {
+				status = &Status{
+					Proxy: proxy.Name,
+					Name:  schedule.Name,
+				}
+			}
+			status.Cron = schedule.Cron
+			status.Mode = schedule.Mode
+			newStatuses[id] = status
+
+			go scheduleLoop(ctx, proxy.Name, schedule, status)
+		}
+	}
+
+	statuses = newStatuses
+}
+
+// StopScheduler stops all running schedule loops
+func StopScheduler() {
+	schedulerLock.Lock()
+	defer schedulerLock.Unlock()
+
+	if cancelScheduler != nil {
+		cancelScheduler()
+		cancelScheduler = nil
+	}
+}
+
+// Statuses returns a snapshot of the status of every schedule configured
+// for the named proxy, or of all schedules if no name is given
+func Statuses(proxyName string) []Status {
+	schedulerLock.Lock()
+	defer schedulerLock.Unlock()
+
+	list := make([]Status, 0)
+
+	for _, proxy := range config.Get().Proxies {
+		if proxyName != "" && proxy.Name != proxyName {
+			continue
+		}
+
+		for _, schedule := range proxy.Schedules {
+			if status, ok := statuses[statusKey(proxy.Name, schedule.Name)]; ok {
+				list = append(list, *status)
+			}
+		}
+	}
+
+	return list
+}
+
+// statusKey builds the key a schedule's status is stored at
+func statusKey(proxyName, scheduleName string) string {
+	return proxyName + "/" + scheduleName
+}
+
+// scheduleLoop waits for each activation of the given schedule and runs it
+// until the scheduler context is cancelled
+func scheduleLoop(ctx context.Context, proxyName string, schedule config.Schedule, status *Status) {
+	for {
+		next := schedule.Spec.Next(time.Now())
+		if next.IsZero() {
+			util.Error(str.CJobs, str.EScheduleNever, proxyName, schedule.Name, schedule.Cron)
+			return
+		}
+
+		schedulerLock.Lock()
+		status.NextRun = next
+		schedulerLock.Unlock()
+
+		util.DebugFlag("schedule", str.CJobs, str.DScheduleNext, proxyName, schedule.Name, next)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if lockRun(ctx, proxyName, schedule, next) {
+			runSchedule(ctx, proxyName, schedule, status)
+		}
+	}
+}
+
+// lockRun returns true if this instance should perform the schedule's run
+// at the given activation time. Instances sharing cache levels take a lock
+// per activation so that only one of them fetches the scheduled tiles.
+func lockRun(ctx context.Context, proxyName string, schedule config.Schedule, activation time.Time) bool {
+	c := cache.Get(proxyName)
+	if c == nil || !c.Shared() {
+		return true
+	}
+
+	// hold the lock until the next activation, which is locked separately
+	ttl := schedule.Spec.Next(activation).Sub(activation)
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+
+	name := "schedule:" + schedule.Name + ":" + strconv.FormatInt(activation.Unix(), 10)
+	acquired, err := c.Lock(ctx, name, ttl)
+	if err != nil {
+		util.Error(str.CJobs, str.EScheduleLock, proxyName, schedule.Name, err.Error())
+		return true
+	}
+
+	if !acquired {
+		util.DebugFlag("schedule", str.CJobs, str.DScheduleLocked, proxyName, schedule.Name, activation)
+	}
+	return acquired
+}
+
+// runSchedule performs a single run of the given schedule, recording the
+// outcome in the schedule's status
+func runSchedule(ctx context.Context, proxyName string, schedule config.Schedule, status *Status) {
+	start := time.Now()
+
+	schedulerLock.Lock()
+	status.Running = true
+	status.LastRun = &start
+	schedulerLock.Unlock()
+
+	result, err := func() (*Result, error) {
+		// look up the cache at run time since reloads replace cache instances
+		c := cache.Get(proxyName)
+		if c == nil {
+			return nil, fmt.Errorf("no cache configured for proxy")
+		}
+
+		tiles, err := Targets(schedule)
+		if err != nil {
+			return nil, err
+		}
+
+		result := Run(ctx, Job{
+			Cache:    c,
+			Tiles:    tiles,
+			Mode:     schedule.Mode,
+			Endpoint: schedule.Endpoint,
+			Params:   helpers.DefaultParams(*c.Proxy(), schedule.Params),
+		})
+
+		// runs stopped by a reload or shutdown report the tiles done so far
+		if ctx.Err() != nil {
+			return &result, fmt.Errorf("run cancelled after %d of %d tiles", result.Succeeded, result.Attempted)
+		}
+
+		return &result, nil
+	}()
+
+	duration := time.Since(start)
+
+	schedulerLock.Lock()
+	status.Running = false
+	status.LastDuration = duration.Seconds()
+	status.LastError = ""
+	status.LastResult = result
+	if err != nil {
+		status.LastError = err.Error()
+	}
+	schedulerLock.Unlock()
+
+	if err != nil {
+		util.Error(str.CJobs, str.EScheduleRun, proxyName, schedule.Name, err.Error())
+		return
+	}
+
+	util.Info(str.CJobs, str.MScheduleRun, proxyName, schedule.Name, duration,
+		result.Attempted, result.Succeeded, result.Changed)
+}
+
+// Targets computes every tile targeted by the given schedule within its
+// configured zoom range
+func Targets(schedule config.Schedule) ([]tile.Tile, error) {
+	switch {
+	case schedule.Tile != "":
+		var root tile.Tile
+		if _, err := fmt.Sscanf(schedule.Tile, "%d/%d/%d", &root.Zoom, &root.X, &root.Y); err != nil {
+			return nil, err
+		}
+		return withinZooms(root.DeepChildren(schedule.MaxZoom), schedule), nil
+	case schedule.BBox != nil:
+		return tile.InBounds(schedule.BBox[0], schedule.BBox[1], schedule.BBox[2], schedule.BBox[3],
+			schedule.MinZoom, schedule.MaxZoom), nil
+	case schedule.GeometryFile != "":
+		geometry, err := readGeometry(schedule.GeometryFile)
+		if err != nil {
+			return nil, err
+		}
+		defer geometry.Destroy()
+		return withinZooms(tile.Intersecting(geometry, schedule.MaxZoom), schedule), nil
+	}
+
+	return nil, fmt.Errorf("no target configured")
+}
+
+// withinZooms filters the given tiles down to the schedule's zoom range
+func withinZooms(tiles []tile.Tile, schedule config.Schedule) []tile.Tile {
+	filtered := make([]tile.Tile, 0, len(tiles))
+	for _, t := range tiles {
+		if t.Zoom >= schedule.MinZoom && t.Zoom <= schedule.MaxZoom {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered
+}
+
+// readGeometry reads a GeoJSON or WKT (.wkt extension) geometry from disk
+func readGeometry(path string) (*geos.Geom, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".wkt") {
+		return geos.NewGeomFromWKT(string(data))
+	}
+
+	return geos.NewGeomFromGeoJSON(string(data))
+}
@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/config"
+)
+
+// TestCancelledRun will test that runs cancelled by a reload are recorded
+// as failed along with the tiles done before they were cancelled
+func TestCancelledRun(t *testing.T) {
+	c, err := cache.New(config.Proxy{Name: "test", Cache: config.Cache{
+		Layers:         []string{config.LayerMemory},
+		MemEnabled:     true,
+		MemCap:         64,
+		MemTTLDuration: time.Hour,
+	}})
+	if err != nil {
+		t.Fatalf("failed to build cache: %s", err)
+	}
+	cache.Caches["test"] = c
+	defer delete(cache.Caches, "test")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	status := &Status{}
+	runSchedule(ctx, "test", config.Schedule{
+		Name: "nightly",
+		Mode: config.ScheduleModeInvalidate,
+		Tile: "0/0/0",
+	}, status)
+
+	if status.LastError == "" {
+		t.Error("expected cancelled run to record an error")
+	}
+	if status.LastResult == nil || status.LastResult.Attempted != 1 || status.LastResult.Succeeded != 0 {
+		t.Errorf("expected cancelled run to record its partial result, got %+v", status.LastResult)
+	}
+}
@@ -0,0 +1,223 @@
+package jobs
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/helpers"
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/tile"
+	"github.com/dechristopher/lod/util"
+)
+
+// warmed is set once the startup warmup of every proxy has completed
+var warmed atomic.Bool
+
+// Ready returns true once the startup warmup has completed
+func Ready() bool {
+	return warmed.Load()
+}
+
+// Warmup loads the configured tiles of every proxy into its local cache
+// levels, blocking until every proxy is warm or its warmup timed out
+func Warmup() {
+	start := time.Now()
+	wg := &sync.WaitGroup{}
+
+	for _, proxy := range config.Get().Proxies {
+		if !proxy.Warmup.Enabled() {
+			continue
+		}
+
+		c := cache.Get(proxy.Name)
+		if c == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(c *cache.Cache) {
+			defer wg.Done()
+			warmCache(c)
+		}(c)
+	}
+
+	wg.Wait()
+	warmed.Store(true)
+
+	util.Info(str.CJobs, str.MWarmupDone, time.Since(start))
+}
+
+// warmCache warms a single proxy's cache from its popular keys and
+// configured tiles, fetching missing tiles from the upstream if configured
+func warmCache(c *cache.Cache) {
+	start := time.Now()
+	proxy := *c.Proxy()
+	warmup := proxy.Warmup
+
+	ctx, cancel := context.WithTimeout(context.Background(), warmup.TimeoutDuration)
+	defer cancel()
+
+	var hot []string
+	if warmup.HotKeys > 0 {
+		var err error
+		if hot, err = c.HotKeys(ctx, warmup.HotKeys); err != nil {
+			util.Error(str.CJobs, str.EWarmup, proxy.Name, err.Error())
+		}
+	}
+
+	tiles, err := WarmupTargets(warmup)
+	if err != nil {
+		util.Error(str.CJobs, str.EWarmup, proxy.Name, err.Error())
+	}
+
+	params := helpers.DefaultParams(proxy, warmup.Params)
+	// keys share one generation, so if one can't be built none can and the
+	// listed tiles are left to be cached on request
+	keys := make([]string, 0, len(tiles))
+	keyed := make([]tile.Tile, 0, len(tiles))
+	ttls := make([]cache.TTLs, 0, len(tiles))
+	for _, t := range tiles {
+		key, errKey := helpers.CacheKey(proxy, t, warmup.Endpoint, params)
+		if errKey != nil {
+			util.Error(str.CJobs, str.EWarmup, proxy.Name, errKey.Error())
+			break
+		}
+		keys = append(keys, key)
+		keyed = append(keyed, t)
+		ttls = append(ttls, c.TTLs(t.Zoom, warmup.Endpoint))
+	}
+
+	// popular keys can't be mapped back to tiles and their TTL rules, so
+	// they're warmed with the TTL of each level
+	missingHot := warmKeys(ctx, c, hot, nil)
+	missing := warmKeys(ctx, c, keys, ttls)
+
+	// only listed tiles can be fetched since popular keys can't be mapped back to tiles
+	var fetch []tile.Tile
+	if warmup.Upstream {
+		for _, i := range missing {
+			fetch = append(fetch, keyed[i])
+		}
+	}
+
+	result := Result{}
+	if len(fetch) > 0 {
+		result = Run(ctx, Job{
+			Cache:    c,
+			Tiles:    fetch,
+			Mode:     config.ScheduleModePrime,
+			Endpoint: warmup.Endpoint,
+			Params:   params,
+		})
+	}
+
+	attempted := len(hot) + len(keys)
+	util.Info(str.CJobs, str.MWarmup, proxy.Name, time.Since(start),
+		attempted, attempted-len(missingHot)-len(missing), result.Changed)
+}
+
+// warmKeys warms the given keys with the proxy's configured number of
+// workers, returning the indices of keys not held by any cache level. Keys
+// are warmed with the TTLs at the same index, or the TTL of each level if
+// none are given.
+func warmKeys(ctx context.Context, c *cache.Cache, keys []string, ttls []cache.TTLs) []int {
+	jobs := make(chan int, len(keys))
+	for i := range keys {
+		jobs <- i
+	}
+	close(jobs)
+
+	lock := sync.Mutex{}
+	missing := make([]int, 0)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(c.Proxy().NumWorkers)
+
+	for numWorkers := 0; numWorkers < c.Proxy().NumWorkers; numWorkers++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				// drain remaining jobs without warming them if timed out,
+				// reporting them missing
+				found := false
+				if ctx.Err() == nil {
+					var keyTTLs cache.TTLs
+					if ttls != nil {
+						keyTTLs = ttls[i]
+					}
+
+					var err error
+					if found, err = c.Warm(ctx, keys[i], keyTTLs); err != nil {
+						util.DebugFlag("warmup", str.CJobs, str.DWarmupFail, keys[i], err.Error())
+					}
+				}
+
+				if !found {
+					lock.Lock()
+					missing = append(missing, i)
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	return missing
+}
+
+// WarmupTargets computes every tile listed in the warmup's tile file and
+// within its bounding box
+func WarmupTargets(warmup config.Warmup) ([]tile.Tile, error) {
+	tiles := make([]tile.Tile, 0)
+
+	if warmup.TileFile != "" {
+		listed, err := readTileFile(warmup.TileFile)
+		if err != nil {
+			return nil, err
+		}
+		tiles = append(tiles, listed...)
+	}
+
+	if warmup.BBox != nil {
+		tiles = append(tiles, tile.InBounds(warmup.BBox[0], warmup.BBox[1], warmup.BBox[2], warmup.BBox[3],
+			warmup.MinZoom, warmup.MaxZoom)...)
+	}
+
+	return tiles, nil
+}
+
+// readTileFile reads tiles formatted as z/x/y from a file, one per line,
+// skipping blank lines and lines starting with #
+func readTileFile(path string) ([]tile.Tile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	tiles := make([]tile.Tile, 0)
+	scanner := bufio.NewScanner(file)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var t tile.Tile
+		if _, err = fmt.Sscanf(text, "%d/%d/%d", &t.Zoom, &t.X, &t.Y); err != nil {
+			return nil, fmt.Errorf("%s:%d: tile '%s' must be formatted as z/x/y", path, line, text)
+		}
+		tiles = append(tiles, t)
+	}
+
+	return tiles, scanner.Err()
+}
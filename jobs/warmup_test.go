@@ -0,0 +1,178 @@
+package jobs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/packet"
+	"github.com/dechristopher/lod/tile"
+)
+
+// writeTileFile writes a tile file with the given contents, returning its path
+func writeTileFile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "tiles.txt")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("failed to write tile file: %s", err)
+	}
+	return path
+}
+
+// newWarmupCache builds an in-memory cache for a proxy fetching tiles from
+// the given upstream and registers it until the test completes
+func newWarmupCache(t *testing.T, upstream string, warmup config.Warmup) *cache.Cache {
+	proxy := config.Proxy{
+		Name:       "test",
+		TileURL:    upstream + "/{z}/{x}/{y}.pbf",
+		NumWorkers: 2,
+		Warmup:     warmup,
+		Cache: config.Cache{
+			KeyTemplate:    "{z}/{x}/{y}",
+			Layers:         []string{config.LayerMemory},
+			MemEnabled:     true,
+			MemCap:         64,
+			MemTTLDuration: time.Hour,
+		},
+	}
+
+	c, err := cache.New(proxy)
+	if err != nil {
+		t.Fatalf("failed to build cache: %s", err)
+	}
+
+	proxies := config.Get().Proxies
+	config.Get().Proxies = []config.Proxy{proxy}
+	cache.Caches["test"] = c
+	t.Cleanup(func() {
+		config.Get().Proxies = proxies
+		delete(cache.Caches, "test")
+	})
+
+	return c
+}
+
+// TestReadTileFile will test reading tiles from a tile file, skipping blank
+// lines and comments and reporting the line of malformed tiles
+func TestReadTileFile(t *testing.T) {
+	path := writeTileFile(t, "# popular tiles\n1/0/1\n\n  2/3/1  \n")
+
+	tiles, err := readTileFile(path)
+	if err != nil {
+		t.Fatalf("failed to read tile file: %s", err)
+	}
+
+	expected := []tile.Tile{{Zoom: 1, X: 0, Y: 1}, {Zoom: 2, X: 3, Y: 1}}
+	if !reflect.DeepEqual(tiles, expected) {
+		t.Errorf("expected tiles %v, got %v", expected, tiles)
+	}
+
+	if _, err = readTileFile(writeTileFile(t, "1/0/1\nnot a tile\n")); err == nil {
+		t.Error("expected malformed tile to fail")
+	} else if !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("expected error to name line 2, got %s", err)
+	}
+
+	if _, err = readTileFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("expected missing tile file to fail")
+	}
+}
+
+// TestWarmupTargets will test that warmup targets combine the tiles listed
+// in the tile file with those within the bounding box
+func TestWarmupTargets(t *testing.T) {
+	tiles, err := WarmupTargets(config.Warmup{
+		TileFile: writeTileFile(t, "5/1/2\n"),
+		BBox:     []float64{-180, -85, 180, 85},
+		MinZoom:  0,
+		MaxZoom:  1,
+	})
+	if err != nil {
+		t.Fatalf("failed to compute targets: %s", err)
+	}
+
+	// one listed tile, plus the root tile and its four children
+	if len(tiles) != 6 || tiles[0] != (tile.Tile{Zoom: 5, X: 1, Y: 2}) {
+		t.Errorf("expected listed tile followed by 5 bounded tiles, got %v", tiles)
+	}
+
+	if _, err = WarmupTargets(config.Warmup{TileFile: filepath.Join(t.TempDir(), "missing.txt")}); err == nil {
+		t.Error("expected missing tile file to fail")
+	}
+
+	if tiles, err = WarmupTargets(config.Warmup{}); err != nil || len(tiles) != 0 {
+		t.Errorf("expected no targets, got %v error=%v", tiles, err)
+	}
+}
+
+// TestWarmKeysMissing will test that warming reports the indices of keys
+// no cache level holds, and of every key once timed out
+func TestWarmKeysMissing(t *testing.T) {
+	c := newWarmupCache(t, "http://localhost", config.Warmup{})
+	for _, key := range []string{"a", "c"} {
+		c.Set(key, packet.Encode([]byte("tile"), map[string]string{}), nil, nil)
+	}
+
+	keys := []string{"a", "b", "c", "d"}
+
+	missing := warmKeys(context.Background(), c, keys, nil)
+	sort.Ints(missing)
+	if !reflect.DeepEqual(missing, []int{1, 3}) {
+		t.Errorf("expected keys 1 and 3 to be missing, got %v", missing)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	missing = warmKeys(ctx, c, keys, make([]cache.TTLs, len(keys)))
+	sort.Ints(missing)
+	if !reflect.DeepEqual(missing, []int{0, 1, 2, 3}) {
+		t.Errorf("expected every key to be missing once timed out, got %v", missing)
+	}
+}
+
+// TestWarmupReady will test that instances only report ready once the
+// warmup completed, with listed tiles missing from the cache fetched from
+// the upstream
+func TestWarmupReady(t *testing.T) {
+	fetched := make(chan string, 16)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched <- r.URL.Path
+		_, _ = w.Write([]byte("tile"))
+	}))
+	defer upstream.Close()
+
+	c := newWarmupCache(t, upstream.URL, config.Warmup{
+		TileFile:        writeTileFile(t, "1/0/0\n1/1/0\n"),
+		Upstream:        true,
+		TimeoutDuration: time.Minute,
+	})
+	c.Set("1/0/0", packet.Encode([]byte("tile"), map[string]string{}), nil, nil)
+
+	warmed.Store(false)
+	if Ready() {
+		t.Fatal("expected instance not to be ready before warmup")
+	}
+
+	Warmup()
+
+	if !Ready() {
+		t.Error("expected instance to be ready after warmup")
+	}
+
+	if len(fetched) != 1 || <-fetched != "/1/1/0.pbf" {
+		t.Errorf("expected only the uncached tile to be fetched")
+	}
+
+	if c.Peek("1/1/0", context.Background()) == nil {
+		t.Error("expected fetched tile to be cached")
+	}
+}
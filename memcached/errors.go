@@ -0,0 +1,65 @@
+package memcached
+
+import "fmt"
+
+// ErrNoServers is an error struct for a client created without servers
+type ErrNoServers struct{}
+
+// Error returns the string representation of ErrNoServers
+func (e ErrNoServers) Error() string {
+	return "memcached: no servers configured"
+}
+
+// ErrInvalidKey is an error struct for keys memcached can't store, which
+// must be at most 250 bytes without whitespace or control characters
+type ErrInvalidKey struct {
+	Key string
+}
+
+// Error returns the string representation of ErrInvalidKey
+func (e ErrInvalidKey) Error() string {
+	return fmt.Sprintf("memcached: invalid key '%s'", e.Key)
+}
+
+// ErrNotStored is an error struct returned when a conditional store,
+// such as an add of an existing key, was not performed
+type ErrNotStored struct {
+	Key string
+}
+
+// Error returns the string representation of ErrNotStored
+func (e ErrNotStored) Error() string {
+	return fmt.Sprintf("memcached: item with key '%s' not stored", e.Key)
+}
+
+// ErrServer is an error struct for errors reported by a memcached server
+type ErrServer struct {
+	Server  string
+	Message string
+}
+
+// Error returns the string representation of ErrServer
+func (e ErrServer) Error() string {
+	return fmt.Sprintf("memcached: server %s replied %s", e.Server, e.Message)
+}
+
+// ErrMalformedResponse is an error struct for unexpected server responses
+type ErrMalformedResponse struct {
+	Server string
+	Line   string
+}
+
+// Error returns the string representation of ErrMalformedResponse
+func (e ErrMalformedResponse) Error() string {
+	return fmt.Sprintf("memcached: malformed response from %s: %q", e.Server, e.Line)
+}
+
+// ErrNotFound is an error struct returned when no item exists at a key
+type ErrNotFound struct {
+	Key string
+}
+
+// Error returns the string representation of ErrNotFound
+func (e ErrNotFound) Error() string {
+	return fmt.Sprintf("memcached: no item found with key '%s'", e.Key)
+}
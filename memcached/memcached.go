@@ -0,0 +1,405 @@
+package memcached
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client is a minimal client for the memcached text protocol, supporting
+// just the operations LOD needs to use a memcached pool as a cache tier.
+// Keys are distributed over the servers with consistent hashing.
+type Client struct {
+	ring    *ring            // hash ring mapping keys to servers
+	pools   map[string]*pool // idle connections of each server
+	timeout time.Duration    // timeout of each operation
+}
+
+// Options for creating a new Client
+type Options struct {
+	Servers      []string      // host:port addresses of the memcached servers
+	Timeout      time.Duration // timeout of each operation, default 500ms
+	MaxIdleConns int           // idle connections kept open per server, default 8
+}
+
+// Item is a value stored in memcached
+type Item struct {
+	Key   string // key the item is stored at
+	Value []byte // item content
+	Flags uint32 // opaque flags stored alongside the content
+}
+
+// maxKeyLength is the longest key memcached accepts
+const maxKeyLength = 250
+
+// maxRelativeExpiry is the longest expiry memcached accepts as a relative
+// number of seconds, longer expiries must be given as a unix timestamp
+const maxRelativeExpiry = 30 * 24 * time.Hour
+
+// New creates a new Client from the given options
+func New(opts Options) (*Client, error) {
+	if len(opts.Servers) == 0 {
+		return nil, ErrNoServers{}
+	}
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = 500 * time.Millisecond
+	}
+
+	maxIdle := opts.MaxIdleConns
+	if maxIdle == 0 {
+		maxIdle = 8
+	}
+
+	pools := make(map[string]*pool, len(opts.Servers))
+	for _, server := range opts.Servers {
+		pools[server] = &pool{
+			server:  server,
+			maxIdle: maxIdle,
+		}
+	}
+
+	return &Client{
+		ring:    newRing(opts.Servers),
+		pools:   pools,
+		timeout: timeout,
+	}, nil
+}
+
+// ValidKey returns true if memcached can store an item at the given key
+func ValidKey(key string) bool {
+	if len(key) == 0 || len(key) > maxKeyLength {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Ping verifies that every server is reachable
+func (c *Client) Ping(ctx context.Context) error {
+	for _, p := range c.pools {
+		err := c.with(ctx, p, func(cn *conn) error {
+			line, err := cn.command("version\r\n")
+			if err != nil {
+				return err
+			}
+			if !strings.HasPrefix(line, "VERSION") {
+				return ErrMalformedResponse{Server: p.server, Line: line}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get returns the item stored at key, or ErrNotFound if there is none
+func (c *Client) Get(ctx context.Context, key string) (*Item, error) {
+	items, err := c.GetMulti(ctx, []string{key})
+	if err != nil {
+		return nil, err
+	}
+
+	item, ok := items[key]
+	if !ok {
+		return nil, ErrNotFound{Key: key}
+	}
+
+	return item, nil
+}
+
+// GetMulti returns the items stored at the given keys, fetching the keys of
+// each server in a single request. Keys without items are left out.
+func (c *Client) GetMulti(ctx context.Context, keys []string) (map[string]*Item, error) {
+	byServer := make(map[string][]string)
+	for _, key := range keys {
+		if !ValidKey(key) {
+			return nil, ErrInvalidKey{Key: key}
+		}
+		server := c.ring.pick(key)
+		byServer[server] = append(byServer[server], key)
+	}
+
+	items := make(map[string]*Item, len(keys))
+	for server, serverKeys := range byServer {
+		p := c.pools[server]
+		err := c.with(ctx, p, func(cn *conn) error {
+			if _, err := fmt.Fprintf(cn.rw, "get %s\r\n", strings.Join(serverKeys, " ")); err != nil {
+				return err
+			}
+			if err := cn.rw.Flush(); err != nil {
+				return err
+			}
+			return cn.readValues(items)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+// Set stores the item, expiring it after the given TTL, or never if zero
+func (c *Client) Set(ctx context.Context, item *Item, ttl time.Duration) error {
+	return c.store(ctx, "set", item, ttl)
+}
+
+// Add stores the item only if no item exists at its key, returning
+// ErrNotStored otherwise
+func (c *Client) Add(ctx context.Context, item *Item, ttl time.Duration) error {
+	return c.store(ctx, "add", item, ttl)
+}
+
+// Delete removes the item stored at key, succeeding if there is none
+func (c *Client) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey{Key: key}
+	}
+
+	p := c.pools[c.ring.pick(key)]
+	return c.with(ctx, p, func(cn *conn) error {
+		line, err := cn.command("delete " + key + "\r\n")
+		if err != nil {
+			return err
+		}
+		if line != "DELETED" && line != "NOT_FOUND" {
+			return ErrMalformedResponse{Server: p.server, Line: line}
+		}
+		return nil
+	})
+}
+
+// Close closes every idle connection
+func (c *Client) Close() error {
+	for _, p := range c.pools {
+		p.close()
+	}
+	return nil
+}
+
+// store sends a storage command for the item to the server owning its key
+func (c *Client) store(ctx context.Context, verb string, item *Item, ttl time.Duration) error {
+	if !ValidKey(item.Key) {
+		return ErrInvalidKey{Key: item.Key}
+	}
+
+	p := c.pools[c.ring.pick(item.Key)]
+	return c.with(ctx, p, func(cn *conn) error {
+		_, err := fmt.Fprintf(cn.rw, "%s %s %d %d %d\r\n", verb, item.Key,
+			item.Flags, expiry(ttl), len(item.Value))
+		if err != nil {
+			return err
+		}
+
+		if _, err = cn.rw.Write(item.Value); err != nil {
+			return err
+		}
+
+		line, err := cn.command("\r\n")
+		if err != nil {
+			return err
+		}
+
+		switch line {
+		case "STORED":
+			return nil
+		case "NOT_STORED":
+			return ErrNotStored{Key: item.Key}
+		}
+
+		return ErrMalformedResponse{Server: p.server, Line: line}
+	})
+}
+
+// expiry converts a TTL to memcached's expiry time, a relative number of
+// seconds, or a unix timestamp for TTLs longer than 30 days
+func expiry(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+
+	if ttl > maxRelativeExpiry {
+		return time.Now().Add(ttl).Unix()
+	}
+
+	// round up so sub-second TTLs don't mean never expire
+	return int64((ttl + time.Second - 1) / time.Second)
+}
+
+// with runs fn on a connection to the server of the given pool, returning
+// the connection to the pool afterwards unless it was left in a bad state
+func (c *Client) with(ctx context.Context, p *pool, fn func(cn *conn) error) error {
+	deadline := time.Now().Add(c.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	cn, err := p.get(deadline)
+	if err != nil {
+		return err
+	}
+
+	if err = cn.nc.SetDeadline(deadline); err != nil {
+		_ = cn.nc.Close()
+		return err
+	}
+
+	err = fn(cn)
+	switch err.(type) {
+	case nil, ErrNotStored:
+		// the connection is still in sync with the server
+		p.put(cn)
+	default:
+		_ = cn.nc.Close()
+	}
+
+	return err
+}
+
+// pool holds idle connections to a single server
+type pool struct {
+	server  string     // host:port address of the server
+	maxIdle int        // maximum number of idle connections kept open
+	lock    sync.Mutex // guards idle
+	idle    []*conn    // idle connections
+}
+
+// get returns an idle connection, or dials a new one if there are none
+func (p *pool) get(deadline time.Time) (*conn, error) {
+	p.lock.Lock()
+	if n := len(p.idle); n > 0 {
+		cn := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.lock.Unlock()
+		return cn, nil
+	}
+	p.lock.Unlock()
+
+	dialer := net.Dialer{Deadline: deadline}
+	nc, err := dialer.Dial("tcp", p.server)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn{
+		server: p.server,
+		nc:     nc,
+		rw:     bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc)),
+	}, nil
+}
+
+// put returns a connection to the pool, closing it if the pool is full
+func (p *pool) put(cn *conn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if len(p.idle) >= p.maxIdle {
+		_ = cn.nc.Close()
+		return
+	}
+
+	p.idle = append(p.idle, cn)
+}
+
+// close closes every idle connection in the pool
+func (p *pool) close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, cn := range p.idle {
+		_ = cn.nc.Close()
+	}
+	p.idle = nil
+}
+
+// conn is a buffered connection to a single server
+type conn struct {
+	server string
+	nc     net.Conn
+	rw     *bufio.ReadWriter
+}
+
+// command writes the request and returns the single line response
+func (cn *conn) command(request string) (string, error) {
+	if _, err := cn.rw.WriteString(request); err != nil {
+		return "", err
+	}
+	if err := cn.rw.Flush(); err != nil {
+		return "", err
+	}
+	return cn.readLine()
+}
+
+// readLine reads a response line, converting error replies to errors
+func (cn *conn) readLine() (string, error) {
+	line, err := cn.rw.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+
+	if line == "ERROR" || strings.HasPrefix(line, "CLIENT_ERROR") ||
+		strings.HasPrefix(line, "SERVER_ERROR") {
+		return "", ErrServer{Server: cn.server, Message: line}
+	}
+
+	return line, nil
+}
+
+// readValues reads the VALUE blocks of a get response into items
+func (cn *conn) readValues(items map[string]*Item) error {
+	for {
+		line, err := cn.readLine()
+		if err != nil {
+			return err
+		}
+
+		if line == "END" {
+			return nil
+		}
+
+		// VALUE <key> <flags> <bytes>
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "VALUE" {
+			return ErrMalformedResponse{Server: cn.server, Line: line}
+		}
+
+		flags, errFlags := strconv.ParseUint(fields[2], 10, 32)
+		size, errSize := strconv.Atoi(fields[3])
+		if errFlags != nil || errSize != nil || size < 0 {
+			return ErrMalformedResponse{Server: cn.server, Line: line}
+		}
+
+		value := make([]byte, size+2)
+		if _, err = io.ReadFull(cn.rw, value); err != nil {
+			return err
+		}
+		if !bytes.HasSuffix(value, []byte("\r\n")) {
+			return ErrMalformedResponse{Server: cn.server, Line: line}
+		}
+
+		items[fields[1]] = &Item{
+			Key:   fields[1],
+			Value: value[:size],
+			Flags: uint32(flags),
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package memcached
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeServer serves a subset of the memcached text protocol from a map
+func fakeServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen, error=%s", err.Error())
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	var lock sync.Mutex
+	items := make(map[string]string)
+
+	serve := func(nc net.Conn) {
+		defer nc.Close()
+		rw := bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc))
+
+		for {
+			line, errRead := rw.ReadString('\n')
+			if errRead != nil {
+				return
+			}
+			fields := strings.Fields(line)
+
+			lock.Lock()
+			switch fields[0] {
+			case "version":
+				_, _ = rw.WriteString("VERSION 1.6.0\r\n")
+			case "get":
+				for _, key := range fields[1:] {
+					if value, ok := items[key]; ok {
+						flags, data, _ := strings.Cut(value, " ")
+						_, _ = fmt.Fprintf(rw, "VALUE %s %s %d\r\n%s\r\n", key, flags, len(data), data)
+					}
+				}
+				_, _ = rw.WriteString("END\r\n")
+			case "set", "add":
+				size, _ := strconv.Atoi(fields[4])
+				data := make([]byte, size+2)
+				_, _ = io.ReadFull(rw, data)
+				if _, ok := items[fields[1]]; ok && fields[0] == "add" {
+					_, _ = rw.WriteString("NOT_STORED\r\n")
+				} else {
+					items[fields[1]] = fields[2] + " " + string(data[:size])
+					_, _ = rw.WriteString("STORED\r\n")
+				}
+			case "delete":
+				delete(items, fields[1])
+				_, _ = rw.WriteString("DELETED\r\n")
+			default:
+				_, _ = rw.WriteString("ERROR\r\n")
+			}
+			lock.Unlock()
+
+			if rw.Flush() != nil {
+				return
+			}
+		}
+	}
+
+	go func() {
+		for {
+			nc, errAccept := listener.Accept()
+			if errAccept != nil {
+				return
+			}
+			go serve(nc)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// TestClientRoundTrip will test storing, fetching and deleting items
+func TestClientRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	client, err := New(Options{Servers: []string{fakeServer(t), fakeServer(t)}})
+	if err != nil {
+		t.Fatalf("failed to create client, error=%s", err.Error())
+	}
+	defer client.Close()
+
+	if err = client.Ping(ctx); err != nil {
+		t.Fatalf("failed to ping, error=%s", err.Error())
+	}
+
+	for i := 0; i < 10; i++ {
+		item := &Item{Key: fmt.Sprintf("key-%d", i), Value: []byte(fmt.Sprintf("value\r\n%d", i)), Flags: 1}
+		if err = client.Set(ctx, item, 0); err != nil {
+			t.Fatalf("failed to set %s, error=%s", item.Key, err.Error())
+		}
+	}
+
+	items, err := client.GetMulti(ctx, []string{"key-1", "key-7", "missing"})
+	if err != nil {
+		t.Fatalf("failed to get, error=%s", err.Error())
+	}
+
+	if len(items) != 2 || string(items["key-7"].Value) != "value\r\n7" || items["key-7"].Flags != 1 {
+		t.Errorf("unexpected items %+v", items)
+	}
+
+	if err = client.Add(ctx, &Item{Key: "key-1", Value: []byte("other")}, 0); err == nil {
+		t.Errorf("expected add of existing key to fail")
+	}
+
+	if err = client.Delete(ctx, "key-1"); err != nil {
+		t.Fatalf("failed to delete, error=%s", err.Error())
+	}
+
+	if _, err = client.Get(ctx, "key-1"); err == nil {
+		t.Errorf("expected key-1 to be deleted")
+	}
+
+	if err = client.Set(ctx, &Item{Key: "bad key"}, 0); err == nil {
+		t.Errorf("expected invalid key to be rejected")
+	}
+}
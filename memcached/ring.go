@@ -0,0 +1,58 @@
+package memcached
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// replicas is the number of points each server is placed at on the ring
+const replicas = 160
+
+// ring is a consistent hash ring mapping keys to servers. Each server is
+// placed at several points so keys spread evenly, and changing the server
+// list only moves the keys of the servers that were added or removed.
+type ring struct {
+	points  []uint32          // sorted hashes of every server point
+	servers map[uint32]string // server address at each point
+}
+
+// newRing builds a hash ring over the given server addresses
+func newRing(servers []string) *ring {
+	r := &ring{
+		points:  make([]uint32, 0, len(servers)*replicas),
+		servers: make(map[uint32]string, len(servers)*replicas),
+	}
+
+	for _, server := range servers {
+		for i := 0; i < replicas; i++ {
+			point := crc32.ChecksumIEEE([]byte(server + "-" + strconv.Itoa(i)))
+			if _, ok := r.servers[point]; ok {
+				continue
+			}
+			r.servers[point] = server
+			r.points = append(r.points, point)
+		}
+	}
+
+	sort.Slice(r.points, func(i, j int) bool {
+		return r.points[i] < r.points[j]
+	})
+
+	return r
+}
+
+// pick returns the address of the server responsible for the given key,
+// the first server point at or after the key's hash on the ring
+func (r *ring) pick(key string) string {
+	hash := crc32.ChecksumIEEE([]byte(key))
+
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i] >= hash
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return r.servers[r.points[i]]
+}
@@ -0,0 +1,31 @@
+package memcached
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestRingRemap will test that removing a server only moves the keys that
+// were owned by that server, and that keys spread over every server
+func TestRingRemap(t *testing.T) {
+	servers := []string{"10.0.0.1:11211", "10.0.0.2:11211", "10.0.0.3:11211"}
+	full := newRing(servers)
+	reduced := newRing(servers[:2])
+
+	owned := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("%d/%d/%d", i%20, i, i*7)
+		server := full.pick(key)
+		owned[server]++
+
+		if server != servers[2] && reduced.pick(key) != server {
+			t.Fatalf("key %s moved from %s after removing %s", key, server, servers[2])
+		}
+	}
+
+	for _, server := range servers {
+		if owned[server] < 2000 {
+			t.Errorf("expected keys to spread evenly, %s owns %d of 10000", server, owned[server])
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package packet
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Checksum is the integrity checksum algorithm of a version 2 TilePacket,
+// recorded in the low bits of its flags. SHA-256 is the slowest and most
+// collision resistant, while CRC32C and xxHash are cheap enough to verify
+// large tiles on every cache hit.
+type Checksum uint8
+
+// Checksum algorithms, version 1 packets always use SHA-256
+const (
+	SHA256 Checksum = iota // SHA-256, the default
+	CRC32C                 // CRC-32 with the Castagnoli polynomial, hardware accelerated on most CPUs
+	XXHash                 // 64-bit xxHash
+)
+
+// checksumMask selects the checksum algorithm from the flags of a packet
+const checksumMask = 0x0f
+
+// checksumNames maps configuration names to checksum algorithms
+var checksumNames = map[string]Checksum{
+	"":       SHA256,
+	"sha256": SHA256,
+	"crc32c": CRC32C,
+	"xxhash": XXHash,
+}
+
+// castagnoli is the CRC32C lookup table
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ParseChecksum returns the checksum algorithm with the given name, one of
+// sha256, crc32c or xxhash, defaulting to SHA-256 if no name is given
+func ParseChecksum(name string) (Checksum, bool) {
+	checksum, ok := checksumNames[name]
+	return checksum, ok
+}
+
+// sum computes the checksum of the data, zero padded to the size of the
+// checksum field, or returns false for unknown algorithms
+func (c Checksum) sum(data []byte) ([sha256.Size]byte, bool) {
+	var sum [sha256.Size]byte
+
+	switch c {
+	case SHA256:
+		sum = sha256.Sum256(data)
+	case CRC32C:
+		binary.LittleEndian.PutUint32(sum[:], crc32.Checksum(data, castagnoli))
+	case XXHash:
+		binary.LittleEndian.PutUint64(sum[:], xxhash.Sum64(data))
+	default:
+		return sum, false
+	}
+
+	return sum, true
+}
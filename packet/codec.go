@@ -0,0 +1,110 @@
+package packet
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec is the compression codec of the tile data of a version 2
+// TilePacket, recorded in the high bits of its flags
+type Codec uint8
+
+// Compression codecs, version 1 packets are never compressed
+const (
+	CodecNone Codec = iota // uncompressed
+	CodecGzip              // gzip, understood by every client
+	CodecZstd              // zstandard, smaller and faster than gzip
+)
+
+// codecShift is the position of the codec within the flags of a packet
+const codecShift = 4
+
+// codecNames maps configuration names to compression codecs
+var codecNames = map[string]Codec{
+	"":     CodecNone,
+	"none": CodecNone,
+	"gzip": CodecGzip,
+	"zstd": CodecZstd,
+}
+
+// ParseCodec returns the compression codec with the given name, one of
+// none, gzip or zstd, defaulting to none if no name is given
+func ParseCodec(name string) (Codec, bool) {
+	codec, ok := codecNames[name]
+	return codec, ok
+}
+
+// Encoding returns the HTTP content coding of the codec, or an empty string
+// for uncompressed data
+func (c Codec) Encoding() string {
+	switch c {
+	case CodecGzip:
+		return "gzip"
+	case CodecZstd:
+		return "zstd"
+	}
+	return ""
+}
+
+var (
+	// gzipWriters pools gzip writers, which are expensive to allocate
+	gzipWriters = sync.Pool{New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, gzip.BestSpeed)
+		return w
+	}}
+
+	// zstdOnce lazily creates the shared zstd encoder and decoder, which are
+	// safe for concurrent use of EncodeAll and DecodeAll
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+// initZstd creates the shared zstd encoder and decoder
+func initZstd() {
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+}
+
+// compress returns the data compressed with the codec
+func (c Codec) compress(data []byte) ([]byte, error) {
+	switch c {
+	case CodecGzip:
+		buf := bytes.NewBuffer(make([]byte, 0, len(data)/2))
+		w := gzipWriters.Get().(*gzip.Writer)
+		defer gzipWriters.Put(w)
+
+		w.Reset(buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CodecZstd:
+		zstdOnce.Do(initZstd)
+		return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+	}
+	return data, nil
+}
+
+// decompress returns the data decompressed with the codec
+func (c Codec) decompress(data []byte) ([]byte, error) {
+	switch c {
+	case CodecGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	case CodecZstd:
+		zstdOnce.Do(initZstd)
+		return zstdDecoder.DecodeAll(data, nil)
+	}
+	return data, nil
+}
@@ -0,0 +1,36 @@
+package packet
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Internal headers were stored in version 1 TilePackets for LOD's own
+// bookkeeping and are never sent to clients. Version 2 packets hold this
+// metadata in dedicated fields instead.
+const (
+	InternalPrefix = "Lod-"                     // prefix of every internal header
+	HeaderExpires  = InternalPrefix + "Expires" // unix time the tile expires at
+	HeaderFetched  = InternalPrefix + "Fetched" // unix time the tile was fetched from upstream at
+)
+
+// IsInternal returns true if the header is an internal header
+func IsInternal(header string) bool {
+	return strings.HasPrefix(header, InternalPrefix)
+}
+
+// unixHeader parses a unix time from the named header
+func unixHeader(headers map[string]string, name string) (time.Time, bool) {
+	value, ok := headers[name]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(unix, 0), true
+}
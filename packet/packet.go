@@ -1,8 +1,9 @@
 package packet
 
 import (
+	"bytes"
 	"crypto/sha256"
-	"encoding/binary"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -10,99 +11,255 @@ import (
 // TilePacket is a custom binary data type for storing tile metadata alongside
 // the tile data itself. Keeping it bundled up in bytes allows us to store it
 // pretty much anywhere.
+//
+// Version 1 packets carry only a checksum, headers and the tile data:
 // |----------------------------------------------------------------------|
 // | Checksum | Tile Data Size | Count | H1K Size | H1K | ... | Tile Data |
 // |----------------------------------------------------------------------|
 // | 32 bytes |     uint32     | uint8 |  uint16  | <-N | ... |  N bytes  |
 // |----------------------------------------------------------------------|
+//
+// Version 2 packets start with a magic prefix ending in the version number
+// and hold the tile's fetch metadata. Times are unix milliseconds, 0 when
+// unset. The low 4 bits of the flags select the checksum algorithm, whose
+// result is zero padded to fill the checksum, and the high 4 bits select the
+// compression codec of the tile data. The ETag, Last-Modified,
+// content hash, header keys and header values are each prefixed with their
+// uvarint length, and the content hash is empty unless the tile was encoded
+// with one.
+// |-------------------------------------------------------------------------------|
+// |  Magic  | Checksum | Flags | Fetched | Expires | Status | ETag | L-Mod | Hash |
+// |-------------------------------------------------------------------------------|
+// | 4 bytes | 32 bytes | uint8 |  int64  |  int64  | uint16 | <-N  |  <-N  | <-N  |
+// |-------------------------------------------------------------------------------|
+// |  Count  | H1K | H1V | ... | Tile Data |
+// |---------------------------------------|
+// | uvarint | <-N | <-N | ... |  N bytes  |
+// |---------------------------------------|
 type TilePacket []byte
 
-// FromBytes wraps tile data from the cache and validates the
-// contents, returning a TilePacket for additional processing
-func FromBytes(data []byte, cacheKey string) (*TilePacket, error) {
-	tile := TilePacket(data)
-	valid := tile.Validate()
+// Versions of the TilePacket layout
+const (
+	V1 = 1 // original layout without metadata
+	V2 = 2 // layout with a version prefix and fetch metadata
+)
+
+// magicV2 prefixes every version 2 TilePacket
+var magicV2 = []byte{'L', 'O', 'D', V2}
+
+// Meta is the metadata stored alongside a tile in version 2 TilePackets
+type Meta struct {
+	Fetched      time.Time // time the tile was fetched from upstream at
+	Expires      time.Time // time the tile expires at, zero for never
+	Status       int       // upstream response status code
+	ETag         string    // upstream ETag header
+	LastModified string    // upstream Last-Modified header
+	ContentHash  []byte    // optional hash of the tile data, separate from the checksum
+	Checksum     Checksum  // integrity checksum algorithm of the packet
+	Codec        Codec     // compression codec of the stored tile data
+}
 
-	if !valid {
+// FromBytes wraps tile data from the cache and validates the contents
+// against the layout of its version, returning the TilePacket decoded for
+// additional processing
+func FromBytes(data []byte, cacheKey string) (*Decoded, error) {
+	if !TilePacket(data).verify() {
 		return nil, ErrTilePacketValidate{Key: cacheKey}
 	}
 
-	return &tile, nil
+	return FromTrustedBytes(data, cacheKey)
 }
 
-// Raw returns the TilePacket as a byte array
-func (t TilePacket) Raw() []byte {
-	return t
+// FromTrustedBytes wraps tile data from a trusted source, such as the
+// in-process memory cache which only holds packets that were validated or
+// encoded by this instance, checking its structure but not its checksum
+func FromTrustedBytes(data []byte, cacheKey string) (*Decoded, error) {
+	decoded, ok := TilePacket(data).decode()
+	if !ok {
+		return nil, ErrTilePacketValidate{Key: cacheKey}
+	}
+
+	return &decoded, nil
 }
 
-// Decode a TilePacket back into raw tile data and corresponding metadata
-func (t TilePacket) Decode() ([]byte, map[string]string, error) {
-	// ensure that the stored data is valid
-	if !t.Validate() {
-		return nil, nil, errors.New("checksum match failed, tile packet data corrupted")
+// Version returns the layout version of the TilePacket. Version 1 packets
+// have no prefix, so any packet without the version 2 prefix is version 1.
+func (t TilePacket) Version() int {
+	if bytes.HasPrefix(t, magicV2) {
+		return V2
 	}
+	return V1
+}
 
-	return t.TileData(), t.Headers(), nil
+// Raw returns the TilePacket as a byte array
+func (t TilePacket) Raw() []byte {
+	return t
 }
 
 // Validate the tile packet against the stored checksum
 func (t TilePacket) Validate() bool {
-	// guard against malformed or empty packets
-	if len(t) < sha256.Size {
+	if !t.verify() {
 		return false
 	}
+	_, ok := t.decode()
+	return ok
+}
+
+// verify the tile packet against the stored checksum
+func (t TilePacket) verify() bool {
+	if t.Version() == V2 {
+		return t.verifyV2()
+	}
+	return t.validateV1()
+}
+
+// decode parses the layout of the TilePacket, returning false if it's
+// malformed. Version 1 packets only record their fetch and expiry times,
+// in internal headers.
+func (t TilePacket) decode() (Decoded, bool) {
+	if t.Version() == V2 {
+		layout := t.parseV2()
+		return Decoded{
+			TilePacket: t,
+			meta:       layout.meta,
+			headers:    layout.headers,
+			data:       t[layout.data:],
+		}, layout.ok
+	}
 
-	// compute checksum on packet data starting after stored checksum
-	checksum := sha256.Sum256(t[32:])
+	if len(t) <= sha256.Size+4 || t.tileDataSizeV1() > len(t)-sha256.Size-5 {
+		return Decoded{TilePacket: t, headers: make(map[string]string)}, false
+	}
 
-	var storedChecksum [sha256.Size]byte
-	for i := range t[:32] {
-		storedChecksum[i] = t[i]
+	decoded := Decoded{
+		TilePacket: t,
+		headers:    t.headersV1(),
+		data:       t.tileDataV1(),
 	}
+	decoded.meta.Fetched, _ = unixHeader(decoded.headers, HeaderFetched)
+	decoded.meta.Expires, _ = unixHeader(decoded.headers, HeaderExpires)
 
-	return checksum == storedChecksum
+	return decoded, true
 }
 
-// TileData returns the raw tile data from the TilePacket
+// Decode a TilePacket back into raw tile data and corresponding metadata,
+// decompressing the tile data if it was stored compressed
+func (t TilePacket) Decode() ([]byte, map[string]string, error) {
+	decoded, _ := t.decode()
+	return decoded.Decode()
+}
+
+// Decompress returns the tile data decompressed with the packet's codec
+func (t TilePacket) Decompress() ([]byte, error) {
+	decoded, _ := t.decode()
+	return decoded.Decompress()
+}
+
+// TileData returns the tile data from the TilePacket as stored, compressed
+// with the packet's codec if it has one
 func (t TilePacket) TileData() []byte {
-	// use tile data size to calculate the offset from the start of the packet that the tile data begins
-	// this is necessary since header metadata can be variable in both count and length
-	return t[(len(t) - t.TileDataSize()):]
+	decoded, _ := t.decode()
+	return decoded.data
 }
 
-// TileDataSize returns the raw tile data size in bytes from the TilePacket
+// TileDataSize returns the stored tile data size in bytes from the TilePacket
 func (t TilePacket) TileDataSize() int {
-	return int(binary.LittleEndian.Uint32(t[32:36]))
+	decoded, _ := t.decode()
+	return len(decoded.data)
 }
 
 // Headers returns a KV map of HTTP headers from the TilePacket
 func (t TilePacket) Headers() map[string]string {
-	// starting index for first byte of first header's key in the TilePacket structure
-	offset := 37
+	decoded, _ := t.decode()
+	return decoded.headers
+}
 
-	headers := make(map[string]string)
+// LenHeaders returns the number of headers encoded in the TilePacket
+func (t TilePacket) LenHeaders() int {
+	decoded, _ := t.decode()
+	return len(decoded.headers)
+}
 
-	for count := 0; count < t.LenHeaders(); count++ {
-		keyLen := binary.LittleEndian.Uint16(t[offset : offset+2])
-		offset += 2
+// Meta returns the metadata stored in the TilePacket
+func (t TilePacket) Meta() Meta {
+	decoded, _ := t.decode()
+	return decoded.meta
+}
+
+// Expires returns the time the tile expires at, if one was recorded
+func (t TilePacket) Expires() (time.Time, bool) {
+	decoded, _ := t.decode()
+	return decoded.Expires()
+}
 
-		key := string(t[offset : offset+int(keyLen)])
-		offset += int(keyLen)
+// Fetched returns the time the tile was fetched from upstream at, if recorded
+func (t TilePacket) Fetched() (time.Time, bool) {
+	decoded, _ := t.decode()
+	return decoded.Fetched()
+}
 
-		valLen := binary.LittleEndian.Uint16(t[offset : offset+2])
-		offset += 2
+// Decoded is a TilePacket whose layout was parsed once when read, so that
+// its accessors don't parse it again
+type Decoded struct {
+	TilePacket
+	meta    Meta              // metadata of the tile
+	headers map[string]string // stored headers
+	data    []byte            // tile data as stored
+}
 
-		val := string(t[offset : offset+int(valLen)])
-		offset += int(valLen)
+// Decode the packet back into raw tile data and corresponding metadata,
+// decompressing the tile data if it was stored compressed
+func (d Decoded) Decode() ([]byte, map[string]string, error) {
+	// ensure that the stored data is valid
+	if !d.verify() {
+		return nil, nil, errors.New("checksum match failed, tile packet data corrupted")
+	}
 
-		headers[key] = val
+	tile, err := d.Decompress()
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return headers
+	return tile, d.headers, nil
 }
 
-// LenHeaders returns the number of headers encoded in the TilePacket.
-// Reads the count byte (byte index 36) and returns the value as an integer
-func (t TilePacket) LenHeaders() int {
-	return int(t[36:37][0])
+// Decompress returns the tile data decompressed with the packet's codec
+func (d Decoded) Decompress() ([]byte, error) {
+	return d.meta.Codec.decompress(d.data)
+}
+
+// TileData returns the tile data as stored, compressed with the packet's
+// codec if it has one
+func (d Decoded) TileData() []byte {
+	return d.data
+}
+
+// TileDataSize returns the stored tile data size in bytes
+func (d Decoded) TileDataSize() int {
+	return len(d.data)
+}
+
+// Headers returns a KV map of HTTP headers stored in the packet
+func (d Decoded) Headers() map[string]string {
+	return d.headers
+}
+
+// LenHeaders returns the number of headers stored in the packet
+func (d Decoded) LenHeaders() int {
+	return len(d.headers)
+}
+
+// Meta returns the metadata stored in the packet
+func (d Decoded) Meta() Meta {
+	return d.meta
+}
+
+// Expires returns the time the tile expires at, if one was recorded
+func (d Decoded) Expires() (time.Time, bool) {
+	return d.meta.Expires, !d.meta.Expires.IsZero()
+}
+
+// Fetched returns the time the tile was fetched from upstream at, if recorded
+func (d Decoded) Fetched() (time.Time, bool) {
+	return d.meta.Fetched, !d.meta.Fetched.IsZero()
 }
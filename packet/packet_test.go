@@ -1,9 +1,14 @@
 package packet
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
 	_ "embed"
+	"encoding/binary"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/dechristopher/lod/str"
 )
@@ -30,6 +35,164 @@ func TestDecode(t *testing.T) {
 	}
 }
 
+// TestDecodeV1 will test that version 1 tile packets still decode, along
+// with the fetch and expiry times recorded in their internal headers
+func TestDecodeV1(t *testing.T) {
+	headers := map[string]string{
+		"Content-Type": "application/vnd.mapbox-vector-tile",
+		HeaderFetched:  "1700000000",
+		HeaderExpires:  "1700003600",
+	}
+
+	tile, err := FromBytes(encodeV1(testTile, headers), "1/2/3")
+	if err != nil {
+		t.Fatalf(str.TCacheBadDecode, err.Error())
+	}
+
+	if tile.Version() != V1 {
+		t.Errorf(str.TCacheBadVersion, tile.Version(), V1)
+	}
+
+	if !reflect.DeepEqual(tile.TileData(), testTile) {
+		t.Errorf(str.TCacheBadTileData)
+	}
+
+	if !reflect.DeepEqual(tile.Headers(), headers) {
+		t.Errorf(str.TCacheBadHeaderData)
+	}
+
+	expected := Meta{Fetched: time.Unix(1700000000, 0), Expires: time.Unix(1700003600, 0)}
+	if meta := tile.Meta(); !reflect.DeepEqual(meta, expected) {
+		t.Errorf(str.TCacheBadMeta, meta, expected)
+	}
+}
+
+// TestDecodeMeta will test that fetch metadata and headers beyond the
+// limits of version 1 round trip through a version 2 tile packet
+func TestDecodeMeta(t *testing.T) {
+	headers := make(map[string]string)
+	for i := 0; i < 300; i++ {
+		headers["X-Header-"+string(rune('A'+i%26))+string(rune('a'+i/26))] = "value"
+	}
+	headers["X-Large"] = string(make([]byte, 70000))
+
+	expected := Meta{
+		Fetched:      time.UnixMilli(time.Now().UnixMilli()),
+		Expires:      time.UnixMilli(time.Now().Add(time.Hour).UnixMilli()),
+		Status:       200,
+		ETag:         `"abc123"`,
+		LastModified: "Wed, 21 Oct 2015 07:28:00 GMT",
+		ContentHash:  []byte{1, 2, 3, 4},
+	}
+
+	tile, err := FromBytes(EncodeMeta(testTile, headers, expected), "1/2/3")
+	if err != nil {
+		t.Fatalf(str.TCacheBadDecode, err.Error())
+	}
+
+	if tile.Version() != V2 {
+		t.Errorf(str.TCacheBadVersion, tile.Version(), V2)
+	}
+
+	if !reflect.DeepEqual(tile.TileData(), testTile) {
+		t.Errorf(str.TCacheBadTileData)
+	}
+
+	if tile.LenHeaders() != len(headers) || !reflect.DeepEqual(tile.Headers(), headers) {
+		t.Errorf(str.TCacheBadHeaderData)
+	}
+
+	if meta := tile.Meta(); !reflect.DeepEqual(meta, expected) {
+		t.Errorf(str.TCacheBadMeta, meta, expected)
+	}
+
+	// corrupt the metadata, which the checksum covers
+	tile.TilePacket[expiresV2]++
+	if tile.Validate() {
+		t.Errorf(str.TCacheBadValidation)
+	}
+}
+
+// TestChecksums will test that packets encoded with each checksum algorithm
+// validate, and fail validation once their tile data is corrupted
+func TestChecksums(t *testing.T) {
+	for _, checksum := range []Checksum{SHA256, CRC32C, XXHash} {
+		tile := EncodeMeta(testTile, testHeaders, Meta{Checksum: checksum})
+
+		if !tile.Validate() || tile.Meta().Checksum != checksum {
+			t.Errorf(str.TCacheBadValidation)
+		}
+
+		tile[len(tile)-1]++
+		if tile.Validate() {
+			t.Errorf("expected corrupted packet to fail %d checksum", checksum)
+		}
+	}
+}
+
+// TestCompression will test that tile data is stored compressed with each
+// codec and decodes intact, and that incompressible data is stored as is
+func TestCompression(t *testing.T) {
+	json := bytes.Repeat([]byte(`{"type":"Feature","properties":{}},`), 200)
+
+	for _, codec := range []Codec{CodecGzip, CodecZstd} {
+		tile := EncodeMeta(json, testHeaders, Meta{Codec: codec})
+
+		if tile.Meta().Codec != codec || tile.TileDataSize() >= len(json) {
+			t.Errorf("expected tile data compressed with codec %d, got codec %d",
+				codec, tile.Meta().Codec)
+		}
+
+		decoded, _, err := tile.Decode()
+		if err != nil {
+			t.Fatalf(str.TCacheBadDecode, err.Error())
+		}
+
+		if !bytes.Equal(decoded, json) {
+			t.Errorf(str.TCacheBadTileData)
+		}
+	}
+
+	random := make([]byte, 4096)
+	_, _ = rand.Read(random)
+
+	if tile := EncodeMeta(random, testHeaders, Meta{Codec: CodecZstd}); tile.Meta().Codec != CodecNone ||
+		!bytes.Equal(tile.TileData(), random) {
+		t.Errorf("expected incompressible tile data to be stored uncompressed")
+	}
+}
+
+// BenchmarkValidate will benchmark verifying a large raster tile with each
+// checksum algorithm, and wrapping it without verification
+func BenchmarkValidate(b *testing.B) {
+	raster := make([]byte, 512*1024)
+	_, _ = rand.Read(raster)
+
+	for _, name := range []string{"sha256", "crc32c", "xxhash"} {
+		checksum, _ := ParseChecksum(name)
+		tile := EncodeMeta(raster, testHeaders, Meta{Checksum: checksum})
+
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(tile)))
+			for i := 0; i < b.N; i++ {
+				if _, err := FromBytes(tile, "1/2/3"); err != nil {
+					b.Errorf(str.TCacheBadDecode, err.Error())
+				}
+			}
+		})
+	}
+
+	tile := Encode(raster, testHeaders)
+	b.Run("trusted", func(b *testing.B) {
+		b.SetBytes(int64(len(tile)))
+		for i := 0; i < b.N; i++ {
+			if _, err := FromTrustedBytes(tile, "1/2/3"); err != nil {
+				b.Errorf(str.TCacheBadDecode, err.Error())
+			}
+		}
+	})
+}
+
 // BenchmarkDecode will benchmark a standard tile and metadata decode
 func BenchmarkDecode(b *testing.B) {
 	// encode test tile
@@ -43,3 +206,25 @@ func BenchmarkDecode(b *testing.B) {
 		}
 	}
 }
+
+// encodeV1 encodes tile data and headers into a version 1 TilePacket, as
+// LOD did before version 2 was introduced
+func encodeV1(tile []byte, headers map[string]string) TilePacket {
+	tilePacket := make(TilePacket, sha256.Size+5)
+	binary.LittleEndian.PutUint32(tilePacket[32:36], uint32(len(tile)))
+	tilePacket[36] = uint8(len(headers))
+
+	for key, val := range headers {
+		tilePacket = binary.LittleEndian.AppendUint16(tilePacket, uint16(len(key)))
+		tilePacket = append(tilePacket, key...)
+		tilePacket = binary.LittleEndian.AppendUint16(tilePacket, uint16(len(val)))
+		tilePacket = append(tilePacket, val...)
+	}
+
+	tilePacket = append(tilePacket, tile...)
+
+	checksum := sha256.Sum256(tilePacket[32:])
+	copy(tilePacket, checksum[:])
+
+	return tilePacket
+}
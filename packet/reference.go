@@ -0,0 +1,66 @@
+package packet
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// magicRef prefixes references, which hold everything of a version 2
+// TilePacket but its tile data, stored separately by content hash so that
+// identical tiles are only stored once
+var magicRef = []byte{'L', 'O', 'D', 'R'}
+
+// ContentHash returns the hash identifying uncompressed tile data by its
+// content, for use as the content hash of a TilePacket
+func ContentHash(tile []byte) []byte {
+	sum := sha256.Sum256(tile)
+	return sum[:]
+}
+
+// Split splits a version 2 TilePacket with a content hash into a reference
+// holding everything but its tile data, and the tile data as stored.
+// Packets without a content hash can't be split.
+func (t TilePacket) Split() ([]byte, []byte, bool) {
+	if t.Version() != V2 {
+		return nil, nil, false
+	}
+
+	layout := t.parseV2()
+	if !layout.ok || len(layout.meta.ContentHash) == 0 {
+		return nil, nil, false
+	}
+
+	ref := make([]byte, 0, len(magicRef)+layout.data)
+	ref = append(append(ref, magicRef...), t[:layout.data]...)
+
+	return ref, t[layout.data:], true
+}
+
+// Referenced returns the content hash and codec of the tile data the
+// reference points to, or false if the data isn't a reference
+func Referenced(data []byte) ([]byte, Codec, bool) {
+	if !bytes.HasPrefix(data, magicRef) {
+		return nil, CodecNone, false
+	}
+
+	ref := TilePacket(data[len(magicRef):])
+	if ref.Version() != V2 {
+		return nil, CodecNone, false
+	}
+
+	layout := ref.parseV2()
+	if !layout.ok || layout.data != len(ref) || len(layout.meta.ContentHash) == 0 {
+		return nil, CodecNone, false
+	}
+
+	return layout.meta.ContentHash, layout.meta.Codec, true
+}
+
+// Join rebuilds the TilePacket a reference was split from using its tile
+// data. The result must be validated, since the tile data is stored apart
+// from the reference.
+func Join(ref, tile []byte) TilePacket {
+	tilePacket := make(TilePacket, 0, len(ref)-len(magicRef)+len(tile))
+	tilePacket = append(tilePacket, ref[len(magicRef):]...)
+	return append(tilePacket, tile...)
+}
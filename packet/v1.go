@@ -0,0 +1,68 @@
+package packet
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// validateV1 validates a version 1 tile packet against the stored checksum
+func (t TilePacket) validateV1() bool {
+	// guard against malformed or empty packets
+	if len(t) < sha256.Size {
+		return false
+	}
+
+	// compute checksum on packet data starting after stored checksum
+	checksum := sha256.Sum256(t[32:])
+
+	var storedChecksum [sha256.Size]byte
+	for i := range t[:32] {
+		storedChecksum[i] = t[i]
+	}
+
+	return checksum == storedChecksum
+}
+
+// tileDataV1 returns the raw tile data from a version 1 TilePacket
+func (t TilePacket) tileDataV1() []byte {
+	// use tile data size to calculate the offset from the start of the packet that the tile data begins
+	// this is necessary since header metadata can be variable in both count and length
+	return t[(len(t) - t.tileDataSizeV1()):]
+}
+
+// tileDataSizeV1 returns the raw tile data size in bytes from a version 1 TilePacket
+func (t TilePacket) tileDataSizeV1() int {
+	return int(binary.LittleEndian.Uint32(t[32:36]))
+}
+
+// headersV1 returns a KV map of HTTP headers from a version 1 TilePacket
+func (t TilePacket) headersV1() map[string]string {
+	// starting index for first byte of first header's key in the TilePacket structure
+	offset := 37
+
+	headers := make(map[string]string)
+
+	for count := 0; count < t.lenHeadersV1(); count++ {
+		keyLen := binary.LittleEndian.Uint16(t[offset : offset+2])
+		offset += 2
+
+		key := string(t[offset : offset+int(keyLen)])
+		offset += int(keyLen)
+
+		valLen := binary.LittleEndian.Uint16(t[offset : offset+2])
+		offset += 2
+
+		val := string(t[offset : offset+int(valLen)])
+		offset += int(valLen)
+
+		headers[key] = val
+	}
+
+	return headers
+}
+
+// lenHeadersV1 returns the number of headers encoded in a version 1 TilePacket.
+// Reads the count byte (byte index 36) and returns the value as an integer
+func (t TilePacket) lenHeadersV1() int {
+	return int(t[36:37][0])
+}
@@ -0,0 +1,185 @@
+package packet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"time"
+)
+
+// offsets of the fixed size fields of a version 2 TilePacket
+const (
+	checksumV2 = 4                        // offset of the checksum, after the magic prefix
+	flagsV2    = checksumV2 + sha256.Size // offset of the flags, selecting the checksum algorithm and codec
+	fetchedV2  = flagsV2 + 1              // offset of the fetch time
+	expiresV2  = fetchedV2 + 8            // offset of the expiry time
+	statusV2   = expiresV2 + 8            // offset of the upstream status code
+	fieldsV2   = statusV2 + 2             // offset of the first variable length field
+)
+
+// layoutV2 is a parsed version 2 TilePacket
+type layoutV2 struct {
+	meta    Meta              // metadata of the tile
+	headers map[string]string // stored headers
+	data    int               // offset of the tile data
+	ok      bool              // whether the packet parsed without running out of bounds
+}
+
+// encodeV2 encodes tile data, headers and metadata into a version 2 TilePacket
+func encodeV2(tile []byte, headers map[string]string, meta Meta) TilePacket {
+	size := fieldsV2 + len(meta.ETag) + len(meta.LastModified) + len(meta.ContentHash) + len(tile) +
+		(4+2*len(headers))*binary.MaxVarintLen64
+	for key, val := range headers {
+		size += len(key) + len(val)
+	}
+
+	// fall back to SHA-256 for unknown checksum algorithms
+	algorithm := meta.Checksum
+	if algorithm > XXHash {
+		algorithm = SHA256
+	}
+
+	// store tile data uncompressed if compression fails or doesn't pay off
+	codec := meta.Codec
+	if codec != CodecNone {
+		compressed, err := codec.compress(tile)
+		if err != nil || len(compressed) >= len(tile) {
+			codec = CodecNone
+		} else {
+			tile = compressed
+		}
+	}
+
+	tilePacket := make(TilePacket, fieldsV2, size)
+	copy(tilePacket, magicV2)
+	tilePacket[flagsV2] = uint8(algorithm) | uint8(codec)<<codecShift
+
+	binary.LittleEndian.PutUint64(tilePacket[fetchedV2:], uint64(unixMilli(meta.Fetched)))
+	binary.LittleEndian.PutUint64(tilePacket[expiresV2:], uint64(unixMilli(meta.Expires)))
+	binary.LittleEndian.PutUint16(tilePacket[statusV2:], uint16(meta.Status))
+
+	tilePacket = appendField(tilePacket, []byte(meta.ETag))
+	tilePacket = appendField(tilePacket, []byte(meta.LastModified))
+	tilePacket = appendField(tilePacket, meta.ContentHash)
+
+	tilePacket = binary.AppendUvarint(tilePacket, uint64(len(headers)))
+	for key, val := range headers {
+		tilePacket = appendField(tilePacket, []byte(key))
+		tilePacket = appendField(tilePacket, []byte(val))
+	}
+
+	// append tile to packet after end of metadata
+	tilePacket = append(tilePacket, tile...)
+
+	// checksum everything after the checksum, leaving the magic prefix to
+	// select the layout
+	checksum, _ := algorithm.sum(tilePacket[flagsV2:])
+	copy(tilePacket[checksumV2:], checksum[:])
+
+	return tilePacket
+}
+
+// verifyV2 verifies a version 2 tile packet against the stored checksum
+func (t TilePacket) verifyV2() bool {
+	if len(t) < fieldsV2 {
+		return false
+	}
+
+	// the flags are covered by the checksum, so a corrupted algorithm fails too
+	checksum, ok := Checksum(t[flagsV2] & checksumMask).sum(t[flagsV2:])
+	return ok && bytes.Equal(checksum[:], t[checksumV2:flagsV2])
+}
+
+// parseV2 parses the metadata and headers of a version 2 TilePacket
+func (t TilePacket) parseV2() layoutV2 {
+	layout := layoutV2{headers: make(map[string]string), data: len(t)}
+	if len(t) < fieldsV2 {
+		return layout
+	}
+
+	layout.meta = Meta{
+		Fetched:  fromUnixMilli(int64(binary.LittleEndian.Uint64(t[fetchedV2:]))),
+		Expires:  fromUnixMilli(int64(binary.LittleEndian.Uint64(t[expiresV2:]))),
+		Status:   int(binary.LittleEndian.Uint16(t[statusV2:])),
+		Checksum: Checksum(t[flagsV2] & checksumMask),
+		Codec:    Codec(t[flagsV2] >> codecShift),
+	}
+	if layout.meta.Codec > CodecZstd {
+		return layout
+	}
+
+	offset := fieldsV2
+	etag, offset, ok := t.field(offset)
+	if !ok {
+		return layout
+	}
+	lastModified, offset, ok := t.field(offset)
+	if !ok {
+		return layout
+	}
+	contentHash, offset, ok := t.field(offset)
+	if !ok {
+		return layout
+	}
+
+	layout.meta.ETag = string(etag)
+	layout.meta.LastModified = string(lastModified)
+	if len(contentHash) > 0 {
+		layout.meta.ContentHash = contentHash
+	}
+
+	count, n := binary.Uvarint(t[offset:])
+	if n <= 0 {
+		return layout
+	}
+	offset += n
+
+	for i := uint64(0); i < count; i++ {
+		var key, val []byte
+		if key, offset, ok = t.field(offset); !ok {
+			return layout
+		}
+		if val, offset, ok = t.field(offset); !ok {
+			return layout
+		}
+		layout.headers[string(key)] = string(val)
+	}
+
+	layout.data = offset
+	layout.ok = true
+	return layout
+}
+
+// field reads a length-prefixed field at the offset, returning it along with
+// the offset following it, or false if it runs out of bounds
+func (t TilePacket) field(offset int) ([]byte, int, bool) {
+	length, n := binary.Uvarint(t[offset:])
+	if n <= 0 || length > uint64(len(t)-offset-n) {
+		return nil, offset, false
+	}
+
+	start := offset + n
+	return t[start : start+int(length)], start + int(length), true
+}
+
+// appendField appends a length-prefixed field to the packet
+func appendField(tilePacket TilePacket, field []byte) TilePacket {
+	tilePacket = binary.AppendUvarint(tilePacket, uint64(len(field)))
+	return append(tilePacket, field...)
+}
+
+// unixMilli returns the time in unix milliseconds, or 0 for the zero time
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+// fromUnixMilli returns the time at the unix milliseconds, or the zero time for 0
+func fromUnixMilli(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
@@ -0,0 +1,37 @@
+package s3
+
+import "fmt"
+
+// ErrInvalidEndpoint is an error struct for an object storage
+// endpoint that isn't an absolute URL
+type ErrInvalidEndpoint struct {
+	Endpoint string
+}
+
+// Error returns the string representation of ErrInvalidEndpoint
+func (e ErrInvalidEndpoint) Error() string {
+	return fmt.Sprintf("s3: invalid endpoint '%s', expected an absolute URL", e.Endpoint)
+}
+
+// ErrNotFound is an error struct returned when no object exists at a key
+type ErrNotFound struct {
+	Key string
+}
+
+// Error returns the string representation of ErrNotFound
+func (e ErrNotFound) Error() string {
+	return fmt.Sprintf("s3: no object found with key '%s'", e.Key)
+}
+
+// ErrRequest is an error struct for requests rejected by the object storage service
+type ErrRequest struct {
+	Method string
+	Key    string
+	Status string
+	Body   string
+}
+
+// Error returns the string representation of ErrRequest
+func (e ErrRequest) Error() string {
+	return fmt.Sprintf("s3: %s '%s' failed with status %s: %s", e.Method, e.Key, e.Status, e.Body)
+}
@@ -0,0 +1,252 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client is a minimal client for S3-compatible object storage, supporting
+// just the object operations LOD needs to use a bucket as a cache tier
+type Client struct {
+	endpoint  *url.URL     // base URL of the object storage service
+	bucket    string       // bucket objects are stored in
+	region    string       // region requests are signed for
+	accessKey string       // access key ID
+	secretKey string       // secret access key
+	pathStyle bool         // address the bucket in the path instead of the host
+	http      *http.Client // underlying HTTP client
+}
+
+// Options for creating a new Client
+type Options struct {
+	Endpoint  string        // base URL of the service, ex: https://s3.us-east-1.amazonaws.com
+	Bucket    string        // bucket objects are stored in
+	Region    string        // region requests are signed for, default us-east-1
+	AccessKey string        // access key ID
+	SecretKey string        // secret access key
+	PathStyle bool          // address the bucket in the path, required by most MinIO deployments
+	Timeout   time.Duration // timeout of each request, default 10s
+}
+
+// Object is the content and metadata of a stored object
+type Object struct {
+	Data     []byte            // object content
+	Metadata map[string]string // user metadata, keys lowercased without the x-amz-meta- prefix
+}
+
+// metaPrefix prefixes user metadata headers
+const metaPrefix = "X-Amz-Meta-"
+
+// New creates a new Client from the given options
+func New(opts Options) (*Client, error) {
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, ErrInvalidEndpoint{Endpoint: opts.Endpoint}
+	}
+
+	region := opts.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &Client{
+		endpoint:  endpoint,
+		bucket:    opts.Bucket,
+		region:    region,
+		accessKey: opts.AccessKey,
+		secretKey: opts.SecretKey,
+		pathStyle: opts.PathStyle,
+		http:      &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Ping verifies that the bucket exists and is accessible with the
+// configured credentials
+func (c *Client) Ping(ctx context.Context) error {
+	res, err := c.do(ctx, http.MethodHead, "", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// Get returns the object stored at key, or ErrNotFound if there is none
+func (c *Client) Get(ctx context.Context, key string) (*Object, error) {
+	res, err := c.do(ctx, http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := make(map[string]string)
+	for name := range res.Header {
+		if strings.HasPrefix(name, metaPrefix) {
+			metadata[strings.ToLower(strings.TrimPrefix(name, metaPrefix))] = res.Header.Get(name)
+		}
+	}
+
+	return &Object{
+		Data:     data,
+		Metadata: metadata,
+	}, nil
+}
+
+// Put stores data at key with the given user metadata
+func (c *Client) Put(ctx context.Context, key string, data []byte, metadata map[string]string) error {
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/octet-stream")
+	for name, value := range metadata {
+		headers.Set(metaPrefix+name, value)
+	}
+
+	res, err := c.do(ctx, http.MethodPut, key, nil, headers, data)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// Delete removes the object stored at key, succeeding if there is none
+func (c *Client) Delete(ctx context.Context, key string) error {
+	res, err := c.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		if _, ok := err.(ErrNotFound); ok {
+			return nil
+		}
+		return err
+	}
+	return res.Body.Close()
+}
+
+// listResult is the response body of a ListObjectsV2 request
+type listResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List calls fn with each page of object keys starting with prefix until
+// all keys are listed or fn returns an error
+func (c *Client) List(ctx context.Context, prefix string, fn func(keys []string) error) error {
+	token := ""
+
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		res, err := c.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		var result listResult
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		_ = res.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(result.Contents))
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+
+		if err = fn(keys); err != nil {
+			return err
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// do sends a signed request for the given object key, or the bucket itself
+// if no key is given, returning an error for any non-2xx response
+func (c *Client) do(ctx context.Context, method, key string, query url.Values,
+	headers http.Header, body []byte) (*http.Response, error) {
+	target := *c.endpoint
+	path := strings.TrimSuffix(target.Path, "/")
+
+	if c.pathStyle {
+		path += "/" + c.bucket
+	} else {
+		target.Host = c.bucket + "." + target.Host
+	}
+
+	if key != "" || !c.pathStyle {
+		path += "/" + key
+	}
+	target.Path = path
+	target.RawPath = escape(path, false)
+	target.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+
+	payloadHash := emptyHash
+	if len(body) > 0 {
+		payloadHash = hashHex(body)
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.ContentLength = int64(len(body))
+
+	sign(req, c.accessKey, c.secretKey, c.region, time.Now())
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return res, nil
+	}
+
+	defer res.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound{Key: key}
+	}
+
+	return nil, ErrRequest{
+		Method: method,
+		Key:    key,
+		Status: strconv.Itoa(res.StatusCode),
+		Body:   string(message),
+	}
+}
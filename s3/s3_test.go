@@ -0,0 +1,86 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestClient will test storing, fetching and deleting objects against a
+// minimal in-memory object storage server
+func TestClient(t *testing.T) {
+	var lock sync.Mutex
+	objects := make(map[string][]byte)
+	metadata := make(map[string]string)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if !strings.HasPrefix(r.Header.Get("Authorization"), signingAlgorithm) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path], _ = io.ReadAll(r.Body)
+			metadata[r.URL.Path] = r.Header.Get(metaPrefix + "Expires")
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set(metaPrefix+"Expires", metadata[r.URL.Path])
+			_, _ = w.Write(data)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	client, err := New(Options{
+		Endpoint:  server.URL,
+		Bucket:    "tiles",
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create client, error=%s", err.Error())
+	}
+
+	ctx := context.Background()
+	key := "osm/basemap:4:3:5"
+
+	if err = client.Put(ctx, key, []byte("tile"), map[string]string{"expires": "42"}); err != nil {
+		t.Fatalf("failed to put object, error=%s", err.Error())
+	}
+
+	if _, ok := objects["/tiles/"+key]; !ok {
+		t.Fatalf("expected object at path-style key, got %v", objects)
+	}
+
+	object, err := client.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("failed to get object, error=%s", err.Error())
+	}
+
+	if string(object.Data) != "tile" || object.Metadata["expires"] != "42" {
+		t.Errorf("unexpected object %+v", object)
+	}
+
+	if err = client.Delete(ctx, key); err != nil {
+		t.Fatalf("failed to delete object, error=%s", err.Error())
+	}
+
+	if _, err = client.Get(ctx, key); err == nil {
+		t.Errorf("expected deleted object to be missing")
+	} else if _, ok := err.(ErrNotFound); !ok {
+		t.Errorf("expected ErrNotFound, got %s", err.Error())
+	}
+}
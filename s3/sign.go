@@ -0,0 +1,149 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// signingAlgorithm is the AWS Signature Version 4 algorithm identifier
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	// amzDateFormat is the timestamp format used by Signature Version 4
+	amzDateFormat = "20060102T150405Z"
+	// service is the name of the service requests are signed for
+	service = "s3"
+)
+
+// emptyHash is the SHA256 hash of an empty payload
+var emptyHash = hashHex(nil)
+
+// sign adds AWS Signature Version 4 authentication to the request. The
+// request must already carry its x-amz-content-sha256 header, and every
+// header set on the request at signing time is included in the signature.
+func sign(req *http.Request, accessKey, secretKey, region string, now time.Time) {
+	amzDate := now.UTC().Format(amzDateFormat)
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers, signedHeaders := canonicalHeaders(req)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escape(req.URL.Path, false),
+		canonicalQuery(req),
+		headers,
+		signedHeaders,
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+
+	stringToSign := strings.Join([]string{
+		signingAlgorithm,
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signingAlgorithm, accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalHeaders returns the canonical header block and the signed
+// header list of the request, always including the host header
+func canonicalHeaders(req *http.Request) (string, string) {
+	values := map[string]string{
+		"host": req.URL.Host,
+	}
+
+	for name, value := range req.Header {
+		values[strings.ToLower(name)] = strings.TrimSpace(strings.Join(value, ","))
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var headers strings.Builder
+	for _, name := range names {
+		headers.WriteString(name)
+		headers.WriteByte(':')
+		headers.WriteString(values[name])
+		headers.WriteByte('\n')
+	}
+
+	return headers.String(), strings.Join(names, ";")
+}
+
+// canonicalQuery returns the request's query string with keys sorted and
+// keys and values escaped as Signature Version 4 requires
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, escape(key, true)+"="+escape(value, true))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// escape URI-encodes every byte except unreserved characters, and slashes
+// unless encodeSlash is set, as Signature Version 4 requires
+func escape(value string, encodeSlash bool) string {
+	var escaped strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			escaped.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			escaped.WriteByte(c)
+		default:
+			escaped.WriteString(fmt.Sprintf("%%%02X", c))
+		}
+	}
+
+	return escaped.String()
+}
+
+// hashHex returns the hex encoded SHA256 hash of data
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data using the given key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
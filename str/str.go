@@ -40,11 +40,14 @@ const (
 
 // (C) Log caller names
 const (
-	CMain  = "LOD"
-	CLog   = "LOG"
-	CProxy = "PRX"
-	CCache = "CCH"
-	CAdmin = "ADM"
+	CMain    = "LOD"
+	CLog     = "LOG"
+	CProxy   = "PRX"
+	CCache   = "CCH"
+	CAdmin   = "ADM"
+	CJobs    = "JOB"
+	CCluster = "CLU"
+	CArchive = "ARC"
 )
 
 // (E) Error messages
@@ -59,6 +62,13 @@ const (
 	ECacheDelete        = "failed to delete tile from cache, key=%s error=%s"
 	ECacheSet           = "failed to set cache entry, key=%s error=%s"
 	ECacheFlush         = "failed to flush cache, name=%s error=%s"
+	ERedisClose         = "failed to close shared redis connection, error=%s"
+	ECacheGeneration    = "failed to read cache generation name=%s error=%s"
+	ECacheTag           = "failed to index cache entry by tag, key=%s tag=%s error=%s"
+	ECacheClose         = "failed to close %s cache, error=%s"
+	ECacheHot           = "failed to record popular cache keys, name=%s error=%s"
+	ECacheHeat          = "failed to record tile heatmap, name=%s error=%s"
+	EWarmup             = "failed to warm cache, name=%s error=%s"
 	EProxyAgentError    = "proxy[%s]: agent request failed (%s): %s"
 	EProxyBadCast       = "proxy[%s]: agent response invalid (%s): check the configuration"
 	EProxyWrite         = "proxy[%s]: failed to write response (%s): %s"
@@ -66,9 +76,25 @@ const (
 	EInvalidateTile     = "failed to invalidate tile %s error=%s"
 	EPrimeTileDeep      = "failed to prime tile %s with depth error=%s"
 	EPrimeTile          = "failed to prime tile %s error=%s"
+	EInvalidateTag      = "failed to invalidate tiles tagged %s error=%s"
+	EDeleteKeys         = "failed to delete keys of %s matching %s error=%s"
+	EHeatmap            = "failed to read tile heatmap of %s error=%s"
+	EBumpGeneration     = "failed to bump cache generation name=%s error=%s"
+	EExport             = "failed to export %s cache of %s, resume from cursor '%s', error=%s"
+	EImport             = "failed to import into %s cache of %s, resume by skipping %d records, error=%s"
+	ELocalLayer         = "the %s cache level is local to the lod process, use the admin endpoints to transfer it"
 	EWrite              = "write err: error=%s meta=%+v"
 	EReload             = "failed to reload instance capabilities, error=%s"
 	ERequest            = "generic uncaught error in request chain, ctx=%s error=%s"
+	EScheduleRun        = "schedule %s/%s failed, error=%s"
+	EScheduleNever      = "schedule %s/%s will never run, cron=%s"
+	EScheduleLock       = "failed to lock schedule %s/%s, running anyway, error=%s"
+	EClusterDecode      = "failed to decode cluster message, error=%s"
+	EClusterApply       = "failed to apply cluster %s from %s, error=%s"
+	EClusterAck         = "failed to acknowledge cluster command %s, error=%s"
+	EClusterTimeout     = "cluster %s timed out waiting for %d acknowledgements"
+	EClusterRegister    = "failed to register instance in cluster, error=%s"
+	EClusterPeer        = "failed cluster request to peer %s, error=%s"
 )
 
 // (U) User-facing error messages and codes
@@ -79,27 +105,49 @@ const (
 	MDevMode            = "!! DEVELOPER MODE !!"
 	MInit               = "LOD v%s - copyright 2021-2022 Andrew DeChristopher <me@dchr.host>\n"
 	MStarted            = "started in %s [env: %s][http: %d]"
-	MProxy              = "configured proxy [mem: %t / redis: %t][%s] -> %s"
+	MProxy              = "configured proxy [mem: %t / disk: %t / redis: %t / memcached: %t / s3: %t][%s] -> %s"
 	MReload             = "reloaded instance capabilities"
 	MOldCacheDeleted    = "old cache instance '%s' removed"
+	MCacheGeneration    = "bumped cache generation name=%s endpoint=%s generation=%s"
 	MInvalidateTile     = "invalidated tile %s with no depth (%d) (%d tiles)"
 	MInvalidateTileDeep = "invalidated tile %s with depth %d (%d tiles)"
+	MInvalidateTag      = "invalidated tiles tagged %s (%d tiles)"
+	MDeleteKeys         = "deleted keys of %s matching %s (%d keys)"
+	MExportProgress     = "exporting %s cache of %s: %d entries, next cursor '%s'"
+	MExport             = "exported %s cache of %s: %d entries"
+	MImportProgress     = "importing into %s cache of %s: %d entries, next record %d"
+	MImport             = "imported into %s cache of %s: %d entries, %d expired"
+	MWarmup             = "warmed cache %s in %s (attempted: %d, warmed: %d, fetched: %d)"
+	MWarmupDone         = "warmup complete in %s"
 	MPrimeTile          = "primed tile %s with no depth (%d) (%d tiles)"
 	MPrimeTileDeep      = "primed tile %s with depth %d (%d tiles)"
+	MScheduleRun        = "schedule %s/%s ran in %s (attempted: %d, succeeded: %d, changed: %d)"
+	MClusterJoined      = "joined cluster as %s on channel %s"
+	MClusterApplied     = "applied cluster %s [%s] from %s"
+	MClusterBroadcast   = "broadcast cluster %s [%s], %d acknowledgements"
 	MShutdown           = "shutting down"
 	MExit               = "exit"
 )
 
 // (D) Debug log messages
 const (
-	DCacheUp        = "cache online name=%s"
-	DCacheSet       = "cache set key=%s len=%d"
-	DCacheMiss      = "cache internal miss key=%s"
-	DCacheMissExt   = "cache external miss key=%s"
-	DCacheHit       = "cache hit key=%s len=%d"
-	DCalcTiles      = "admin: proxy %s: depth search found %d tiles from via %s to depth %d"
-	DPrimeFail      = "failed to prime tile %s, err=%s"
-	DInvalidateFail = "failed to invalidate tile %s, err=%s"
+	DCacheUp           = "cache online name=%s"
+	DCacheSet          = "cache set key=%s len=%d"
+	DCacheMissLayer    = "cache %s miss key=%s"
+	DCacheMiss         = "cache miss key=%s"
+	DCacheTagIndexFull = "cache tag index full, not indexing key=%s"
+	DCacheHit          = "cache hit key=%s len=%d"
+	DCacheExpired      = "cache expired key=%s"
+	DCacheUncacheable  = "cache skipped uncacheable tile key=%s"
+	DCacheNoGeneration = "cache bypassed without a known generation url=%s"
+	DRedisConnect      = "redis connection opened name=%s mode=%s"
+	DMemcachedSkip     = "memcached skipped oversized tile key=%s len=%d"
+	DCalcTiles         = "admin: proxy %s: depth search found %d tiles from via %s to depth %d"
+	DPrimeFail         = "failed to prime tile %s, err=%s"
+	DInvalidateFail    = "failed to invalidate tile %s, err=%s"
+	DWarmupFail        = "failed to warm key %s, err=%s"
+	DScheduleNext      = "schedule %s/%s next run at %s"
+	DScheduleLocked    = "schedule %s/%s run at %s is handled by another instance"
 )
 
 // (T) Test messages
@@ -109,6 +157,8 @@ const (
 	TCacheBadTileData   = "tile data not properly encoded into tile packet"
 	TCacheBadValidation = "tile data corrupted, checksum failed"
 	TCacheBadDecode     = "tile decode failed, error=%s"
+	TCacheBadVersion    = "tile packet version mismatch, got=%d expected=%d"
+	TCacheBadMeta       = "tile metadata not properly encoded into tile packet, got=%+v expected=%+v"
 )
 
 // Help message
@@ -121,3 +171,21 @@ Flags:
 Usage:
   lod [--conf config.toml] [--dev]
 `
+
+// HelpCacheTool is the help message of the cache archive tool
+const HelpCacheTool = `
+Commands:
+  export  Export a proxy's cache level to a cache archive
+  import  Import a cache archive into a proxy's cache level
+Flags:
+  --conf   Path/URL to TOML configuration file. Default: config.toml
+  --proxy  Name of the proxy whose cache to export or import.
+  --layer  Cache level to export from or import into, other than memory.
+           Default: redis
+  --file   Path of the cache archive.
+  --resume Resume an interrupted export of the archive.
+  --skip   Number of archive records to skip when resuming an import.
+Usage:
+  lodcache export --proxy tiles --layer redis --file tiles.lodarc [--resume]
+  lodcache import --proxy tiles --layer s3 --file tiles.lodarc [--skip 0]
+`
@@ -102,6 +102,7 @@ func (t Tile) Children() [4]Tile {
 func (t Tile) DeepChildren(maxZoom int) []Tile {
 	tileChan := make(chan Tile)
 	tiles := make([]Tile, 0)
+	done := make(chan struct{})
 
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
@@ -111,6 +112,7 @@ func (t Tile) DeepChildren(maxZoom int) []Tile {
 		for tile := range tileChan {
 			tiles = append(tiles, tile)
 		}
+		close(done)
 	}()
 
 	// begin async deepening algorithm to compute children
@@ -119,6 +121,9 @@ func (t Tile) DeepChildren(maxZoom int) []Tile {
 	wg.Wait()
 	close(tileChan)
 
+	// wait for the final children to be appended
+	<-done
+
 	return tiles
 }
 
@@ -142,7 +147,8 @@ func deepChildrenImpl(maxZoom int, tile Tile, tileChan chan Tile, wg *sync.WaitG
 }
 
 // Bounds calculates bounding box of the given tile based
-// on the tile's X and Y value and zoom level
+// on the tile's X and Y value and zoom level. X values are
+// longitudes and Y values are latitudes.
 func (t Tile) Bounds() *geos.Bounds {
 	// get northwest corner of current tile
 	nwLat, nwLon := getCorner(t.XFloat(), t.YFloat(), t.ZoomFloat())
@@ -150,13 +156,13 @@ func (t Tile) Bounds() *geos.Bounds {
 	// which gives us the southeast corner of current tile
 	seLat, seLon := getCorner(t.XFloat()+1, t.YFloat()+1, t.ZoomFloat())
 
-	bounds := geos.NewBounds(nwLat, nwLon, seLat, seLon)
+	bounds := geos.NewBounds(nwLon, seLat, seLon, nwLat)
 	return bounds
 }
 
 // DeepIntersect emits, to the given channel, all tiles that a given geometry
-// intersects at all zoom levels, starting at the tile provided
-func DeepIntersect(geometry *geos.Geom, tile Tile, tileChan chan Tile, wg *sync.WaitGroup) {
+// intersects up to the given zoom level, starting at the tile provided
+func DeepIntersect(geometry *geos.Geom, maxZoom int, tile Tile, tileChan chan Tile, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	box := tile.Bounds()
@@ -165,13 +171,12 @@ func DeepIntersect(geometry *geos.Geom, tile Tile, tileChan chan Tile, wg *sync.
 	intersects := geometry.Intersects(boxPolygon)
 
 	if !intersects {
-		// fmt.Printf("[%d] does not intersect at tile %+v\n", tile.Zoom, tile)
 		return
 	}
 
 	tileChan <- tile
 
-	if tile.Zoom == 16 {
+	if tile.Zoom >= maxZoom {
 		return
 	}
 
@@ -179,8 +184,89 @@ func DeepIntersect(geometry *geos.Geom, tile Tile, tileChan chan Tile, wg *sync.
 	wg.Add(4)
 
 	for _, childTile := range children {
-		go DeepIntersect(geometry, childTile, tileChan, wg)
+		go DeepIntersect(geometry, maxZoom, childTile, tileChan, wg)
+	}
+}
+
+// Intersecting returns a list of all tiles up to the given zoom level that
+// the given geometry intersects, starting from the root tile
+func Intersecting(geometry *geos.Geom, maxZoom int) []Tile {
+	tileChan := make(chan Tile)
+	tiles := make([]Tile, 0)
+	done := make(chan struct{})
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+
+	// append intersecting tiles to tiles list as we receive them
+	go func() {
+		for tile := range tileChan {
+			tiles = append(tiles, tile)
+		}
+		close(done)
+	}()
+
+	go DeepIntersect(geometry, maxZoom, Tile{}, tileChan, wg)
+
+	wg.Wait()
+	close(tileChan)
+	<-done
+
+	return tiles
+}
+
+// FromLonLat returns the tile at the given zoom level that contains
+// the given longitude and latitude
+func FromLonLat(lon, lat float64, zoom int) Tile {
+	n := math.Exp2(float64(zoom))
+	max := int(n) - 1
+
+	// clamp latitude to the limits of the web mercator projection
+	lat = math.Max(math.Min(lat, maxLatitude), -maxLatitude)
+	latRad := lat * math.Pi / 180
+
+	x := int(math.Floor((lon + 180.0) / 360.0 * n))
+	y := int(math.Floor((1.0 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2.0 * n))
+
+	return Tile{
+		X:    clamp(x, 0, max),
+		Y:    clamp(y, 0, max),
+		Zoom: zoom,
+	}
+}
+
+// InBounds returns a list of all tiles between the given zoom levels that
+// intersect the given bounding box of longitudes and latitudes
+func InBounds(minLon, minLat, maxLon, maxLat float64, minZoom, maxZoom int) []Tile {
+	tiles := make([]Tile, 0)
+
+	for zoom := minZoom; zoom <= maxZoom; zoom++ {
+		// tile Y values grow southward, so the northwest corner has the minimum Y
+		nw := FromLonLat(minLon, maxLat, zoom)
+		se := FromLonLat(maxLon, minLat, zoom)
+
+		for x := nw.X; x <= se.X; x++ {
+			for y := nw.Y; y <= se.Y; y++ {
+				tiles = append(tiles, Tile{X: x, Y: y, Zoom: zoom})
+			}
+		}
+	}
+
+	return tiles
+}
+
+// maxLatitude is the northern and southern limit of the web mercator projection
+const maxLatitude = 85.05112878
+
+// clamp an integer value between min and max
+func clamp(val, min, max int) int {
+	if val < min {
+		return min
+	}
+	if val > max {
+		return max
 	}
+	return val
 }
 
 // getCorners calculates the NW corner of a given tile
@@ -1,12 +1,13 @@
 package admin
 
 import (
-	"sync"
-
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/cluster"
+	"github.com/dechristopher/lod/config"
 	"github.com/dechristopher/lod/helpers"
+	"github.com/dechristopher/lod/jobs"
 	"github.com/dechristopher/lod/str"
 	"github.com/dechristopher/lod/tile"
 	"github.com/dechristopher/lod/util"
@@ -32,7 +33,7 @@ func InvalidateAndPrime(ctx *fiber.Ctx, payload invalidateAndPrimePayload) error
 	}
 
 	// fill params map to augment param segmentation behavior present in proxy endpoint
-	helpers.FillParamsMap(*c.Proxy, ctx)
+	helpers.FillParamsMap(*c.Proxy(), ctx)
 
 	// get requested reqTile from context
 	reqTile, err := tile.Get(ctx)
@@ -59,137 +60,53 @@ func InvalidateAndPrime(ctx *fiber.Ctx, payload invalidateAndPrimePayload) error
 	// calculate all necessary tiles for this operation
 	tiles := reqTile.DeepChildren(maxZoom)
 
-	util.Debug(str.CAdmin, str.DCalcTiles, c.Proxy.Name,
+	util.Debug(str.CAdmin, str.DCalcTiles, c.Proxy().Name,
 		len(tiles), reqTile.String(), maxZoom)
 
-	succeeded := 0
-
-	if !payload.Prime {
-		// simply invalidate en masse
-		for _, tileToInvalidate := range tiles {
-			key, errKey := helpers.BuildCacheKey(*c.Proxy, ctx, tileToInvalidate)
-			if errKey != nil {
-				util.Debug(str.CAdmin, str.DInvalidateFail, tileToInvalidate.String(), err.Error())
-				continue
-			}
-			errInv := c.Invalidate(key, ctx.Context())
-			if errInv != nil {
-				util.Debug(str.CAdmin, str.DInvalidateFail, tileToInvalidate.String(), errInv)
-			}
-			succeeded++
-		}
-	} else {
-		// fetch and prime in place for the given tile to avoid invalidating tiles
-		// en masse and having missing tiles in the cache during the priming period
-		wg := &sync.WaitGroup{}
-		wg.Add(c.Proxy.NumWorkers)
-
-		jobs := make(chan tile.Tile, len(tiles))
-		successes := make(chan bool, len(tiles))
-
-		// spin up workers to make agent-proxied requests to the upstream
-		for numWorkers := 0; numWorkers < c.Proxy.NumWorkers; numWorkers++ {
-			go tileWorker(tileWorkerPayload{
-				jobs:      jobs,
-				successes: successes,
-				cache:     c,
-				ctx:       ctx,
-				waitGroup: wg,
-			})
-		}
-
-		// submit jobs to workers
-		for _, tileJob := range tiles {
-			jobs <- tileJob
-		}
-
-		// signal that we're out of tiles to prime
-		close(jobs)
-
-		// wait until workers finish
-		wg.Wait()
-
-		// close successes channel after workers finish
-		close(successes)
-
-		// count successfully primed tiles
-		for range successes {
-			succeeded++
-		}
+	mode := config.ScheduleModeInvalidate
+	if payload.Prime {
+		mode = config.ScheduleModePrime
 	}
 
+	// run the job using the dynamic endpoint and parameters of this request
+	result := jobs.Run(ctx.Context(), jobs.Job{
+		Cache:    c,
+		Tiles:    tiles,
+		Mode:     mode,
+		Endpoint: ctx.Params(str.ParamEndpoint),
+		Params:   helpers.GetParamsFromCtx(ctx),
+	})
+
 	status := "ok"
-	if succeeded != len(tiles) {
+	if result.Succeeded != result.Attempted {
 		status = "failed"
 	}
 
 	util.Info(str.CAdmin, payload.InfoMessage, reqTile.String(), maxZoom, len(tiles))
-	return ctx.JSON(map[string]interface{}{
-		"attempted": len(tiles),
-		"primed":    succeeded,
+	response := map[string]interface{}{
+		"attempted": result.Attempted,
+		"primed":    result.Succeeded,
 		"status":    status,
-	})
-}
-
-// tileWorkerPayload is a struct containing all the ingredients
-// needed for a tileWorker to operate on its job queue
-type tileWorkerPayload struct {
-	jobs      <-chan tile.Tile
-	successes chan<- bool
-	cache     *cache.Cache
-	ctx       *fiber.Ctx
-	waitGroup *sync.WaitGroup
-}
-
-// tileWorker is a worker function that's spun up during requests to prime and
-// invalidate batches of tiles
-func tileWorker(payload tileWorkerPayload) {
-	defer payload.waitGroup.Done()
-
-	for tileJob := range payload.jobs {
-		url, err := helpers.BuildTileUrl(*payload.cache.Proxy, payload.ctx, tileJob)
-		if err != nil {
-			util.Debug(str.CAdmin, str.DPrimeFail, tileJob.String(), err.Error())
-			continue
-		}
-
-		cacheKey, err := helpers.BuildCacheKey(*payload.cache.Proxy, payload.ctx, tileJob)
-		if err != nil {
-			util.Debug(str.CAdmin, str.DPrimeFail, tileJob.String(), err.Error())
-			continue
-		}
-
-		response, errProxy := helpers.FetchUpstream(url, *payload.cache.Proxy)()
-		if errProxy != nil {
-			util.Debug(str.CAdmin, str.DPrimeFail, tileJob.String(), err.Error())
-			continue
-		}
-
-		// cast interface returned from flight group to a proxyResponse
-		proxyResp, ok := response.(helpers.ProxyResponse)
-
-		// sanity check to ensure cast worked properly
-		if !ok {
-			util.Debug(str.CAdmin, str.DPrimeFail, tileJob.String(), err.Error())
-			continue
-		}
+	}
 
-		// write reqTile data and headers and cache result
-		if err = helpers.ProcessResponse(helpers.ProcessResponsePayload{
-			Ctx:       payload.ctx,
-			Cache:     payload.cache,
-			Proxy:     *payload.cache.Proxy,
-			CacheKey:  cacheKey,
-			Response:  proxyResp,
-			WriteData: true,
-		}); err != nil {
-			util.DebugFlag("primer", str.CAdmin, str.DPrimeFail, tileJob.String(), err.Error())
-			return
+	// apply the same operation across the cluster if enabled
+	if cluster.Enabled() {
+		op := cluster.OpInvalidate
+		if payload.Prime {
+			op = cluster.OpPrime
 		}
 
-		// signal successful tile
-		payload.successes <- true
+		response["cluster"] = cluster.Broadcast(ctx.Context(), cluster.Command{
+			Op:       op,
+			Proxy:    c.Proxy().Name,
+			Tile:     *reqTile,
+			MaxZoom:  maxZoom,
+			Endpoint: ctx.Params(str.ParamEndpoint),
+			Params:   helpers.GetParamsFromCtx(ctx),
+		})
 	}
+
+	return ctx.JSON(response)
 }
 
 // InvalidateTile will invalidate a tile from the caches if it exists
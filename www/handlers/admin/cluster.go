@@ -0,0 +1,205 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/dechristopher/lod/cluster"
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/util"
+)
+
+// peerView is a cluster member alongside its live status and stats
+type peerView struct {
+	cluster.Member
+	Self   bool        `json:"self"`            // whether this member is the instance serving the request
+	Status interface{} `json:"status"`          // the member's /admin/status response
+	Stats  interface{} `json:"stats"`           // the member's /admin/stats response
+	Error  string      `json:"error,omitempty"` // error encountered while querying the member
+}
+
+// peerResponse is the outcome of an admin request forwarded to a peer
+type peerResponse struct {
+	Code  int         `json:"code"`            // HTTP status code returned by the peer
+	Body  interface{} `json:"body,omitempty"`  // response body returned by the peer
+	Error string      `json:"error,omitempty"` // error encountered while making the request
+}
+
+// Cluster lists all live cluster members with their status and stats
+func Cluster(ctx *fiber.Ctx) error {
+	members, err := cluster.Members(ctx.Context())
+	if err != nil {
+		return clusterError(ctx, err)
+	}
+
+	peers := make([]peerView, len(members))
+	wg := &sync.WaitGroup{}
+
+	for i := range members {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			peers[i] = peerView{
+				Member: members[i],
+				Self:   members[i].ID == cluster.InstanceID,
+			}
+
+			status := peerRequest(members[i], "/status")
+			stats := peerRequest(members[i], "/stats")
+			peers[i].Status = status.Body
+			peers[i].Stats = stats.Body
+
+			if status.Error != "" {
+				peers[i].Error = status.Error
+			} else if stats.Error != "" {
+				peers[i].Error = stats.Error
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	return ctx.JSON(map[string]interface{}{
+		"instance": cluster.InstanceID,
+		"peers":    peers,
+	})
+}
+
+// ClusterFanout forwards an admin request to every live cluster member
+func ClusterFanout(ctx *fiber.Ctx) error {
+	path, err := forwardedPath(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(map[string]string{
+			"status": "failed",
+			"error":  err.Error(),
+		})
+	}
+
+	members, err := cluster.Members(ctx.Context())
+	if err != nil {
+		return clusterError(ctx, err)
+	}
+
+	responses := make(map[string]peerResponse, len(members))
+	lock := sync.Mutex{}
+	wg := &sync.WaitGroup{}
+
+	for _, member := range members {
+		wg.Add(1)
+		go func(member cluster.Member) {
+			defer wg.Done()
+			response := peerRequest(member, path)
+
+			lock.Lock()
+			responses[member.ID] = response
+			lock.Unlock()
+		}(member)
+	}
+
+	wg.Wait()
+
+	return ctx.JSON(map[string]interface{}{
+		"instance":  cluster.InstanceID,
+		"responses": responses,
+	})
+}
+
+// ClusterPeer forwards an admin request to a single cluster member by ID
+func ClusterPeer(ctx *fiber.Ctx) error {
+	path, err := forwardedPath(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(map[string]string{
+			"status": "failed",
+			"error":  err.Error(),
+		})
+	}
+
+	member, err := cluster.GetMember(ctx.Context(), ctx.Params("id"))
+	if err != nil {
+		return clusterError(ctx, err)
+	}
+
+	if member == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(map[string]string{
+			"status": "no live cluster member with given id",
+		})
+	}
+
+	return ctx.JSON(map[string]interface{}{
+		"instance":  cluster.InstanceID,
+		"responses": map[string]peerResponse{member.ID: peerRequest(*member, path)},
+	})
+}
+
+// forwardedPath returns the admin path and query string to forward to peers,
+// refusing to forward cluster requests to prevent endless fan-out loops
+func forwardedPath(ctx *fiber.Ctx) (string, error) {
+	path := "/" + strings.TrimPrefix(ctx.Params("*"), "/")
+	if path == "/" || strings.HasPrefix(path, "/cluster") {
+		return "", fmt.Errorf("invalid admin path to forward '%s'", path)
+	}
+
+	if query := string(ctx.Request().URI().QueryString()); query != "" {
+		path += "?" + query
+	}
+
+	return path, nil
+}
+
+// peerRequest makes an authenticated admin request to a cluster member
+func peerRequest(member cluster.Member, path string) peerResponse {
+	agent := fiber.AcquireAgent()
+	agent.Timeout(config.Get().Instance.Cluster.PeerTimeoutDuration)
+
+	req := agent.Request()
+	req.Header.SetMethod(fiber.MethodGet)
+	req.SetRequestURI(strings.TrimSuffix(member.Address, "/") + "/admin" + path)
+
+	if token := config.Get().Instance.AdminToken; token != "" {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	}
+
+	if err := agent.Parse(); err != nil {
+		fiber.ReleaseAgent(agent)
+		util.Error(str.CCluster, str.EClusterPeer, member.ID, err.Error())
+		return peerResponse{Error: err.Error()}
+	}
+
+	code, body, errs := agent.Bytes()
+	if len(errs) > 0 {
+		util.Error(str.CCluster, str.EClusterPeer, member.ID, errs[0].Error())
+		return peerResponse{Code: code, Error: errs[0].Error()}
+	}
+
+	response := peerResponse{Code: code}
+
+	// embed JSON responses as-is, anything else as a string
+	var decoded interface{}
+	if json.Unmarshal(body, &decoded) == nil {
+		response.Body = decoded
+	} else if len(body) > 0 {
+		response.Body = string(body)
+	}
+
+	return response
+}
+
+// clusterError responds with the given cluster error, using a 503
+// status if this instance is not connected to a cluster
+func clusterError(ctx *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	if _, ok := err.(cluster.ErrNotConnected); ok {
+		status = fiber.StatusServiceUnavailable
+	}
+
+	return ctx.Status(status).JSON(map[string]string{
+		"status": "failed",
+		"error":  err.Error(),
+	})
+}
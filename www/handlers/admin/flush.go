@@ -4,17 +4,20 @@ import (
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/cluster"
 	"github.com/dechristopher/lod/config"
 	"github.com/dechristopher/lod/str"
 	"github.com/dechristopher/lod/util"
 )
 
-// Flush an entire proxy cache by name, or all caches
+// Flush an entire proxy cache by name, or all caches. Only the in-memory caches
+// are flushed unless ?external=true is provided, which also deletes all of the
+// proxy's keys from Redis across every shard.
 func Flush(ctx *fiber.Ctx) error {
 	if ctx.Path() == "/admin/flush" {
 		// flush all configured proxies
 		for _, proxy := range config.Get().Proxies {
-			err := cache.Get(proxy.Name).FlushInternal()
+			err := flushProxy(ctx, proxy.Name)
 
 			if err != nil {
 				util.Error(str.CAdmin, str.ECacheFlush, proxy.Name, err.Error())
@@ -25,9 +28,7 @@ func Flush(ctx *fiber.Ctx) error {
 			}
 		}
 
-		return ctx.JSON(map[string]string{
-			"status": "ok",
-		})
+		return ctx.JSON(flushResponse(ctx, ""))
 	}
 
 	name := ctx.Locals(str.LocalCacheName).(string)
@@ -42,7 +43,7 @@ func Flush(ctx *fiber.Ctx) error {
 	for _, proxy := range config.Get().Proxies {
 		if proxy.Name == name {
 			// flush the proxy's internal cache
-			err := cache.Get(proxy.Name).FlushInternal()
+			err := flushProxy(ctx, proxy.Name)
 
 			if err != nil {
 				util.Error(str.CAdmin, str.ECacheFlush, name, err.Error())
@@ -52,9 +53,7 @@ func Flush(ctx *fiber.Ctx) error {
 				})
 			}
 
-			return ctx.JSON(map[string]string{
-				"status": "ok",
-			})
+			return ctx.JSON(flushResponse(ctx, name))
 		}
 	}
 
@@ -63,3 +62,36 @@ func Flush(ctx *fiber.Ctx) error {
 		"status": "no proxy configured with given name",
 	})
 }
+
+// flushProxy flushes the named proxy's in-memory cache and, if requested,
+// its external cache
+func flushProxy(ctx *fiber.Ctx, name string) error {
+	c := cache.Get(name)
+
+	if err := c.FlushInternal(); err != nil {
+		return err
+	}
+
+	if ctx.QueryBool("external") {
+		return c.FlushExternal(ctx.Context())
+	}
+
+	return nil
+}
+
+// flushResponse builds the response to a successful flush, broadcasting
+// the flush to all cluster peers if the cluster is enabled
+func flushResponse(ctx *fiber.Ctx, name string) map[string]interface{} {
+	response := map[string]interface{}{
+		"status": "ok",
+	}
+
+	if cluster.Enabled() {
+		response["cluster"] = cluster.Broadcast(ctx.Context(), cluster.Command{
+			Op:    cluster.OpFlush,
+			Proxy: name,
+		})
+	}
+
+	return response
+}
@@ -0,0 +1,54 @@
+package admin
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/cluster"
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/util"
+)
+
+// BumpGeneration increments the namespace generation of a versioned proxy,
+// or of one of its dynamic endpoints, orphaning every cache entry stored
+// under the previous generation across the cluster
+func BumpGeneration(ctx *fiber.Ctx) error {
+	c := cache.Get(ctx.Locals(str.LocalCacheName).(string))
+	if c == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(map[string]string{
+			"status": "no proxy configured with given name",
+		})
+	}
+
+	if !c.Proxy().Cache.Versioned {
+		return ctx.Status(fiber.StatusBadRequest).JSON(map[string]string{
+			"status": "bad request, proxy cache is not versioned",
+		})
+	}
+
+	endpoint := ctx.Params(str.ParamEndpoint)
+
+	generation, err := c.BumpGeneration(ctx.Context(), endpoint)
+	if err != nil {
+		util.Error(str.CAdmin, str.EBumpGeneration, c.Proxy().Name, err.Error())
+		return ctx.Status(fiber.StatusInternalServerError).JSON(map[string]string{
+			"status": "failed",
+			"error":  err.Error(),
+		})
+	}
+
+	response := map[string]interface{}{
+		"status":     "ok",
+		"endpoint":   endpoint,
+		"generation": generation,
+	}
+
+	if cluster.Enabled() {
+		response["cluster"] = cluster.Broadcast(ctx.Context(), cluster.Command{
+			Op:    cluster.OpGeneration,
+			Proxy: c.Proxy().Name,
+		})
+	}
+
+	return ctx.JSON(response)
+}
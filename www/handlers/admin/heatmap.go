@@ -0,0 +1,147 @@
+package admin
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/util"
+)
+
+// defaultHeatmapTiles is the number of tiles returned unless n is given
+const defaultHeatmapTiles = 100
+
+// heatTile is a requested tile with its bounds and estimated request count
+type heatTile struct {
+	Z      int        `json:"z"`      // zoom level
+	X      int        `json:"x"`      // tile column
+	Y      int        `json:"y"`      // tile row
+	Count  uint64     `json:"count"`  // estimated number of requests
+	Bounds [4]float64 `json:"bounds"` // [min_lon, min_lat, max_lon, max_lat]
+}
+
+// hotTile is a requested tile by its z/x/y path with its estimated request count
+type hotTile struct {
+	Tile  string `json:"tile"`  // z/x/y path of the tile
+	Count uint64 `json:"count"` // estimated number of requests
+}
+
+// Heatmap returns a proxy's most requested tiles along with their bounds, as
+// JSON or as a GeoJSON FeatureCollection of tile polygons if format=geojson.
+// The number of tiles is given by n and the zoom level by zoom, defaulting to
+// every zoom level.
+func Heatmap(ctx *fiber.Ctx) error {
+	tiles, done, err := hotTiles(ctx)
+	if done {
+		return err
+	}
+
+	heat := make([]heatTile, len(tiles))
+	for i, tileCount := range tiles {
+		bounds := tileCount.Tile.Bounds()
+		heat[i] = heatTile{
+			Z:      tileCount.Tile.Zoom,
+			X:      tileCount.Tile.X,
+			Y:      tileCount.Tile.Y,
+			Count:  tileCount.Count,
+			Bounds: [4]float64{bounds.MinX, bounds.MinY, bounds.MaxX, bounds.MaxY},
+		}
+	}
+
+	if ctx.Query("format") == "geojson" {
+		return ctx.JSON(featureCollection(heat))
+	}
+
+	return ctx.JSON(map[string]interface{}{
+		"proxy": ctx.Locals(str.LocalCacheName),
+		"tiles": heat,
+	})
+}
+
+// HotTiles returns a proxy's most requested tiles by z/x/y path, most
+// requested first. The number of tiles is given by n and the zoom level by
+// zoom, defaulting to every zoom level.
+func HotTiles(ctx *fiber.Ctx) error {
+	tiles, done, err := hotTiles(ctx)
+	if done {
+		return err
+	}
+
+	hot := make([]hotTile, len(tiles))
+	for i, tileCount := range tiles {
+		hot[i] = hotTile{
+			Tile:  fmt.Sprintf("%d/%d/%d", tileCount.Tile.Zoom, tileCount.Tile.X, tileCount.Tile.Y),
+			Count: tileCount.Count,
+		}
+	}
+
+	return ctx.JSON(map[string]interface{}{
+		"proxy": ctx.Locals(str.LocalCacheName),
+		"tiles": hot,
+	})
+}
+
+// hotTiles returns the most requested tiles of the request's proxy, or true
+// along with the result of writing an error response if they can't be
+func hotTiles(ctx *fiber.Ctx) ([]cache.TileCount, bool, error) {
+	c := cache.Get(ctx.Locals(str.LocalCacheName).(string))
+	if c == nil {
+		return nil, true, ctx.Status(fiber.StatusNotFound).JSON(map[string]string{
+			"status": "no proxy configured with given name",
+		})
+	}
+
+	if !c.Proxy().Heatmap.Enabled() {
+		return nil, true, ctx.Status(fiber.StatusNotFound).JSON(map[string]string{
+			"status": "failed",
+			"error":  "heatmap not enabled for proxy",
+		})
+	}
+
+	n := ctx.QueryInt("n", defaultHeatmapTiles)
+	if n < 1 || n > c.Proxy().Heatmap.TopK {
+		n = c.Proxy().Heatmap.TopK
+	}
+
+	tiles, err := c.HotTiles(ctx.Context(), n, ctx.QueryInt("zoom", -1))
+	if err != nil {
+		util.Error(str.CAdmin, str.EHeatmap, c.Proxy().Name, err.Error())
+		return nil, true, ctx.Status(fiber.StatusInternalServerError).JSON(map[string]string{
+			"status": "failed",
+			"error":  err.Error(),
+		})
+	}
+
+	return tiles, false, nil
+}
+
+// featureCollection returns a GeoJSON FeatureCollection of tile polygons
+// with their coordinates and request counts as properties
+func featureCollection(heat []heatTile) map[string]interface{} {
+	features := make([]map[string]interface{}, len(heat))
+	for i, t := range heat {
+		minLon, minLat, maxLon, maxLat := t.Bounds[0], t.Bounds[1], t.Bounds[2], t.Bounds[3]
+		features[i] = map[string]interface{}{
+			"type": "Feature",
+			"geometry": map[string]interface{}{
+				"type": "Polygon",
+				"coordinates": [][][2]float64{{
+					{minLon, minLat}, {maxLon, minLat}, {maxLon, maxLat}, {minLon, maxLat}, {minLon, minLat},
+				}},
+			},
+			"properties": map[string]interface{}{
+				"z":     t.Z,
+				"x":     t.X,
+				"y":     t.Y,
+				"count": t.Count,
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	}
+}
@@ -0,0 +1,87 @@
+package admin
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/cluster"
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/util"
+)
+
+// Keys returns a page of the entries of one of a proxy's cache levels whose
+// keys match the glob pattern given by the match parameter, continuing from
+// the scan cursor given by the cursor parameter
+func Keys(ctx *fiber.Ctx) error {
+	c := cache.Get(ctx.Locals(str.LocalCacheName).(string))
+	if c == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(map[string]string{
+			"status": "no proxy configured with given name",
+		})
+	}
+
+	count := ctx.QueryInt("count", cache.DefaultKeyCount)
+	if count < 1 || count > cache.MaxKeyCount {
+		count = cache.MaxKeyCount
+	}
+
+	page, err := c.Keys(ctx.Context(), ctx.Query("layer"), ctx.Query("match", "*"),
+		ctx.Query("cursor"), count)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(map[string]string{
+			"status": "failed",
+			"error":  err.Error(),
+		})
+	}
+
+	return ctx.JSON(page)
+}
+
+// DeleteKeys deletes every entry whose key matches the glob pattern given
+// by the match parameter from all cache levels of a proxy, and from the
+// local caches of every cluster peer
+func DeleteKeys(ctx *fiber.Ctx) error {
+	c := cache.Get(ctx.Locals(str.LocalCacheName).(string))
+	if c == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(map[string]string{
+			"status": "no proxy configured with given name",
+		})
+	}
+
+	// require an explicit pattern, "*" deletes everything
+	match := ctx.Query("match")
+	if match == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(map[string]string{
+			"status": "failed",
+			"error":  "match parameter required",
+		})
+	}
+
+	keys, err := c.DeleteKeys(ctx.Context(), match)
+	if err != nil {
+		util.Error(str.CAdmin, str.EDeleteKeys, c.Proxy().Name, match, err.Error())
+		return ctx.Status(fiber.StatusInternalServerError).JSON(map[string]interface{}{
+			"status": "failed",
+			"error":  err.Error(),
+			"keys":   keys,
+		})
+	}
+
+	util.Info(str.CAdmin, str.MDeleteKeys, c.Proxy().Name, match, keys)
+
+	response := map[string]interface{}{
+		"status": "ok",
+		"match":  match,
+		"keys":   keys,
+	}
+
+	if cluster.Enabled() {
+		response["cluster"] = cluster.Broadcast(ctx.Context(), cluster.Command{
+			Op:    cluster.OpDeleteKeys,
+			Proxy: c.Proxy().Name,
+			Match: match,
+		})
+	}
+
+	return ctx.JSON(response)
+}
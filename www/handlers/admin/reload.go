@@ -4,7 +4,9 @@ import (
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/cluster"
 	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/jobs"
 	"github.com/dechristopher/lod/str"
 	"github.com/dechristopher/lod/util"
 )
@@ -24,6 +26,15 @@ func ReloadCapabilities(ctx *fiber.Ctx) error {
 		return errorReload(ctx, err)
 	}
 
+	// reconnect to the cluster with the new configuration
+	err = cluster.Init()
+	if err != nil {
+		return errorReload(ctx, err)
+	}
+
+	// restart scheduled jobs with the new configuration
+	jobs.StartScheduler()
+
 	util.Info(str.CAdmin, str.MReload)
 	return ctx.JSON(map[string]string{
 		"status": "ok",
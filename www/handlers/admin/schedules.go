@@ -0,0 +1,29 @@
+package admin
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/dechristopher/lod/jobs"
+	"github.com/dechristopher/lod/str"
+)
+
+// Schedules returns the status of scheduled jobs for a proxy by name, or all proxies
+func Schedules(ctx *fiber.Ctx) error {
+	if ctx.Path() == "/admin/schedules" {
+		return ctx.JSON(map[string]interface{}{
+			"schedules": jobs.Statuses(""),
+		})
+	}
+
+	name := ctx.Locals(str.LocalCacheName).(string)
+	if name == "" {
+		// quit early if no name provided
+		return ctx.Status(fiber.StatusBadRequest).JSON(map[string]string{
+			"status": "bad request, no proxy name provided",
+		})
+	}
+
+	return ctx.JSON(map[string]interface{}{
+		"schedules": jobs.Statuses(name),
+	})
+}
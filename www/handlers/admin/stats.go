@@ -9,28 +9,32 @@ import (
 )
 
 type stats struct {
-	Hits     float64 `json:"hits"`     // total cache hits this proxy has encountered
-	Misses   float64 `json:"misses"`   // total cache misses this proxy has encountered
-	Requests float64 `json:"requests"` // total requests this proxy has serviced
-	HitRate  float64 `json:"hit_rate"` // overall hit rate, hits/total requests
-	TPS      float64 `json:"tps"`      // average tiles per second served over the past minute
-	Cache    fetch   `json:"cache"`    // cache fetch performance stats
-	Upstream fetch   `json:"upstream"` // upstream fetch performance stats
+	Hits     float64              `json:"hits"`             // total cache hits this proxy has encountered
+	Misses   float64              `json:"misses"`           // total cache misses this proxy has encountered
+	Requests float64              `json:"requests"`         // total requests this proxy has serviced
+	HitRate  float64              `json:"hit_rate"`         // overall hit rate, hits/total requests
+	TPS      float64              `json:"tps"`              // average tiles per second served over the past minute
+	Cache    fetch                `json:"cache"`            // cache fetch performance stats
+	Upstream fetch                `json:"upstream"`         // upstream fetch performance stats
+	Layers   []cache.BackendStats `json:"layers,omitempty"` // usage stats of each cache level
+}
+
+// globalStats are the stats of every proxy combined, along with each proxy's
+type globalStats struct {
+	stats
+	Proxies map[string]stats `json:"proxies"` // stats of each proxy by name
 }
 
 type fetch struct {
-	FetchAvg  float64 `json:"fetch_avg"`   // average upstream fetch time over the past minute
-	Fetch75th float64 `json:"fetch_75_th"` // 75th percentile upstream fetch time over the past minute
-	Fetch99th float64 `json:"fetch_99_th"` // 99th percentile upstream fetch time over the past minute
+	FetchAvg  float64 `json:"fetch_avg"`   // average fetch time in milliseconds over the past minute
+	Fetch75th float64 `json:"fetch_75_th"` // 75th percentile fetch time in milliseconds over the past minute
+	Fetch99th float64 `json:"fetch_99_th"` // 99th percentile fetch time in milliseconds over the past minute
 }
 
 // Stats returns stats for a cache by name, or all caches
 func Stats(ctx *fiber.Ctx) error {
-	// TODO implement global stats
 	if ctx.Path() == "/admin/stats" {
-		return ctx.JSON(map[string]string{
-			"stats": "all",
-		})
+		return ctx.JSON(allStats())
 	}
 
 	name := ctx.Locals(str.LocalCacheName).(string)
@@ -49,24 +53,58 @@ func Stats(ctx *fiber.Ctx) error {
 		})
 	}
 
+	return ctx.JSON(proxyStats(c))
+}
+
+// proxyStats returns the stats of a single proxy
+func proxyStats(c *cache.Cache) stats {
 	hits := util.GetMetricValue(c.Metrics.CacheHits)
 	misses := util.GetMetricValue(c.Metrics.CacheMisses)
 
-	return ctx.JSON(stats{
+	return newStats(hits, misses, c.Traffic(), c.Stats())
+}
+
+// allStats returns the stats of every proxy combined, with hit rates and
+// latencies weighted by each proxy's traffic
+func allStats() globalStats {
+	all := globalStats{Proxies: make(map[string]stats)}
+
+	var hits, misses float64
+	for name, c := range cache.All() {
+		proxy := proxyStats(c)
+		hits += proxy.Hits
+		misses += proxy.Misses
+		all.Proxies[name] = proxy
+	}
+
+	all.stats = newStats(hits, misses, cache.TotalTraffic(), nil)
+	return all
+}
+
+// newStats builds stats from hit counts and traffic over the past minute
+func newStats(hits, misses float64, traffic cache.Traffic, layers []cache.BackendStats) stats {
+	var hitRate float64
+	if hits+misses > 0 {
+		hitRate = hits / (hits + misses)
+	}
+
+	return stats{
 		Hits:     hits,
 		Misses:   misses,
 		Requests: hits + misses,
-		HitRate:  util.GetMetricValue(c.Metrics.HitRate),
-		TPS:      0,
-		Cache: fetch{
-			FetchAvg:  0,
-			Fetch75th: 0,
-			Fetch99th: 0,
-		},
-		Upstream: fetch{
-			FetchAvg:  0,
-			Fetch75th: 0,
-			Fetch99th: 0,
-		},
-	})
+		HitRate:  hitRate,
+		TPS:      traffic.TPS,
+		Cache:    newFetch(traffic.Cache),
+		Upstream: newFetch(traffic.Upstream),
+		Layers:   layers,
+	}
+}
+
+// newFetch converts latencies into fetch performance stats
+func newFetch(latency cache.Latency) fetch {
+	return fetch{
+		FetchAvg:  latency.Avg,
+		Fetch75th: latency.P75,
+		Fetch99th: latency.P99,
+	}
 }
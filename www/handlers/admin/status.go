@@ -3,24 +3,30 @@ package admin
 import (
 	"github.com/gofiber/fiber/v2"
 
+	"github.com/dechristopher/lod/cluster"
 	"github.com/dechristopher/lod/config"
 	"github.com/dechristopher/lod/env"
+	"github.com/dechristopher/lod/jobs"
 	"github.com/dechristopher/lod/util"
 )
 
 type statusResponse struct {
+	ID          string  `json:"id"`     // unique instance ID within the cluster
 	Version     string  `json:"v"`      // current lio version
 	Environment env.Env `json:"env"`    // configured environment
 	Uptime      float64 `json:"uptime"` // uptime in seconds
 	BootTime    int64   `json:"boot"`   // time started, unix timestamp
+	Ready       bool    `json:"ready"`  // whether the startup cache warmup completed
 }
 
 // Status returns a JSON object with status info
 func Status(c *fiber.Ctx) error {
 	return c.JSON(statusResponse{
+		ID:          cluster.InstanceID,
 		Version:     config.Version,
 		Environment: env.GetEnv(),
 		Uptime:      util.TimeSinceBoot().Seconds(),
 		BootTime:    util.BootTime.UnixMilli(),
+		Ready:       jobs.Ready(),
 	})
 }
@@ -0,0 +1,50 @@
+package admin
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/cluster"
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/util"
+)
+
+// InvalidateTag purges every tile tagged with the given tag from all cache
+// levels of a proxy, and from the local caches of every cluster peer
+func InvalidateTag(ctx *fiber.Ctx) error {
+	c := cache.Get(ctx.Locals(str.LocalCacheName).(string))
+	if c == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(map[string]string{
+			"status": "no proxy configured with given name",
+		})
+	}
+
+	tag := ctx.Params("tag")
+
+	tiles, err := c.InvalidateTag(ctx.Context(), tag)
+	if err != nil {
+		util.Error(str.CAdmin, str.EInvalidateTag, tag, err.Error())
+		return ctx.Status(fiber.StatusInternalServerError).JSON(map[string]string{
+			"status": "failed",
+			"error":  err.Error(),
+		})
+	}
+
+	util.Info(str.CAdmin, str.MInvalidateTag, tag, tiles)
+
+	response := map[string]interface{}{
+		"status": "ok",
+		"tag":    tag,
+		"tiles":  tiles,
+	}
+
+	if cluster.Enabled() {
+		response["cluster"] = cluster.Broadcast(ctx.Context(), cluster.Command{
+			Op:    cluster.OpPurgeTag,
+			Proxy: c.Proxy().Name,
+			Tag:   tag,
+		})
+	}
+
+	return ctx.JSON(response)
+}
@@ -0,0 +1,164 @@
+package admin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+
+	"github.com/dechristopher/lod/archive"
+	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/util"
+)
+
+// TransferTimeout is the time allowed to stream a cache archive to or from
+// the import and export endpoints, which the server's read and write
+// timeouts would otherwise cut short
+const TransferTimeout = 6 * time.Hour
+
+// RequestConfig extends the read and write deadlines of authorized cache
+// archive transfers, called by the server once request headers are received
+// and before streamed request bodies are read
+func RequestConfig(header *fasthttp.RequestHeader) fasthttp.RequestConfig {
+	if !authorized(header) || !isTransfer(string(header.Method()), string(header.RequestURI())) {
+		return fasthttp.RequestConfig{}
+	}
+	return fasthttp.RequestConfig{
+		ReadTimeout:  TransferTimeout,
+		WriteTimeout: TransferTimeout,
+	}
+}
+
+// authorized returns true if the request carries the configured admin token,
+// or no admin token is configured
+func authorized(header *fasthttp.RequestHeader) bool {
+	token := config.Get().Instance.AdminToken
+	return token == "" || string(header.Peek(fiber.HeaderAuthorization)) == "Bearer "+token
+}
+
+// isTransfer returns true if the request is for the import or export
+// endpoint of a configured proxy and one of its cache levels that can be
+// transferred, ex: POST /admin/tiles/import/memory
+func isTransfer(method, uri string) bool {
+	uri, _, _ = strings.Cut(uri, "?")
+	parts := strings.Split(uri, "/")
+	if len(parts) != 5 || parts[0] != "" || parts[1] != "admin" {
+		return false
+	}
+
+	c := cache.Get(parts[2])
+	if c == nil {
+		return false
+	}
+
+	switch {
+	case method == fiber.MethodPost && parts[3] == "import":
+		return c.Importable(parts[4]) == nil
+	case method == fiber.MethodGet && parts[3] == "export":
+		return c.Exportable(parts[4]) == nil
+	}
+	return false
+}
+
+// Export streams every entry of one of a proxy's cache levels as a cache
+// archive, resuming from the scan cursor given by the from parameter
+func Export(ctx *fiber.Ctx) error {
+	c := cache.Get(ctx.Locals(str.LocalCacheName).(string))
+	if c == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(map[string]string{
+			"status": "no proxy configured with given name",
+		})
+	}
+
+	layer := ctx.Params("layer")
+	from := ctx.Query("from")
+
+	// fail early, errors can't be reported once the archive is streaming
+	if err := c.Exportable(layer); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(map[string]string{
+			"status": "failed",
+			"error":  err.Error(),
+		})
+	}
+
+	ctx.Set(fiber.HeaderContentType, fiber.MIMEOctetStream)
+	ctx.Set(fiber.HeaderContentDisposition,
+		fmt.Sprintf(`attachment; filename="%s-%s.lodarc"`, c.Proxy().Name, layer))
+
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		writer, err := archive.NewWriter(w)
+		if err != nil {
+			util.Error(str.CAdmin, str.EExport, layer, c.Proxy().Name, from, err.Error())
+			return
+		}
+
+		result, err := c.Export(context.Background(), layer, from, writer, func(progress cache.Transfer) {
+			util.Info(str.CAdmin, str.MExportProgress, layer, c.Proxy().Name, progress.Entries, progress.Cursor)
+		})
+		if err != nil {
+			util.Error(str.CAdmin, str.EExport, layer, c.Proxy().Name, result.Cursor, err.Error())
+			return
+		}
+
+		util.Info(str.CAdmin, str.MExport, layer, c.Proxy().Name, result.Entries)
+	})
+
+	return nil
+}
+
+// Import restores a cache archive sent as the request body into one of a
+// proxy's cache levels, skipping the number of records given by the skip
+// parameter to resume a failed import
+func Import(ctx *fiber.Ctx) error {
+	c := cache.Get(ctx.Locals(str.LocalCacheName).(string))
+	if c == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(map[string]string{
+			"status": "no proxy configured with given name",
+		})
+	}
+
+	layer := ctx.Params("layer")
+	skip := ctx.QueryInt("skip", 0)
+
+	// read streamed bodies as they arrive, reading the body takes the
+	// stream, so only fall back to it for bodies that were read in full
+	var body io.Reader = ctx.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(ctx.Body())
+	}
+
+	reader, err := archive.NewReader(body)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(map[string]string{
+			"status": "failed",
+			"error":  err.Error(),
+		})
+	}
+
+	result, err := c.Import(ctx.Context(), layer, reader, skip, func(progress cache.Transfer) {
+		util.Info(str.CAdmin, str.MImportProgress, layer, c.Proxy().Name, progress.Entries, progress.Next)
+	})
+	if err != nil {
+		util.Error(str.CAdmin, str.EImport, layer, c.Proxy().Name, result.Next, err.Error())
+		return ctx.Status(fiber.StatusInternalServerError).JSON(map[string]interface{}{
+			"status":   "failed",
+			"error":    err.Error(),
+			"transfer": result,
+		})
+	}
+
+	util.Info(str.CAdmin, str.MImport, layer, c.Proxy().Name, result.Entries, result.Expired)
+
+	return ctx.JSON(map[string]interface{}{
+		"status":   "ok",
+		"transfer": result,
+	})
+}
@@ -0,0 +1,94 @@
+package admin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/dechristopher/lod/archive"
+	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/packet"
+	"github.com/dechristopher/lod/www/middleware"
+)
+
+// TestImportStream will test that archives larger than the body limit are
+// imported from the request body as it streams in, rather than once it has
+// been read into memory
+func TestImportStream(t *testing.T) {
+	c, err := cache.New(config.Proxy{Name: "test", Cache: config.Cache{
+		Layers:         []string{config.LayerMemory},
+		MemEnabled:     true,
+		MemCap:         64,
+		MemTTLDuration: time.Hour,
+	}})
+	if err != nil {
+		t.Fatalf("failed to build cache: %s", err)
+	}
+	cache.Caches["test"] = c
+	defer delete(cache.Caches, "test")
+
+	var body bytes.Buffer
+	writer, _ := archive.NewWriter(&body)
+	tile := packet.Encode(bytes.Repeat([]byte("tile"), 256), map[string]string{})
+	for i := 0; i < 100; i++ {
+		_ = writer.Write(archive.Record{Key: fmt.Sprintf("14/%d/0", i), Packet: tile.Raw()})
+	}
+	_ = writer.Flush()
+
+	app := fiber.New(fiber.Config{StreamRequestBody: true, BodyLimit: 16 * 1024})
+	app.Post("/admin/test/import/:layer", middleware.GenCacheNameMiddleware("test"), Import)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	go func() { _ = app.Listener(ln) }()
+	defer func() { _ = app.Shutdown() }()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	defer conn.Close()
+
+	// send half of the archive, which must be imported before the rest of
+	// the body arrives
+	archived := body.Bytes()
+	_, _ = fmt.Fprintf(conn, "POST /admin/test/import/memory HTTP/1.1\r\nHost: lod\r\nContent-Length: %d\r\n\r\n", len(archived))
+	_, _ = conn.Write(archived[:len(archived)/2])
+
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Peek("14/0/0", context.Background()) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("expected records to be imported while the body streams")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	_, _ = conn.Write(archived[len(archived)/2:])
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("failed to read response: %s", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Status   string
+		Error    string
+		Transfer cache.Transfer
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&result)
+	if resp.StatusCode != fiber.StatusOK || result.Transfer.Entries != 100 {
+		t.Fatalf("expected 100 entries imported, got status=%d %+v", resp.StatusCode, result)
+	}
+}
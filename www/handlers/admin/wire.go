@@ -8,6 +8,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/valyala/fasthttp/fasthttpadaptor"
 
+	"github.com/dechristopher/lod/cache"
 	"github.com/dechristopher/lod/config"
 	"github.com/dechristopher/lod/www/middleware"
 )
@@ -28,7 +29,7 @@ func Wire(r *fiber.App) {
 
 	if config.Get().Instance.MetricsEnabled {
 		// prometheus metrics endpoint
-		p := fasthttpadaptor.NewFastHTTPHandler(promhttp.Handler())
+		p := fasthttpadaptor.NewFastHTTPHandler(promhttp.HandlerFor(cache.Registry, promhttp.HandlerOpts{}))
 		adminGroup.Get("/metrics/prometheus", func(c *fiber.Ctx) error {
 			p(c.Context())
 			return nil
@@ -56,6 +57,16 @@ func Wire(r *fiber.App) {
 	// flush the in-memory caches of all proxies
 	adminGroup.Get("/flush", Flush)
 
+	// return the status of scheduled jobs for all proxies
+	adminGroup.Get("/schedules", Schedules)
+
+	// list live cluster members with their status and stats
+	adminGroup.Get("/cluster", Cluster)
+
+	// forward an admin request to all cluster members, or one member by ID
+	adminGroup.Get("/cluster/all/*", ClusterFanout)
+	adminGroup.Get("/cluster/peer/:id/*", ClusterPeer)
+
 	// Wire up named endpoints for each configured proxy
 	for _, proxy := range config.Get().Proxies {
 		namedAdminGroup := adminGroup.Group(proxy.Name)
@@ -73,6 +84,31 @@ func Wire(r *fiber.App) {
 			// configure proxy endpoint genHandler
 			namedAdminGroup.Get(handlerPath, handler)
 		}
+
+		// proxies with dynamic endpoints can also bump the generation
+		// shared by all of their endpoints
+		if proxy.HasEndpointParam {
+			namedAdminGroup.Get("/generation/bump", BumpGeneration)
+		}
+
+		// purge every tile tagged with a given tag, tags span all of a
+		// proxy's dynamic endpoints so no endpoint parameter is taken
+		namedAdminGroup.Get("/invalidate/tag/:tag", InvalidateTag)
+
+		// stream a cache level to a cache archive, or restore one into it,
+		// spanning all of a proxy's dynamic endpoints
+		namedAdminGroup.Get("/export/:layer", Export)
+		namedAdminGroup.Post("/import/:layer", Import)
+
+		// browse the keys of a cache level by glob pattern, or delete every
+		// key matching a pattern from all cache levels
+		namedAdminGroup.Get("/keys", Keys)
+		namedAdminGroup.Get("/keys/delete", DeleteKeys)
+
+		// report the most requested tiles as a heatmap or a ranked list,
+		// spanning all of a proxy's dynamic endpoints
+		namedAdminGroup.Get("/heatmap", Heatmap)
+		namedAdminGroup.Get("/heatmap/top", HotTiles)
 	}
 }
 
@@ -82,6 +118,11 @@ var namedEndpoints = map[string]fiber.Handler{
 	"/stats": Stats,
 	// flush the in-memory cache of a proxy by name
 	"/flush": Flush,
+	// bump the namespace generation of a versioned proxy, or of one of its
+	// dynamic endpoints, orphaning all entries cached under the previous one
+	"/generation/bump": BumpGeneration,
+	// return the status of scheduled jobs of a proxy by name
+	"/schedules": Schedules,
 	// invalidate a given tile without re-priming
 	"/invalidate/:z/:x/:y": InvalidateTile,
 	// invalidate a given tile and all of its children up to a given max
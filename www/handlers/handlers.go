@@ -5,6 +5,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/recover"
 
 	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/jobs"
 	"github.com/dechristopher/lod/www/handlers/admin"
 	"github.com/dechristopher/lod/www/handlers/proxy"
 	"github.com/dechristopher/lod/www/middleware"
@@ -16,6 +17,14 @@ func Wire(r *fiber.App) {
 	// recover from panics
 	r.Use(recover.New())
 
+	// readiness probe, failing until the startup cache warmup completes
+	r.Get("/ready", func(ctx *fiber.Ctx) error {
+		if !jobs.Ready() {
+			return ctx.SendStatus(fiber.StatusServiceUnavailable)
+		}
+		return ctx.SendStatus(fiber.StatusOK)
+	})
+
 	// wire admin group handlers if not disabled
 	if !config.Get().Instance.AdminDisabled {
 		admin.Wire(r)
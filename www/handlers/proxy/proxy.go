@@ -1,6 +1,9 @@
 package proxy
 
 import (
+	"strconv"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/sync/singleflight"
 
@@ -9,6 +12,7 @@ import (
 	"github.com/dechristopher/lod/helpers"
 	"github.com/dechristopher/lod/packet"
 	"github.com/dechristopher/lod/str"
+	"github.com/dechristopher/lod/tile"
 	"github.com/dechristopher/lod/util"
 )
 
@@ -19,14 +23,22 @@ type tileError struct {
 
 var flightGroup singleflight.Group
 
-// genHandler builds a new proxy endpoint handler from configuration
+// genHandler builds a new proxy endpoint handler from configuration. The
+// proxy's cache instance and configuration are looked up on every request,
+// since configuration reloads replace both without rewiring routes.
 func genHandler(p config.Proxy) fiber.Handler {
-	// get cache instance for this proxy
-	c := cache.Get(p.Name)
-
 	// handler function to wire to endpoint
 	return func(ctx *fiber.Ctx) error {
-		return handle(p, c, ctx)
+		// get cache instance for this proxy, which is gone if the proxy was
+		// removed by a reload
+		c := cache.Get(p.Name)
+		if c == nil {
+			return ctx.Status(fiber.StatusNotFound).SendString("")
+		}
+
+		err := handle(*c.Proxy(), c, ctx)
+		c.Metrics.BytesServed.Add(float64(len(ctx.Response().Body())))
+		return err
 	}
 }
 
@@ -38,30 +50,66 @@ func handle(p config.Proxy, c *cache.Cache, ctx *fiber.Ctx) error {
 
 	// build tileUrl and cacheKey from request context and config
 	tileUrl, cacheKey, err := buildKeyAndUrl(p, ctx)
-	if err != nil {
+
+	// tiles can't be cached while the namespace generation of a versioned
+	// proxy isn't known, so they're fetched from upstream without caching
+	_, bypass := err.(cache.ErrUnknownGeneration)
+	if err != nil && !bypass {
 		// buildKeyAndUrl log their own errors, so no need to here
 		return ctx.Status(fiber.StatusBadRequest).SendString("")
 	}
 
+	// pick the TTLs of any rule matching the tile's zoom level and endpoint
+	zoom, _ := ctx.ParamsInt(str.ParamZ)
+	ttls := c.TTLs(zoom, ctx.Params(str.ParamEndpoint))
+	c.ObserveRequest(zoom, ctx.Params(str.ParamEndpoint), p.Metrics.EndpointLabels)
+
+	// count sampled requests of the tile for the proxy's heatmap
+	if p.Heatmap.Enabled() {
+		if t, errTile := tile.Get(ctx); errTile == nil {
+			c.RecordTile(*t)
+		}
+	}
+
 	// attempt to fetch the tile from cache before hitting the upstream
-	if cachedTile := c.Fetch(cacheKey, ctx); cachedTile != nil {
+	var cachedTile *packet.Decoded
+	if !bypass {
+		cachedTile = c.Fetch(cacheKey, ttls, ctx)
+	}
+
+	if cachedTile != nil {
 		// IF WE HIT A CACHED TILE
 		if err = returnCachedTile(ctx, p, tileUrl, cachedTile); err != nil {
 			return ctx.Status(fiber.StatusInternalServerError).SendString("")
 		}
 	} else {
 		// IF WE MISSED A CACHED TILE
-		ctx.Locals(str.LocalCacheStatus, ":miss ")
+		flightKey := cacheKey
+		if bypass {
+			flightKey = tileUrl
+		} else {
+			ctx.Locals(str.LocalCacheStatus, ":miss ")
+		}
 
 		// clean up flight group after request is done
-		defer flightGroup.Forget(cacheKey)
+		defer flightGroup.Forget(flightKey)
 
 		// fetch tile via agent proxy, ensuring only a single request is in flight at a given time
-		response, errProxy, waited := flightGroup.Do(cacheKey, helpers.FetchUpstream(tileUrl, p))
+		fetched := false
+		response, errProxy, waited := flightGroup.Do(flightKey, func() (interface{}, error) {
+			fetched = true
+			return observeUpstream(c, helpers.FetchUpstream(tileUrl, p))
+		})
+
+		// singleflight reports every request sharing a fetch, including the
+		// one that made it
+		if waited && !fetched {
+			c.Metrics.FlightWaits.Inc()
+		}
 
 		if errProxy != nil {
 			// return internal server error status if agent proxy request failed in flight
-			util.Error(str.CProxy, str.EProxyAgentError, p.Name, cacheKey, errProxy.Error())
+			util.Error(str.CProxy, str.EProxyAgentError, p.Name, flightKey, errProxy.Error())
 			ctx.Locals(str.LocalCacheStatus, ":err-a")
 			return ctx.Status(fiber.StatusInternalServerError).SendString("")
 		}
@@ -75,7 +123,7 @@ func handle(p config.Proxy, c *cache.Cache, ctx *fiber.Ctx) error {
 
 		// sanity check to ensure cast worked properly
 		if !ok {
-			util.Error(str.CProxy, str.EProxyBadCast, p.Name, cacheKey)
+			util.Error(str.CProxy, str.EProxyBadCast, p.Name, flightKey)
 			ctx.Locals(str.LocalCacheStatus, ":err-i")
 			return ctx.Status(fiber.StatusInternalServerError).SendString("")
 		}
@@ -86,10 +134,12 @@ func handle(p config.Proxy, c *cache.Cache, ctx *fiber.Ctx) error {
 			Cache:     c,
 			Proxy:     p,
 			CacheKey:  cacheKey,
+			TTLs:      ttls,
 			Response:  proxyResp,
 			WriteData: true,
+			NoStore:   bypass,
 		}); err != nil {
-			util.Error(str.CProxy, str.EProxyWrite, p.Name, cacheKey, err.Error())
+			util.Error(str.CProxy, str.EProxyWrite, p.Name, flightKey, err.Error())
 			ctx.Locals(str.LocalCacheStatus, ":err-u")
 			// Send internal server error response with empty body if upstream
 			// fails to respond or responds with a non-200 status code
@@ -100,8 +150,24 @@ func handle(p config.Proxy, c *cache.Cache, ctx *fiber.Ctx) error {
 	return nil
 }
 
+// observeUpstream makes an upstream fetch, recording its latency and status
+// code once per fetch no matter how many requests wait on it
+func observeUpstream(c *cache.Cache, fetch func() (interface{}, error)) (interface{}, error) {
+	start := time.Now()
+	response, err := fetch()
+
+	code := 0
+	if proxyResp, ok := response.(helpers.ProxyResponse); ok && err == nil {
+		code = proxyResp.Code
+	}
+	c.ObserveUpstream(time.Since(start), code)
+
+	return response, err
+}
+
 // buildKeyAndUrl returns the upstream tile URL and cache key using the given
-// proxy configuration and fiber request context
+// proxy configuration and fiber request context. The tile URL is still
+// returned with cache.ErrUnknownGeneration so the tile can bypass the cache.
 func buildKeyAndUrl(p config.Proxy, ctx *fiber.Ctx) (string, string, error) {
 	// calculate url from the configured URL and params
 	tileUrl, err := helpers.BuildTileUrl(p, ctx)
@@ -113,6 +179,11 @@ func buildKeyAndUrl(p config.Proxy, ctx *fiber.Ctx) (string, string, error) {
 
 	// calculate the cache key for this request using XYZ and URL params
 	cacheKey, err := helpers.BuildCacheKey(p, ctx)
+	if _, ok := err.(cache.ErrUnknownGeneration); ok {
+		ctx.Locals(str.LocalCacheStatus, ":err-g")
+		util.DebugFlag("cache", str.CCache, str.DCacheNoGeneration, tileUrl)
+		return tileUrl, "", err
+	}
 	if err != nil {
 		ctx.Locals(str.LocalCacheStatus, ":err-c")
 		util.Error(str.CProxy, str.ECacheBuildKey, err.Error())
@@ -123,9 +194,26 @@ func buildKeyAndUrl(p config.Proxy, ctx *fiber.Ctx) (string, string, error) {
 }
 
 // returnCachedTile is called if the cache contains the requested tile
-func returnCachedTile(ctx *fiber.Ctx, p config.Proxy, tileUrl string, cachedTile *packet.TilePacket) error {
+func returnCachedTile(ctx *fiber.Ctx, p config.Proxy, tileUrl string, cachedTile *packet.Decoded) error {
+	// send compressed tiles as stored to clients accepting their encoding,
+	// and decompress them for everyone else
+	data := cachedTile.TileData()
+	codec := cachedTile.Meta().Codec
+	if codec != packet.CodecNone && !acceptsEncoding(ctx, codec.Encoding()) {
+		var err error
+		if data, err = cachedTile.Decompress(); err != nil {
+			ctx.Locals(str.LocalCacheStatus, ":err-w")
+			util.Error(str.CProxy, str.EWrite, err.Error(), tileError{
+				url:   tileUrl,
+				proxy: p,
+			})
+			return err
+		}
+		codec = packet.CodecNone
+	}
+
 	// write the tile to the response body
-	_, err := ctx.Write(cachedTile.TileData())
+	_, err := ctx.Write(data)
 	if err != nil {
 		ctx.Locals(str.LocalCacheStatus, ":err-w")
 		util.Error(str.CProxy, str.EWrite, err.Error(), tileError{
@@ -135,13 +223,43 @@ func returnCachedTile(ctx *fiber.Ctx, p config.Proxy, tileUrl string, cachedTile
 		return err
 	}
 
-	// set stored headers in response
+	// set stored headers in response, keeping internal headers private
 	for key, val := range cachedTile.Headers() {
+		if packet.IsInternal(key) {
+			continue
+		}
 		ctx.Set(key, val)
 	}
 
 	// remove delete list headers from final response
 	p.DoDeleteHeaders(ctx)
 
+	// responses for compressed tiles depend on the encodings clients accept
+	if cachedTile.Meta().Codec != packet.CodecNone {
+		ctx.Vary(fiber.HeaderAcceptEncoding)
+		if codec != packet.CodecNone {
+			ctx.Set(fiber.HeaderContentEncoding, codec.Encoding())
+		}
+	}
+
+	// set configured caching headers and the age of the cached tile
+	helpers.SetClientHeaders(ctx, p)
+	if fetched, ok := cachedTile.Fetched(); ok {
+		age := int64(time.Since(fetched).Seconds())
+		if age < 0 {
+			age = 0
+		}
+		ctx.Set(fiber.HeaderAge, strconv.FormatInt(age, 10))
+	}
+
 	return nil
 }
+
+// acceptsEncoding returns true if the client explicitly accepts the content
+// encoding, which clients that send no Accept-Encoding header don't
+func acceptsEncoding(ctx *fiber.Ctx, encoding string) bool {
+	if len(ctx.Request().Header.Peek(fiber.HeaderAcceptEncoding)) == 0 {
+		return false
+	}
+	return ctx.AcceptsEncodings(encoding) == encoding
+}
@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/dechristopher/lod/config"
+	"github.com/dechristopher/lod/packet"
+)
+
+// TestCachedTileHeaders will test that cached tiles are returned with the
+// client caching headers of their zoom level and their age
+func TestCachedTileHeaders(t *testing.T) {
+	p := config.Proxy{Name: "test", ClientCache: config.ClientCache{
+		MaxAge:         "1h",
+		MaxAgeDuration: time.Hour,
+		Rules: []config.ClientCache{
+			{MinZoom: 0, MaxZoom: 8, MaxAge: "24h", MaxAgeDuration: 24 * time.Hour},
+		},
+	}}
+
+	tile := packet.EncodeMeta([]byte("tile"), map[string]string{}, packet.Meta{
+		Fetched: time.Now().Add(-90 * time.Second),
+		Status:  fiber.StatusOK,
+	})
+	decoded, err := packet.FromBytes(tile, "tile")
+	if err != nil {
+		t.Fatalf("failed to decode tile: %s", err)
+	}
+
+	app := fiber.New()
+	app.Get("/:z/:x/:y", func(ctx *fiber.Ctx) error {
+		return returnCachedTile(ctx, p, "", decoded)
+	})
+
+	tests := []struct {
+		path         string
+		cacheControl string
+	}{
+		{"/4/1/1", "max-age=86400"},
+		{"/12/1/1", "max-age=3600"},
+	}
+
+	for _, test := range tests {
+		resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, test.path, nil))
+		if err != nil {
+			t.Fatalf("failed to request %s: %s", test.path, err)
+		}
+
+		if cacheControl := resp.Header.Get(fiber.HeaderCacheControl); cacheControl != test.cacheControl {
+			t.Errorf("%s: expected Cache-Control %q, got %q", test.path, test.cacheControl, cacheControl)
+		}
+
+		age, err := strconv.Atoi(resp.Header.Get(fiber.HeaderAge))
+		if err != nil || age < 89 || age > 91 {
+			t.Errorf("%s: expected Age of 90s, got %q", test.path, resp.Header.Get(fiber.HeaderAge))
+		}
+	}
+}
@@ -13,7 +13,8 @@ import (
 func Wire(r *fiber.App) {
 	for _, p := range config.Get().Proxies {
 		wireProxy(r, p)
-		util.Info(str.CMain, str.MProxy, p.Cache.MemEnabled, p.Cache.RedisEnabled, p.Name, p.TileURL)
+		util.Info(str.CMain, str.MProxy, p.Cache.MemEnabled, p.Cache.DiskEnabled, p.Cache.RedisEnabled,
+			p.Cache.MemcachedEnabled, p.Cache.S3Enabled, p.Name, p.TileURL)
 	}
 }
 
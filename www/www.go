@@ -14,33 +14,12 @@ import (
 	"github.com/dechristopher/lod/str"
 	"github.com/dechristopher/lod/util"
 	"github.com/dechristopher/lod/www/handlers"
+	"github.com/dechristopher/lod/www/handlers/admin"
 )
 
 // Serve all public endpoints
 func Serve() {
-	r := fiber.New(fiber.Config{
-		CaseSensitive:         true,
-		DisableStartupMessage: true,
-		ServerHeader:          "",
-		ProxyHeader:           "X-Forwarded-For",
-		ReadTimeout:           time.Second * 2,
-		WriteTimeout:          time.Second * 30,
-		IdleTimeout:           time.Hour,
-		ErrorHandler: func(ctx *fiber.Ctx, err error) error {
-			util.Error(str.CMain, str.ERequest, ctx.String(), err.Error())
-
-			// send JSON error output if in dev mode
-			if !env.IsProd() {
-				return ctx.Status(fiber.StatusInternalServerError).JSON(map[string]string{
-					"status": "internal server error",
-					"error":  err.Error(),
-				})
-			}
-
-			// otherwise, simply return 500
-			return ctx.Status(fiber.StatusInternalServerError).SendString("")
-		},
-	})
+	r := newRouter()
 
 	// STDOUT request logger
 	r.Use(logger.New(logger.Config{
@@ -76,6 +55,39 @@ func Serve() {
 	os.Exit(0)
 }
 
+// newRouter creates the router serving all endpoints, without any routes
+func newRouter() *fiber.App {
+	r := fiber.New(fiber.Config{
+		CaseSensitive:         true,
+		DisableStartupMessage: true,
+		ServerHeader:          "",
+		ProxyHeader:           "X-Forwarded-For",
+		ReadTimeout:           time.Second * 2,
+		WriteTimeout:          time.Second * 30,
+		IdleTimeout:           time.Hour,
+		StreamRequestBody:     true, // stream large cache archive imports
+		ErrorHandler: func(ctx *fiber.Ctx, err error) error {
+			util.Error(str.CMain, str.ERequest, ctx.String(), err.Error())
+
+			// send JSON error output if in dev mode
+			if !env.IsProd() {
+				return ctx.Status(fiber.StatusInternalServerError).JSON(map[string]string{
+					"status": "internal server error",
+					"error":  err.Error(),
+				})
+			}
+
+			// otherwise, simply return 500
+			return ctx.Status(fiber.StatusInternalServerError).SendString("")
+		},
+	})
+
+	// cache archive transfers may take far longer than the timeouts above
+	r.Server().HeaderReceived = admin.RequestConfig
+
+	return r
+}
+
 // logFormat returns the HTTP log format for the
 // configured fiber logger middleware
 func logFormat() string {
@@ -0,0 +1,115 @@
+package www
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/dechristopher/lod/cache"
+	"github.com/dechristopher/lod/config"
+)
+
+// streamSlowly posts a body to the router in chunks over longer than the
+// router's read timeout with the given token, returning the response status
+// and body
+func streamSlowly(t *testing.T, addr, path, token string) (int, string) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	defer conn.Close()
+
+	chunk := strings.Repeat("x", 1024)
+	chunks := 5
+
+	_, err = fmt.Fprintf(conn, "POST %s HTTP/1.1\r\nHost: lod\r\nAuthorization: Bearer %s\r\nContent-Length: %d\r\n\r\n",
+		path, token, len(chunk)*chunks)
+	if err != nil {
+		t.Fatalf("failed to write headers: %s", err)
+	}
+
+	for i := 0; i < chunks; i++ {
+		time.Sleep(600 * time.Millisecond)
+		if _, err = io.WriteString(conn, chunk); err != nil {
+			return 0, err.Error()
+		}
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+// TestTransferTimeout will test that cache archives can be streamed to the
+// import endpoint of a configured level for longer than the read timeout
+// other requests get, as long as they carry the admin token
+func TestTransferTimeout(t *testing.T) {
+	c, err := cache.New(config.Proxy{Name: "test", Cache: config.Cache{
+		Layers:         []string{config.LayerMemory},
+		MemEnabled:     true,
+		MemCap:         64,
+		MemTTLDuration: time.Hour,
+	}})
+	if err != nil {
+		t.Fatalf("failed to build cache: %s", err)
+	}
+	cache.Caches["test"] = c
+	defer delete(cache.Caches, "test")
+
+	config.Get().Instance.AdminToken = "secret"
+	defer func() { config.Get().Instance.AdminToken = "" }()
+
+	r := newRouter()
+	count := func(ctx *fiber.Ctx) error {
+		n, err := io.Copy(io.Discard, ctx.Context().RequestBodyStream())
+		if err != nil {
+			return err
+		}
+		return ctx.SendString(strconv.FormatInt(n, 10))
+	}
+	r.Post("/admin/test/import/:layer", count)
+	r.Post("/admin/test/other", count)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	go func() { _ = r.Listener(ln) }()
+	defer func() { _ = r.Shutdown() }()
+
+	tests := []struct {
+		path   string
+		token  string
+		extend bool
+	}{
+		{"/admin/test/import/memory?skip=0", "secret", true},
+		{"/admin/test/import/memory", "wrong", false},
+		{"/admin/test/import/redis", "secret", false},
+		{"/admin/test/other", "secret", false},
+	}
+
+	for _, test := range tests {
+		status, body := streamSlowly(t, ln.Addr().String(), test.path, test.token)
+		read := status == fiber.StatusOK && body == "5120"
+		if test.extend && !read {
+			t.Errorf("expected slowly streamed %s to be read in full, got status=%d body=%q",
+				test.path, status, body)
+		}
+		if !test.extend && read {
+			t.Errorf("expected slowly streamed %s with token %q to time out", test.path, test.token)
+		}
+	}
+}